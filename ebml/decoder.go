@@ -17,9 +17,15 @@ const (
 type DecodeOptions struct {
 	SkipDamaged   bool
 	DecodeUnknown func(id uint32, elem *Reader) error
+	// Level returns the depth of elements with the given ID in the schema, -1 if not known.
+	// An element of unknown size ends with the first element of the same or lower depth.
+	Level func(id uint32) int
 }
 
 func NewReader(r io.Reader, opt *DecodeOptions) *Reader {
+	if opt == nil {
+		opt = &DecodeOptions{}
+	}
 	seek, _ := r.(io.ReadSeeker)
 	return &Reader{
 		dec: &decoderState{
@@ -28,15 +34,20 @@ func NewReader(r io.Reader, opt *DecodeOptions) *Reader {
 			seek: seek,
 			src:  r,
 		},
-		len: -1,
+		len:   -1,
+		level: -1,
 	}
 }
 
 func NewReaderBytes(b []byte, opt *DecodeOptions) *Reader {
+	if opt == nil {
+		opt = &DecodeOptions{}
+	}
 	return &Reader{
 		dec: &decoderState{
 			opt: opt,
 			buf: b,
+			w:   len(b),
 		},
 		len: int64(len(b)),
 	}
@@ -46,6 +57,10 @@ type Reader struct {
 	dec *decoderState
 	sub *Reader
 	len int64
+	// Depth and content offset of the element of unknown size
+	level int
+	start int64
+	done  bool
 }
 
 // Read reads the EBML-encoded element bytes into b.
@@ -54,6 +69,9 @@ func (r *Reader) Read(b []byte) (int, error) {
 		return 0, err
 	}
 	if r.len < 0 {
+		if r.done {
+			return 0, io.EOF
+		}
 		return r.dec.Read(b)
 	}
 	if r.len < int64(len(b)) {
@@ -79,17 +97,56 @@ func (r *Reader) Decode(v interface{}) error {
 	return unmarshal(r, p, r.dec.opt)
 }
 
+// DecodeElement reads the content of the element with the given ID and stores it
+// in the field of the struct pointed to by v mapped to that ID.
+// Elements without such field are passed to DecodeUnknown or skipped.
+func (r *Reader) DecodeElement(id uint32, v interface{}) error {
+	p := reflect.ValueOf(v)
+	if p.Kind() != reflect.Ptr || p.IsNil() {
+		return errors.New("ebml: decode not a pointer")
+	}
+	s, err := getStructMapping(p.Elem().Type())
+	if err != nil {
+		return err
+	}
+	if f, ok := s.ids[id]; ok {
+		return f.unmarshal(r, p.Elem().Field(f.index), r.dec.opt)
+	}
+	if r.dec.opt.DecodeUnknown != nil {
+		return r.dec.opt.DecodeUnknown(id, r)
+	}
+	return nil
+}
+
 // ReadElement reads the next EMBL-encoded element ID and size
+// An element of unknown size ends with io.EOF if the next element is not its child.
 func (r *Reader) ReadElement() (id uint32, elem *Reader, err error) {
+	if r.done {
+		return 0, nil, io.EOF
+	}
 	id, err = r.readID()
 	if err != nil {
 		return
 	}
-	elem, err = r.readElement()
+	if r.len < 0 && r.ends(id) {
+		// The element is read by the parent
+		r.dec.pending, r.done = id, true
+		return 0, nil, io.EOF
+	}
+	elem, err = r.readElement(id)
 	r.sub = elem
 	return
 }
 
+// ends reports whether the element of unknown size ends with the element of the given ID.
+func (r *Reader) ends(id uint32) bool {
+	if r.level < 0 || r.dec.opt.Level == nil {
+		return false
+	}
+	l := r.dec.opt.Level(id)
+	return l >= 0 && l <= r.level
+}
+
 // ReadString reads and returns a UTF-8 encoded EBML string value.
 func (r *Reader) ReadString() (string, error) {
 	if r.len < 0 {
@@ -115,6 +172,17 @@ func (r *Reader) Len() int64 {
 	return r.len
 }
 
+// Offset returns the position of the next element in the input stream.
+func (r *Reader) Offset() int64 {
+	off := r.dec.off - int64(idLen(r.dec.pending))
+	for s := r.sub; s != nil; s = s.sub {
+		if s.len > 0 {
+			off += s.len
+		}
+	}
+	return off
+}
+
 // ReadFloat reads and returns a EBML int value.
 func (r *Reader) ReadInt() (int64, error) {
 	if r.len < 0 || r.len > 8 {
@@ -172,6 +240,9 @@ func (r *Reader) next(n int) ([]byte, error) {
 		return nil, err
 	}
 	if r.len < 0 {
+		if r.done {
+			return nil, io.EOF
+		}
 		return r.dec.Next(n)
 	}
 	if r.len == 0 {
@@ -196,11 +267,33 @@ func (r *Reader) skip() error {
 		}
 		s = s.sub
 	}
+	if s = r.sub; s.len < 0 && r.len >= 0 {
+		// Content of the element of unknown size is a part of this element
+		n := r.dec.off - int64(idLen(r.dec.pending)) - s.start + v
+		if n > r.len {
+			return io.ErrUnexpectedEOF
+		}
+		r.len -= n
+	}
 	r.sub = nil
 	return r.dec.Skip(v)
 }
 
 func (r *Reader) readID() (uint32, error) {
+	if id := r.dec.pending; id != 0 {
+		if err := r.skip(); err != nil {
+			return 0, err
+		}
+		n := int64(idLen(id))
+		if r.len >= 0 {
+			if r.len < n {
+				return 0, io.EOF
+			}
+			r.len -= n
+		}
+		r.dec.pending = 0
+		return id, nil
+	}
 	b, err := r.next(1)
 	for err == nil && b[0] < 0x10 {
 		// Skip incomplete elements
@@ -252,7 +345,28 @@ func (r *Reader) ReadVInt() (int64, error) {
 	return i, nil
 }
 
-func (r *Reader) readElement() (*Reader, error) {
+// ParseVInt returns the variable size integer at the start of b and its length.
+// Returns zero length if b is too short or does not start with the integer.
+func ParseVInt(b []byte) (int64, int) {
+	if len(b) == 0 || b[0] == 0 {
+		return 0, 0
+	}
+	n, bit := 1, byte(0x80)
+	for b[0]&bit == 0 {
+		n++
+		bit >>= 1
+	}
+	if len(b) < n {
+		return 0, 0
+	}
+	v := int64(b[0] & (bit - 1))
+	for _, it := range b[1:n] {
+		v = v<<8 | int64(it)
+	}
+	return v, n
+}
+
+func (r *Reader) readElement(id uint32) (*Reader, error) {
 	b, err := r.next(1)
 	if err != nil {
 		return nil, err
@@ -279,7 +393,11 @@ func (r *Reader) readElement() (*Reader, error) {
 	}
 	if mask == 0xff {
 		// Unknown element size
-		return &Reader{r.dec, nil, -1}, nil
+		elem := &Reader{dec: r.dec, len: -1, level: -1, start: r.dec.off}
+		if r.dec.opt.Level != nil {
+			elem.level = r.dec.opt.Level(id)
+		}
+		return elem, nil
 	}
 	if r.len >= 0 {
 		if r.len < size {
@@ -287,7 +405,16 @@ func (r *Reader) readElement() (*Reader, error) {
 		}
 		r.len -= size
 	}
-	return &Reader{r.dec, nil, size}, nil
+	return &Reader{dec: r.dec, len: size}, nil
+}
+
+// idLen returns the length of the encoded element ID, zero for zero ID.
+func idLen(id uint32) int {
+	n := 0
+	for ; id != 0; id >>= 8 {
+		n++
+	}
+	return n
 }
 
 type decoderState struct {
@@ -297,6 +424,8 @@ type decoderState struct {
 	buf  []byte
 	r, w int
 	off  int64
+	// ID of the element read after the end of the element of unknown size
+	pending uint32
 }
 
 func (s *decoderState) Offset() int64 {
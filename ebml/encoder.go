@@ -1,184 +1,222 @@
 package ebml
 
 import (
-// "errors"
-// "io"
-// "reflect"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"reflect"
+	"time"
 )
 
-/*
-type EncodeOptions struct {
-}
-
-type Encoder struct {
-	w *Writer
-}
+// Global EBML element IDs.
+const (
+	IDVoid  = 0xEC
+	IDCRC32 = 0xBF
+)
 
-func NewEncoder(w io.Writer, opt *EncodeOptions) *Encoder {
-	seek,_ := w.(io.WriteSeeker)
-	dec.w = &Writer{
-		dec: &encoderState{
-			buf: make([]byte, bufferSize),
-			seek: seek,
-			src: r,
-			opt: opt,
-		},
-		len: -1,
+// Marshal returns the EBML encoding of v.
+func Marshal(v interface{}) ([]byte, error) {
+	w := &Writer{}
+	if err := w.Encode(v); err != nil {
+		return nil, err
 	}
-	return dec
-}
-
-// Encode writes the EBML encoding of v to the stream.
-func (enc *Encoder) Flush() (err error) {
-	enc.pack()
-	enc.render()
-
-	enc.flush(w)
-	// TODO: flush
-	enc.elem = nil
-	return nil
+	return w.buf, nil
 }
 
+// Writer writes EBML elements to an output stream.
 type Writer struct {
-	*encoderState
+	dst io.Writer
+	buf []byte
 }
 
-type encoderState struct {
-}
-
-// An Encoder writes EBML elements to an output stream.
-type Encoder struct {
-	w io.Writer
-
-	id   int64
-	size int
-	buf  []byte
-	elem []*Encoder
+// NewWriter returns a new writer that writes to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{dst: w}
 }
 
-// NewEncoder returns a new encoder that writes to w.
-func NewEncoder(w io.Writer) *Encoder {
-	enc := new(Encoder)
-	enc.w = w
-	return enc
+// Write writes the raw element bytes.
+func (w *Writer) Write(b []byte) (int, error) {
+	w.buf = append(w.buf, b...)
+	return len(b), w.flush()
 }
 
 // Encode writes the EBML encoding of v to the stream.
-func (enc *Encoder) Encode(v interface{}) (err error) {
+func (w *Writer) Encode(v interface{}) error {
 	if u, ok := v.(Marshaler); ok {
-		return u.MarshalEBML(enc)
+		if err := u.MarshalEBML(w); err != nil {
+			return err
+		}
+		return w.flush()
 	}
 	if v == nil {
-		return errors.New("ebml: Encode nil")
+		return errors.New("ebml: encode nil")
 	}
-	ref := reflect.ValueOf(v)
-	if ref = ref.Elem(); ref.Kind() != reflect.Struct {
-		return errors.New("ebml: Decode not a struct")
+	if err := marshal(w, reflect.ValueOf(v)); err != nil {
+		return err
 	}
-	codec := &typeCodec{ref}
-	return codec.MarshalEBML(enc)
+	return w.flush()
 }
 
-
-
-func (enc *Encoder) pack() int64 {
-	for _, it := range enc.elem {
-		enc.size += it.pack()
+// EncodeElement writes the EBML element with the given ID and the encoding of v as a content.
+func (w *Writer) EncodeElement(id uint32, v interface{}) error {
+	if v == nil {
+		return errors.New("ebml: encode nil")
+	}
+	if err := marshalElement(w, id, reflect.ValueOf(v)); err != nil {
+		return err
 	}
-	return enc.size
+	return w.flush()
 }
 
-func (enc *Encoder) render() {
-	if enc.id != 0 {
+// WriteElementHeader writes the EBML element ID and size.
+// Negative size writes the element of unknown size.
+func (w *Writer) WriteElementHeader(id uint32, size int64) error {
+	return w.WriteElementHeaderWidth(id, size, 0)
+}
 
+// WriteElementHeaderWidth writes the EBML element ID and size encoded using width bytes.
+// Zero width selects the shortest encoding.
+func (w *Writer) WriteElementHeaderWidth(id uint32, size int64, width int) error {
+	if width == 0 {
+		width = VIntWidth(size)
 	}
-	for _, it := range enc.elem {
-		it.render()
+	if width < 1 || width > 8 || size >= 0 && VIntWidth(size) > width {
+		return errFormat("size")
 	}
+	w.buf = appendID(w.buf, id)
+	w.buf = AppendVInt(w.buf, size, width)
+	return w.flush()
 }
 
-// NewElement writes the EBML element of v to the stream, adding outermost element header.
-func (enc *Encoder) NewElement(id int64) *Encoder {
-	e := &Encoder{id: id}
-	enc.elem = append(enc.elem, e)
-	return e
+// WriteVInt writes the EBML variable size integer.
+func (w *Writer) WriteVInt(v int64) error {
+	if v < 0 {
+		return errFormat("vint")
+	}
+	w.buf = AppendVInt(w.buf, v, VIntWidth(v))
+	return w.flush()
 }
 
-func (enc *Encoder) WriteInt(id int64, v int64) {
-	// TODO: optimized version of byte write
-	var b []byte
-	pos := 0
-	for i, off := range intOffset {
-		it := byte(v >> off)
-		if it != 0 {
-			if b == nil {
-				b = enc.next(8 - i)
-			}
-			b[pos] = it
-			pos++
+// WriteVoid writes the Void element of exactly n bytes length including its header.
+func (w *Writer) WriteVoid(n int64) error {
+	if n < 2 {
+		return errFormat("void")
+	}
+	width := 1
+	if n-2 > 126 {
+		width = 8
+	}
+	if err := w.WriteElementHeaderWidth(IDVoid, n-1-int64(width), width); err != nil {
+		return err
+	}
+	b := make([]byte, 4096)
+	for n -= 1 + int64(width); n > 0; n -= int64(len(b)) {
+		if n < int64(len(b)) {
+			b = b[:n]
+		}
+		if _, err := w.Write(b); err != nil {
+			return err
 		}
 	}
+	return nil
 }
 
-func (enc *Encoder) WriteData(id int64, v []byte) {
-
+func (w *Writer) flush() error {
+	if w.dst == nil || len(w.buf) == 0 {
+		return nil
+	}
+	_, err := w.dst.Write(w.buf)
+	w.buf = w.buf[:0]
+	return err
 }
 
-func (enc *Encoder) WriteString(id int64, v string) {
+func (w *Writer) writeElement(id uint32, fn func(w *Writer) error) error {
+	e := &Writer{}
+	if err := fn(e); err != nil {
+		return err
+	}
+	w.buf = appendID(w.buf, id)
+	w.buf = AppendVInt(w.buf, int64(len(e.buf)), VIntWidth(int64(len(e.buf))))
+	w.buf = append(w.buf, e.buf...)
+	return nil
+}
 
+func appendID(b []byte, id uint32) []byte {
+	switch {
+	case id < 1<<8:
+		return append(b, byte(id))
+	case id < 1<<16:
+		return append(b, byte(id>>8), byte(id))
+	case id < 1<<24:
+		return append(b, byte(id>>16), byte(id>>8), byte(id))
+	default:
+		return append(b, byte(id>>24), byte(id>>16), byte(id>>8), byte(id))
+	}
 }
 
-func (enc *Encoder) writeVint(id int64, v int64) {
+// AppendVInt appends the variable size integer v of n bytes to b.
+// Negative v is encoded as the unknown element size.
+func AppendVInt(b []byte, v int64, n int) []byte {
+	if v < 0 {
+		// Unknown element size
+		b = append(b, 0xff>>uint(n-1))
+		for i := 1; i < n; i++ {
+			b = append(b, 0xff)
+		}
+		return b
+	}
+	v |= 1 << uint(7*n)
+	for i := n - 1; i >= 0; i-- {
+		b = append(b, byte(v>>uint(8*i)))
+	}
+	return b
+}
 
+// VIntWidth returns the number of bytes to encode v as the variable size integer.
+func VIntWidth(v int64) int {
+	n := 1
+	for n < 8 && v >= 1<<uint(7*n)-1 {
+		n++
+	}
+	return n
 }
 
-func (enc *Encoder) next(n int) (b []byte) {
-	off := enc.size + n
-	if m := len(enc.buf); m < off {
-		if m = off; m < 64 {
-			m = 64
-		} else {
-			m <<= 1
-		}
-		b = make([]byte, m)
-		copy(b, enc.buf[:enc.size])
-		enc.buf = b
+func appendInt(b []byte, v int64) []byte {
+	n := 1
+	for n < 8 && (v < -1<<uint(8*n-1) || v >= 1<<uint(8*n-1)) {
+		n++
+	}
+	for i := n - 1; i >= 0; i-- {
+		b = append(b, byte(v>>uint(8*i)))
 	}
-	b, enc.size = enc.buf[enc.size:off], off
-	return
+	return b
 }
 
-func (dec *Decoder) readVsint(off int) (v int64, n int, err error) {
-	m, err := dec.buf.ReadByte()
-	if err != nil {
-		return
+func appendUint(b []byte, v uint64) []byte {
+	n := 1
+	for n < 8 && v >= 1<<uint(8*n) {
+		n++
 	}
-	dec.len--
-	var bit byte
-	for n, bit = range mask {
-		if m&bit != 0 {
-			v = int64(m & rest[n+off])
-			break
-		}
+	for i := n - 1; i >= 0; i-- {
+		b = append(b, byte(v>>uint(8*i)))
 	}
-	if n > 0 {
-		if dec.len < int64(n) && 0 < dec.size {
-			err = io.EOF
-			return
-		}
-		var b []byte
-		if b, err = dec.buf.Peek(n); err != nil {
-			return
-		}
-		for _, it := range b {
-			v = (v << 8) | int64(it)
-		}
-		if _, err = dec.buf.Discard(n); err != nil {
-			return
-		}
-		dec.len -= int64(n)
+	return b
+}
+
+func appendFloat(b []byte, v float64, size int) []byte {
+	if size == 4 {
+		var p [4]byte
+		binary.BigEndian.PutUint32(p[:], math.Float32bits(float32(v)))
+		return append(b, p[:]...)
 	}
-	return
+	var p [8]byte
+	binary.BigEndian.PutUint64(p[:], math.Float64bits(v))
+	return append(b, p[:]...)
 }
 
-*/
+func appendTime(b []byte, t time.Time) []byte {
+	var p [8]byte
+	binary.BigEndian.PutUint64(p[:], uint64(t.Sub(timeAbs)))
+	return append(b, p[:]...)
+}
@@ -18,12 +18,89 @@ type Unmarshaler interface {
 }
 
 // Marshaler is the interface implemented by objects that can marshal themselves into valid EBML.
-// type Marshaler interface {
-// 	MarshalEBML(enc *Encoder) error
-// }
+type Marshaler interface {
+	MarshalEBML(w *Writer) error
+}
+
+func marshal(w *Writer, v reflect.Value) error {
+	if v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+	}
+	if v.CanInterface() {
+		if u, ok := v.Interface().(Marshaler); ok {
+			return u.MarshalEBML(w)
+		}
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+		switch t {
+		case timeType:
+			w.buf = appendTime(w.buf, v.Interface().(time.Time))
+		default:
+			s, err := getStructMapping(t)
+			if err != nil {
+				return err
+			}
+			return s.marshal(w, v)
+		}
+	case reflect.Ptr, reflect.Interface:
+		return marshal(w, v.Elem())
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.Uint8 {
+			return &errMarshal{v.Type()}
+		}
+		w.buf = append(w.buf, v.Bytes()...)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		w.buf = appendInt(w.buf, v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		w.buf = appendUint(w.buf, v.Uint())
+	case reflect.Bool:
+		if v.Bool() {
+			w.buf = append(w.buf, 1)
+		} else {
+			w.buf = append(w.buf, 0)
+		}
+	case reflect.Float32, reflect.Float64:
+		w.buf = appendFloat(w.buf, v.Float(), int(v.Type().Size()))
+	case reflect.String:
+		w.buf = append(w.buf, v.String()...)
+	default:
+		return &errMarshal{v.Type()}
+	}
+	return nil
+}
 
-// func marshal(w *Writer, v reflect.Value, opt *EncodeOptions) error {
-// }
+func marshalElement(w *Writer, id uint32, v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.Uint8 {
+			for i, n := 0, v.Len(); i < n; i++ {
+				if err := marshalElement(w, id, v.Index(i)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+	return w.writeElement(id, func(w *Writer) error {
+		return marshal(w, v)
+	})
+}
+
+type errMarshal struct {
+	t reflect.Type
+}
+
+func (e *errMarshal) Error() string {
+	return "ebml: can not marshal " + e.t.String()
+}
 
 func unmarshal(r *Reader, v reflect.Value, opt *DecodeOptions) error {
 	switch v.Kind() {
@@ -62,8 +139,11 @@ func unmarshal(r *Reader, v reflect.Value, opt *DecodeOptions) error {
 		e := v.Type().Elem()
 		switch e.Kind() {
 		case reflect.Uint8:
-			b, err := r.next(int(r.len)) // TODO: limit
-			if err != nil {
+			if r.len < 0 {
+				return errFormat("binary")
+			}
+			b := make([]byte, r.len)
+			if _, err := io.ReadFull(r, b); err != nil {
 				return err
 			}
 			v.SetBytes(b)
@@ -116,6 +196,32 @@ func unmarshal(r *Reader, v reflect.Value, opt *DecodeOptions) error {
 	return nil
 }
 
+// unmarshalSigned reads the EBML signed int value into v.
+func unmarshalSigned(r *Reader, v reflect.Value) error {
+	e := v
+	if e.Kind() == reflect.Slice {
+		e = reflect.New(e.Type().Elem()).Elem()
+	}
+	switch e.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+	default:
+		return &errUnmarshal{v.Type()}
+	}
+	n := uint(r.len)
+	i, err := r.ReadInt()
+	if err != nil {
+		return err
+	}
+	if n > 0 && n < 8 && i&(1<<(8*n-1)) != 0 {
+		i -= 1 << (8 * n)
+	}
+	e.SetInt(i)
+	if v.Kind() == reflect.Slice {
+		v.Set(reflect.Append(v, e))
+	}
+	return nil
+}
+
 type errUnmarshal struct {
 	t reflect.Type
 }
@@ -175,6 +281,19 @@ func (m *structMapping) setDefaults(v reflect.Value) error {
 	return nil
 }
 
+func (m *structMapping) marshal(w *Writer, v reflect.Value) error {
+	for _, it := range m.fields {
+		f := v.Field(it.index)
+		if it.empty(f) {
+			continue
+		}
+		if err := it.marshal(w, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (m *structMapping) unmarshal(r *Reader, v reflect.Value, opt *DecodeOptions) error {
 	for {
 		id, elem, err := r.ReadElement()
@@ -206,12 +325,12 @@ type field struct {
 	name      string
 	def       *reflect.Value
 	omitempty bool
+	signed    bool
 }
 
 func newField(t reflect.StructField, index int, tag string) (*field, error) {
 	v := strings.Split(tag, ",")
 	seq := strings.Split(v[0], ">")
-	// TODO: omitempty
 
 	f := &field{
 		index: index,
@@ -232,6 +351,8 @@ func newField(t reflect.StructField, index int, tag string) (*field, error) {
 		switch it {
 		case "omitempty":
 			f.omitempty = true
+		case "signed":
+			f.signed = true
 		default:
 			def, err := newDefault(t.Type, it)
 			if err != nil {
@@ -262,10 +383,50 @@ func newDefault(t reflect.Type, v string) (interface{}, error) {
 	}
 }
 
+// empty reports whether the field value can be omitted from the encoding.
+func (f *field) empty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	case reflect.Slice:
+		return v.Len() == 0
+	}
+	if !f.omitempty {
+		return false
+	}
+	if f.def != nil {
+		return v.Interface() == f.def.Interface()
+	}
+	return v.IsZero()
+}
+
+func (f *field) marshal(w *Writer, v reflect.Value) error {
+	if len(f.seq) > 0 {
+		return f.marshalSeq(w, v, append([]uint32{f.id}, f.seq...))
+	}
+	return marshalElement(w, f.id, v)
+}
+
+func (f *field) marshalSeq(w *Writer, v reflect.Value, seq []uint32) error {
+	if len(seq) == 1 {
+		return marshalElement(w, seq[0], v)
+	}
+	return w.writeElement(seq[0], func(w *Writer) error {
+		return f.marshalSeq(w, v, seq[1:])
+	})
+}
+
 func (f *field) unmarshal(r *Reader, v reflect.Value, opt *DecodeOptions) error {
 	if len(f.seq) > 0 {
 		return f.unmarshalSeq(r, v, opt, f.seq)
 	}
+	return f.unmarshalValue(r, v, opt)
+}
+
+func (f *field) unmarshalValue(r *Reader, v reflect.Value, opt *DecodeOptions) error {
+	if f.signed {
+		return unmarshalSigned(r, v)
+	}
 	return unmarshal(r, v, opt)
 }
 
@@ -282,7 +443,7 @@ func (f *field) unmarshalSeq(r *Reader, v reflect.Value, opt *DecodeOptions, seq
 			if len(seq) > 1 {
 				err = f.unmarshalSeq(elem, v, opt, seq[1:])
 			} else {
-				err = unmarshal(elem, v, opt)
+				err = f.unmarshalValue(elem, v, opt)
 			}
 		} else if opt.DecodeUnknown != nil {
 			err = opt.DecodeUnknown(id, elem)
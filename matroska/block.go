@@ -0,0 +1,174 @@
+package matroska
+
+import (
+	"errors"
+	"github.com/pixelbender/go-matroska/ebml"
+	"io"
+)
+
+var errBlockFormat = errors.New("matroska: block format error")
+
+// maxBlockSize limits the size of blocks read from damaged or malicious input.
+const maxBlockSize = 256 << 20
+
+// Keyframe reports whether the SimpleBlock contains a keyframe.
+func (b *Block) Keyframe() bool {
+	return b.Flags&BlockFlagKeyframe != 0
+}
+
+// Lacing returns the lacing type used to store frames of the block.
+func (b *Block) Lacing() uint8 {
+	return (b.Flags >> 1) & 3
+}
+
+func (b *Block) UnmarshalEBML(r *ebml.Reader) error {
+	if r.Len() < 0 {
+		return errBlockFormat
+	}
+	if r.Len() > maxBlockSize {
+		return errors.New("matroska: block size too large")
+	}
+	p := make([]byte, r.Len())
+	if _, err := io.ReadFull(r, p); err != nil {
+		return err
+	}
+	return b.unmarshal(p)
+}
+
+func (b *Block) unmarshal(p []byte) error {
+	track, n := ebml.ParseVInt(p)
+	if n == 0 || len(p) < n+3 {
+		return errBlockFormat
+	}
+	b.TrackNumber = TrackNumber(track)
+	b.Timecode = int16(p[n])<<8 | int16(p[n+1])
+	b.Flags = p[n+2]
+	p = p[n+3:]
+	lacing := b.Lacing()
+	if lacing == LacingNone {
+		b.Frames = [][]byte{p}
+		return nil
+	}
+	if len(p) == 0 {
+		return errBlockFormat
+	}
+	count := int(p[0]) + 1
+	p = p[1:]
+	sizes := make([]int, count)
+	switch lacing {
+	case LacingXiph:
+		for i := range sizes[:count-1] {
+			for {
+				if len(p) == 0 {
+					return errBlockFormat
+				}
+				v := p[0]
+				p = p[1:]
+				sizes[i] += int(v)
+				if v != 0xff {
+					break
+				}
+			}
+		}
+	case LacingFixedSize:
+		if len(p)%count != 0 {
+			return errBlockFormat
+		}
+		for i := range sizes[:count-1] {
+			sizes[i] = len(p) / count
+		}
+	case LacingEBML:
+		for i := range sizes[:count-1] {
+			v, n := ebml.ParseVInt(p)
+			if n == 0 {
+				return errBlockFormat
+			}
+			p = p[n:]
+			if i == 0 {
+				sizes[i] = int(v)
+			} else {
+				sizes[i] = sizes[i-1] + int(v-(1<<uint(7*n-1)-1))
+			}
+		}
+	}
+	total := 0
+	for _, it := range sizes[:count-1] {
+		if it < 0 {
+			return errBlockFormat
+		}
+		total += it
+	}
+	if total > len(p) {
+		return errBlockFormat
+	}
+	sizes[count-1] = len(p) - total
+	b.Frames = make([][]byte, count)
+	for i, it := range sizes {
+		b.Frames[i], p = p[:it], p[it:]
+	}
+	return nil
+}
+
+func (b *Block) MarshalEBML(w *ebml.Writer) error {
+	n := len(b.Frames)
+	if n == 0 || n > 256 {
+		return errBlockFormat
+	}
+	lacing := b.Lacing()
+	switch {
+	case n == 1:
+		lacing = LacingNone
+	case lacing == LacingNone:
+		lacing = LacingFixedSize
+		for _, it := range b.Frames {
+			if len(it) != len(b.Frames[0]) {
+				lacing = LacingEBML
+				break
+			}
+		}
+	case lacing == LacingFixedSize:
+		for _, it := range b.Frames {
+			if len(it) != len(b.Frames[0]) {
+				return errBlockFormat
+			}
+		}
+	}
+	h := ebml.AppendVInt(nil, int64(b.TrackNumber), ebml.VIntWidth(int64(b.TrackNumber)))
+	h = append(h, byte(b.Timecode>>8), byte(b.Timecode), b.Flags&^6|lacing<<1)
+	if lacing != LacingNone {
+		h = append(h, byte(n-1))
+	}
+	switch lacing {
+	case LacingXiph:
+		for _, it := range b.Frames[:n-1] {
+			v := len(it)
+			for ; v >= 0xff; v -= 0xff {
+				h = append(h, 0xff)
+			}
+			h = append(h, byte(v))
+		}
+	case LacingEBML:
+		for i, it := range b.Frames[:n-1] {
+			v := int64(len(it))
+			if i == 0 {
+				h = ebml.AppendVInt(h, v, ebml.VIntWidth(v))
+				continue
+			}
+			v -= int64(len(b.Frames[i-1]))
+			k := 1
+			for k < 8 && (v < -(1<<uint(7*k-1)-1) || v > 1<<uint(7*k-1)-1) {
+				k++
+			}
+			h = ebml.AppendVInt(h, v+1<<uint(7*k-1)-1, k)
+		}
+	}
+	if _, err := w.Write(h); err != nil {
+		return err
+	}
+	for _, it := range b.Frames {
+		if _, err := w.Write(it); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,386 @@
+package matroska
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ReadChaptersOGM reads chapters in the OGM simple format:
+//
+//	CHAPTER01=00:00:00.000
+//	CHAPTER01NAME=Intro
+//
+// and returns them as a single edition. Chapter names are assigned the given language.
+func ReadChaptersOGM(r io.Reader, lang string) ([]*Edition, error) {
+	atoms := make(map[int]*ChapterAtom)
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := strings.TrimSpace(strings.TrimPrefix(s.Text(), "\ufeff"))
+		if line == "" {
+			continue
+		}
+		p := strings.IndexByte(line, '=')
+		if p < 0 || !strings.HasPrefix(line, "CHAPTER") {
+			return nil, errors.New("matroska: invalid OGM chapter line: " + line)
+		}
+		key, val := line[7:p], line[p+1:]
+		name := strings.HasSuffix(key, "NAME")
+		if name {
+			key = key[:len(key)-4]
+		}
+		n, err := strconv.Atoi(key)
+		if err != nil {
+			return nil, errors.New("matroska: invalid OGM chapter number: " + line)
+		}
+		a, ok := atoms[n]
+		if !ok {
+			id, err := newUID()
+			if err != nil {
+				return nil, err
+			}
+			a = &ChapterAtom{ID: ChapterID(id), Enabled: true}
+			atoms[n] = a
+		}
+		if name {
+			a.Displays = append(a.Displays, &ChapterDisplay{String: val, Language: lang})
+			continue
+		}
+		t, err := parseChapterTime(val)
+		if err != nil {
+			return nil, err
+		}
+		a.TimeStart = Time(t)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	keys := make([]int, 0, len(atoms))
+	for n := range atoms {
+		keys = append(keys, n)
+	}
+	sort.Ints(keys)
+	id, err := newEditionID()
+	if err != nil {
+		return nil, err
+	}
+	ed := &Edition{ID: id}
+	for _, n := range keys {
+		ed.Atoms = append(ed.Atoms, atoms[n])
+	}
+	return []*Edition{ed}, nil
+}
+
+// WriteChaptersOGM writes visible chapters of the default edition in the OGM simple format.
+// Nested chapters are written in depth-first order, only the first chapter name is written.
+func WriteChaptersOGM(w io.Writer, eds []*Edition) error {
+	ed := (&Segment{Chapters: eds}).DefaultEdition()
+	if ed == nil {
+		return nil
+	}
+	b := bufio.NewWriter(w)
+	n := 0
+	var write func(atoms []*ChapterAtom)
+	write = func(atoms []*ChapterAtom) {
+		for _, it := range atoms {
+			if it.Hidden || !it.Enabled {
+				continue
+			}
+			n++
+			name := ""
+			if len(it.Displays) > 0 {
+				name = it.Displays[0].String
+			}
+			t := time.Duration(it.TimeStart)
+			fmt.Fprintf(b, "CHAPTER%02d=%02d:%02d:%02d.%03d\n", n, t/time.Hour, t/time.Minute%60, t/time.Second%60, t/time.Millisecond%1000)
+			fmt.Fprintf(b, "CHAPTER%02dNAME=%s\n", n, name)
+			write(it.Atoms)
+		}
+	}
+	write(ed.Atoms)
+	return b.Flush()
+}
+
+// ReadChaptersXML reads chapters in the Matroska chapter XML format used by mkvtoolnix.
+func ReadChaptersXML(r io.Reader) ([]*Edition, error) {
+	v := &xmlChapters{}
+	if err := xml.NewDecoder(r).Decode(v); err != nil {
+		return nil, err
+	}
+	eds := make([]*Edition, 0, len(v.Editions))
+	for _, it := range v.Editions {
+		ed := &Edition{
+			ID:      encodeEditionID(it.UID),
+			Hidden:  bool(it.Hidden),
+			Default: bool(it.Default),
+			Ordered: bool(it.Ordered),
+		}
+		var err error
+		if it.UID == 0 {
+			if ed.ID, err = newEditionID(); err != nil {
+				return nil, err
+			}
+		}
+		atoms, err := readXMLAtoms(it.Atoms)
+		if err != nil {
+			return nil, err
+		}
+		ed.Atoms = atoms
+		eds = append(eds, ed)
+	}
+	return eds, nil
+}
+
+func readXMLAtoms(list []*xmlChapterAtom) ([]*ChapterAtom, error) {
+	var atoms []*ChapterAtom
+	for _, it := range list {
+		a := &ChapterAtom{
+			ID:            ChapterID(it.UID),
+			StringID:      it.StringUID,
+			Hidden:        bool(it.Hidden),
+			Enabled:       it.Enabled == nil || bool(*it.Enabled),
+			EditionID:     encodeEditionID(it.SegmentEditionUID),
+			PhysicalEquiv: it.PhysicalEquiv,
+		}
+		if a.ID == 0 {
+			id, err := newUID()
+			if err != nil {
+				return nil, err
+			}
+			a.ID = ChapterID(id)
+		}
+		t, err := parseChapterTime(it.TimeStart)
+		if err != nil {
+			return nil, err
+		}
+		a.TimeStart = Time(t)
+		if it.TimeEnd != "" {
+			if t, err = parseChapterTime(it.TimeEnd); err != nil {
+				return nil, err
+			}
+			a.TimeEnd = Time(t)
+		}
+		if it.SegmentUID != "" {
+			if a.SegmentID, err = hex.DecodeString(strings.Replace(it.SegmentUID, " ", "", -1)); err != nil {
+				return nil, err
+			}
+		}
+		for _, t := range it.Tracks {
+			a.Tracks = append(a.Tracks, TrackID(t))
+		}
+		for _, d := range it.Displays {
+			a.Displays = append(a.Displays, &ChapterDisplay{
+				String:       d.String,
+				Language:     d.Language,
+				LanguageIETF: d.LanguageIETF,
+				Country:      d.Country,
+			})
+			if d.Language == "" {
+				a.Displays[len(a.Displays)-1].Language = "eng"
+			}
+		}
+		if a.Atoms, err = readXMLAtoms(it.Atoms); err != nil {
+			return nil, err
+		}
+		atoms = append(atoms, a)
+	}
+	return atoms, nil
+}
+
+// WriteChaptersXML writes chapters in the Matroska chapter XML format used by mkvtoolnix.
+func WriteChaptersXML(w io.Writer, eds []*Edition) error {
+	v := &xmlChapters{}
+	for _, it := range eds {
+		v.Editions = append(v.Editions, &xmlEdition{
+			UID:     decodeEditionID(it.ID),
+			Hidden:  xmlFlag(it.Hidden),
+			Default: xmlFlag(it.Default),
+			Ordered: xmlFlag(it.Ordered),
+			Atoms:   writeXMLAtoms(it.Atoms),
+		})
+	}
+	if _, err := io.WriteString(w, xml.Header+"<!DOCTYPE Chapters SYSTEM \"matroskachapters.dtd\">\n"); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func writeXMLAtoms(atoms []*ChapterAtom) []*xmlChapterAtom {
+	var list []*xmlChapterAtom
+	for _, it := range atoms {
+		enabled := xmlFlag(it.Enabled)
+		a := &xmlChapterAtom{
+			UID:               uint64(it.ID),
+			StringUID:         it.StringID,
+			TimeStart:         formatChapterTime(time.Duration(it.TimeStart)),
+			Hidden:            xmlFlag(it.Hidden),
+			Enabled:           &enabled,
+			SegmentEditionUID: decodeEditionID(it.EditionID),
+			PhysicalEquiv:     it.PhysicalEquiv,
+			Atoms:             writeXMLAtoms(it.Atoms),
+		}
+		if it.TimeEnd != 0 {
+			a.TimeEnd = formatChapterTime(time.Duration(it.TimeEnd))
+		}
+		if len(it.SegmentID) > 0 {
+			a.SegmentUID = hex.EncodeToString(it.SegmentID)
+		}
+		for _, t := range it.Tracks {
+			a.Tracks = append(a.Tracks, uint64(t))
+		}
+		for _, d := range it.Displays {
+			a.Displays = append(a.Displays, &xmlChapterDisplay{
+				String:       d.String,
+				Language:     d.Language,
+				LanguageIETF: d.LanguageIETF,
+				Country:      d.Country,
+			})
+		}
+		list = append(list, a)
+	}
+	return list
+}
+
+type xmlChapters struct {
+	XMLName  xml.Name      `xml:"Chapters"`
+	Editions []*xmlEdition `xml:"EditionEntry"`
+}
+
+type xmlEdition struct {
+	UID     uint64            `xml:"EditionUID,omitempty"`
+	Hidden  xmlFlag           `xml:"EditionFlagHidden"`
+	Default xmlFlag           `xml:"EditionFlagDefault"`
+	Ordered xmlFlag           `xml:"EditionFlagOrdered,omitempty"`
+	Atoms   []*xmlChapterAtom `xml:"ChapterAtom"`
+}
+
+type xmlChapterAtom struct {
+	UID               uint64               `xml:"ChapterUID,omitempty"`
+	StringUID         string               `xml:"ChapterStringUID,omitempty"`
+	TimeStart         string               `xml:"ChapterTimeStart"`
+	TimeEnd           string               `xml:"ChapterTimeEnd,omitempty"`
+	Hidden            xmlFlag              `xml:"ChapterFlagHidden"`
+	Enabled           *xmlFlag             `xml:"ChapterFlagEnabled"`
+	SegmentUID        string               `xml:"ChapterSegmentUID,omitempty"`
+	SegmentEditionUID uint64               `xml:"ChapterSegmentEditionUID,omitempty"`
+	PhysicalEquiv     int                  `xml:"ChapterPhysicalEquiv,omitempty"`
+	Tracks            []uint64             `xml:"ChapterTrack>ChapterTrackNumber,omitempty"`
+	Displays          []*xmlChapterDisplay `xml:"ChapterDisplay"`
+	Atoms             []*xmlChapterAtom    `xml:"ChapterAtom"`
+}
+
+type xmlChapterDisplay struct {
+	String       string `xml:"ChapterString"`
+	Language     string `xml:"ChapterLanguage,omitempty"`
+	LanguageIETF string `xml:"ChapLanguageIETF,omitempty"`
+	Country      string `xml:"ChapterCountry,omitempty"`
+}
+
+// xmlFlag is a boolean encoded as 0 or 1.
+type xmlFlag bool
+
+func (f xmlFlag) MarshalText() ([]byte, error) {
+	if f {
+		return []byte("1"), nil
+	}
+	return []byte("0"), nil
+}
+
+func (f *xmlFlag) UnmarshalText(b []byte) error {
+	v, err := strconv.ParseBool(strings.TrimSpace(string(b)))
+	*f = xmlFlag(v)
+	return err
+}
+
+// parseChapterTime parses time in the HH:MM:SS.nnnnnnnnn format.
+func parseChapterTime(s string) (time.Duration, error) {
+	p := strings.Split(strings.TrimSpace(s), ":")
+	if len(p) != 3 {
+		return 0, errors.New("matroska: invalid chapter time: " + s)
+	}
+	h, err := strconv.Atoi(p[0])
+	if err != nil {
+		return 0, errors.New("matroska: invalid chapter time: " + s)
+	}
+	m, err := strconv.Atoi(p[1])
+	if err != nil {
+		return 0, errors.New("matroska: invalid chapter time: " + s)
+	}
+	sec, frac := p[2], ""
+	if i := strings.IndexByte(sec, '.'); i >= 0 {
+		sec, frac = sec[:i], sec[i+1:]
+	}
+	sv, err := strconv.Atoi(sec)
+	if err != nil || len(frac) > 9 {
+		return 0, errors.New("matroska: invalid chapter time: " + s)
+	}
+	ns := 0
+	if frac != "" {
+		if ns, err = strconv.Atoi(frac + strings.Repeat("0", 9-len(frac))); err != nil {
+			return 0, errors.New("matroska: invalid chapter time: " + s)
+		}
+	}
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute + time.Duration(sv)*time.Second + time.Duration(ns), nil
+}
+
+func formatChapterTime(t time.Duration) string {
+	return fmt.Sprintf("%02d:%02d:%02d.%09d", t/time.Hour, t/time.Minute%60, t/time.Second%60, t%time.Second)
+}
+
+// newUID returns a random non-zero unique identifier.
+func newUID() (uint64, error) {
+	var b [8]byte
+	for {
+		if _, err := rand.Read(b[:]); err != nil {
+			return 0, err
+		}
+		if v := binary.BigEndian.Uint64(b[:]); v != 0 {
+			return v, nil
+		}
+	}
+}
+
+func newEditionID() (EditionID, error) {
+	v, err := newUID()
+	if err != nil {
+		return nil, err
+	}
+	return encodeEditionID(v), nil
+}
+
+// encodeEditionID returns the binary representation of the unsigned edition ID.
+func encodeEditionID(v uint64) EditionID {
+	if v == 0 {
+		return nil
+	}
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], v)
+	i := 0
+	for b[i] == 0 {
+		i++
+	}
+	return EditionID(b[i:])
+}
+
+func decodeEditionID(id EditionID) uint64 {
+	v := uint64(0)
+	for _, b := range id {
+		v = v<<8 | uint64(b)
+	}
+	return v
+}
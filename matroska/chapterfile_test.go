@@ -0,0 +1,62 @@
+package matroska
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChaptersOGM(t *testing.T) {
+	in := "CHAPTER01=00:00:00.000\nCHAPTER01NAME=Intro\nCHAPTER02=00:01:30.500\nCHAPTER02NAME=Main\n"
+	eds, err := ReadChaptersOGM(strings.NewReader(in), "eng")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(eds) != 1 || len(eds[0].Atoms) != 2 {
+		t.Fatalf("Unexpected editions: %s", dump(eds))
+	}
+	if a := eds[0].Atoms[1]; time.Duration(a.TimeStart) != 90500*time.Millisecond || a.Displays[0].String != "Main" {
+		t.Errorf("Unexpected chapter: %s", dump(a))
+	}
+	out := &bytes.Buffer{}
+	if err = WriteChaptersOGM(out, eds); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != in {
+		t.Errorf("Unexpected OGM chapters, want: %q, got: %q", in, out.String())
+	}
+}
+
+func TestChaptersXML(t *testing.T) {
+	eds := []*Edition{{
+		ID:      EditionID{0x12, 0x34},
+		Default: true,
+		Atoms: []*ChapterAtom{{
+			ID:        1,
+			TimeStart: Time(time.Second),
+			TimeEnd:   Time(time.Minute),
+			Enabled:   true,
+			SegmentID: SegmentID{0xab, 0xcd},
+			Tracks:    []TrackID{1, 2},
+			Displays:  []*ChapterDisplay{{String: "Intro", Language: "eng", LanguageIETF: "en"}},
+			Atoms: []*ChapterAtom{{
+				ID:       2,
+				Hidden:   true,
+				Displays: []*ChapterDisplay{{String: "Début", Language: "fre", Country: "fr"}},
+			}},
+		}},
+	}}
+	b := &bytes.Buffer{}
+	if err := WriteChaptersXML(b, eds); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadChaptersXML(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(eds, got) {
+		t.Errorf("Unexpected editions, want: %s\ngot: %s", dump(eds), dump(got))
+	}
+}
@@ -0,0 +1,113 @@
+package matroska
+
+import "time"
+
+// Chapter is an entry of the flattened chapter list.
+// Chapter atom times are expressed in nanoseconds regardless of the TimecodeScale.
+type Chapter struct {
+	ID     ChapterID
+	Start  time.Duration
+	End    time.Duration     // Zero if not known
+	Depth  int               // Nesting level of the chapter atom
+	Titles map[string]string // Titles keyed by language
+}
+
+// ChapterTimeline returns chapters of the default edition as a flat list in depth-first order.
+//
+// Hidden and disabled atoms are skipped along with the nested ones.
+// Missing end times are taken from the next sibling, the parent or the Segment duration.
+// Chapters of the ordered edition are placed onto the continuous presentation timeline.
+func (s *Segment) ChapterTimeline() []*Chapter {
+	ed := s.DefaultEdition()
+	if ed == nil {
+		return nil
+	}
+	if !ed.Ordered {
+		return appendChapters(nil, ed.Atoms, s.duration(), 0, 0)
+	}
+	var list []*Chapter
+	off := time.Duration(0)
+	for _, it := range ed.Atoms {
+		if !it.Enabled || it.TimeEnd <= it.TimeStart {
+			continue
+		}
+		if !it.Hidden {
+			list = appendChapters(list, []*ChapterAtom{it}, 0, off-time.Duration(it.TimeStart), 0)
+		}
+		off += time.Duration(it.TimeEnd - it.TimeStart)
+	}
+	return list
+}
+
+// DefaultEdition returns the edition flagged as default, the first visible edition if there is no such,
+// or nil if the Segment has no chapters.
+func (s *Segment) DefaultEdition() *Edition {
+	for _, it := range s.Chapters {
+		if it.Default && !it.Hidden {
+			return it
+		}
+	}
+	for _, it := range s.Chapters {
+		if !it.Hidden {
+			return it
+		}
+	}
+	if len(s.Chapters) > 0 {
+		return s.Chapters[0]
+	}
+	return nil
+}
+
+// duration returns the Segment duration.
+func (s *Segment) duration() time.Duration {
+	for _, it := range s.Info {
+		if it.Duration > 0 {
+			return time.Duration(it.Duration * float64(it.TimecodeScale))
+		}
+	}
+	return 0
+}
+
+func appendChapters(list []*Chapter, atoms []*ChapterAtom, end, shift time.Duration, depth int) []*Chapter {
+	for _, it := range atoms {
+		if it.Hidden || !it.Enabled {
+			continue
+		}
+		start, stop := time.Duration(it.TimeStart), time.Duration(it.TimeEnd)
+		if stop <= start {
+			stop = end
+			for _, next := range atoms {
+				t := time.Duration(next.TimeStart)
+				if next.Enabled && t > start && (t < stop || stop <= start) {
+					stop = t
+				}
+			}
+			if stop <= start {
+				stop = -shift
+			}
+		}
+		list = append(list, &Chapter{
+			ID:     it.ID,
+			Start:  start + shift,
+			End:    stop + shift,
+			Depth:  depth,
+			Titles: it.titles(),
+		})
+		list = appendChapters(list, it.Atoms, stop, shift, depth+1)
+	}
+	return list
+}
+
+func (a *ChapterAtom) titles() map[string]string {
+	v := make(map[string]string)
+	for _, it := range a.Displays {
+		lang := it.LanguageIETF
+		if lang == "" {
+			lang = it.Language
+		}
+		if _, ok := v[lang]; !ok {
+			v[lang] = it.String
+		}
+	}
+	return v
+}
@@ -0,0 +1,54 @@
+package matroska
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestChapterTimeline(t *testing.T) {
+	atom := func(id ChapterID, start, end time.Duration, title string, atoms ...*ChapterAtom) *ChapterAtom {
+		return &ChapterAtom{
+			ID:        id,
+			TimeStart: Time(start),
+			TimeEnd:   Time(end),
+			Enabled:   true,
+			Displays:  []*ChapterDisplay{{String: title, Language: "eng"}},
+			Atoms:     atoms,
+		}
+	}
+	hidden := atom(4, 5*time.Second, 0, "Hidden")
+	hidden.Hidden = true
+	s := &Segment{
+		Info: []*Info{{TimecodeScale: time.Millisecond, Duration: 60000}},
+		Chapters: []*Edition{
+			{Atoms: []*ChapterAtom{atom(9, 0, 0, "Other")}},
+			{Default: true, Atoms: []*ChapterAtom{
+				atom(1, 0, 0, "Intro", atom(2, 0, 0, "Part 1"), atom(3, 10*time.Second, 0, "Part 2"), hidden),
+				atom(5, 20*time.Second, 0, "Main"),
+			}},
+		},
+	}
+	want := []*Chapter{
+		{1, 0, 20 * time.Second, 0, map[string]string{"eng": "Intro"}},
+		{2, 0, 5 * time.Second, 1, map[string]string{"eng": "Part 1"}},
+		{3, 10 * time.Second, 20 * time.Second, 1, map[string]string{"eng": "Part 2"}},
+		{5, 20 * time.Second, time.Minute, 0, map[string]string{"eng": "Main"}},
+	}
+	if got := s.ChapterTimeline(); !reflect.DeepEqual(want, got) {
+		t.Errorf("Unexpected chapters, want: %s\ngot: %s", dump(want), dump(got))
+	}
+	s.Chapters = []*Edition{{Ordered: true, Atoms: []*ChapterAtom{
+		atom(1, 30*time.Second, 40*time.Second, "Scene 1"),
+		hidden,
+		atom(2, 10*time.Second, 15*time.Second, "Scene 2"),
+	}}}
+	hidden.TimeEnd = Time(7 * time.Second)
+	want = []*Chapter{
+		{1, 0, 10 * time.Second, 0, map[string]string{"eng": "Scene 1"}},
+		{2, 12 * time.Second, 17 * time.Second, 0, map[string]string{"eng": "Scene 2"}},
+	}
+	if got := s.ChapterTimeline(); !reflect.DeepEqual(want, got) {
+		t.Errorf("Unexpected ordered chapters, want: %s\ngot: %s", dump(want), dump(got))
+	}
+}
@@ -0,0 +1,112 @@
+package codec
+
+// AAC audio object types
+const (
+	AACMain = 1
+	AACLC   = 2
+	AACSSR  = 3
+	AACLTP  = 4
+	AACSBR  = 5
+	AACPS   = 29
+)
+
+var aacSampleRates = []int{96000, 88200, 64000, 48000, 44100, 32000, 24000, 22050, 16000, 12000, 11025, 8000, 7350}
+
+// AACConfig is the AudioSpecificConfig of A_AAC tracks.
+// See ISO/IEC 14496-3.
+type AACConfig struct {
+	ObjectType int
+	SampleRate int
+	Channels   int
+	// Explicitly signalled SBR or PS extension
+	ExtensionObjectType int
+	ExtensionSampleRate int
+}
+
+// ParseAACConfig parses the AudioSpecificConfig.
+func ParseAACConfig(b []byte) (*AACConfig, error) {
+	r := &bitReader{b: b}
+	c := &AACConfig{}
+	c.ObjectType = readAACObjectType(r)
+	c.SampleRate = readAACSampleRate(r)
+	c.Channels = int(r.read(4))
+	if c.ObjectType == AACSBR || c.ObjectType == AACPS {
+		c.ExtensionObjectType = c.ObjectType
+		c.ExtensionSampleRate = readAACSampleRate(r)
+		c.ObjectType = readAACObjectType(r)
+	}
+	if c.Channels == 7 {
+		c.Channels = 8
+	}
+	if r.eof || c.SampleRate == 0 {
+		return nil, errShortConfig
+	}
+	return c, nil
+}
+
+// Bytes returns the AudioSpecificConfig without extensions.
+func (c *AACConfig) Bytes() []byte {
+	i := 0
+	for i < len(aacSampleRates) && aacSampleRates[i] != c.SampleRate {
+		i++
+	}
+	ch := c.Channels
+	if ch == 8 {
+		ch = 7
+	}
+	if i == len(aacSampleRates) {
+		return []byte{
+			byte(c.ObjectType<<3 | 0x7),
+			byte(0x80 | c.SampleRate>>17&0x7f),
+			byte(c.SampleRate >> 9),
+			byte(c.SampleRate >> 1),
+			byte(c.SampleRate<<7 | ch<<3),
+		}
+	}
+	return []byte{byte(c.ObjectType<<3 | i>>1), byte(i<<7 | ch<<3)}
+}
+
+// ADTSHeader returns the ADTS header of the raw AAC frame of the given size.
+// Returns nil if the configuration cannot be signalled in ADTS.
+func (c *AACConfig) ADTSHeader(size int) []byte {
+	i := 0
+	for i < len(aacSampleRates) && aacSampleRates[i] != c.SampleRate {
+		i++
+	}
+	ch := c.Channels
+	if ch == 8 {
+		ch = 7
+	}
+	if i == len(aacSampleRates) || c.ObjectType < 1 || c.ObjectType > 4 || ch > 7 {
+		return nil
+	}
+	n := size + 7
+	return []byte{
+		0xff,
+		0xf1,
+		byte((c.ObjectType-1)<<6 | i<<2 | ch>>2),
+		byte(ch&3<<6 | n>>11),
+		byte(n >> 3),
+		byte(n&7<<5 | 0x1f),
+		0xfc,
+	}
+}
+
+func readAACObjectType(r *bitReader) int {
+	v := int(r.read(5))
+	if v == 31 {
+		v = 32 + int(r.read(6))
+	}
+	return v
+}
+
+func readAACSampleRate(r *bitReader) int {
+	i := int(r.read(4))
+	if i == 0xf {
+		return int(r.read(24))
+	}
+	if i < len(aacSampleRates) {
+		return aacSampleRates[i]
+	}
+	return 0
+}
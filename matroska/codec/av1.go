@@ -0,0 +1,283 @@
+package codec
+
+import "errors"
+
+// AV1 OBU types
+const (
+	AV1OBUSequenceHeader    = 1
+	AV1OBUTemporalDelimiter = 2
+	AV1OBUFrameHeader       = 3
+	AV1OBUFrame             = 6
+)
+
+// AV1Config is the AV1CodecConfigurationRecord of V_AV1 tracks.
+// See https://aomediacodec.github.io/av1-isobmff/#av1codecconfigurationbox
+type AV1Config struct {
+	Profile              uint8
+	Level                uint8
+	Tier                 uint8
+	BitDepth             int
+	Monochrome           bool
+	ChromaSubsamplingX   bool
+	ChromaSubsamplingY   bool
+	ChromaSamplePosition uint8
+	ConfigOBUs           []byte // Sequence Header OBU
+	// Parsed from the Sequence Header OBU if present
+	Width  int
+	Height int
+}
+
+// ParseAV1Config parses the av1C configuration record.
+func ParseAV1Config(b []byte) (*AV1Config, error) {
+	if len(b) < 4 {
+		return nil, errShortConfig
+	}
+	if b[0] != 0x81 {
+		return nil, errInvalidConfig
+	}
+	c := &AV1Config{
+		Profile:              b[1] >> 5,
+		Level:                b[1] & 0x1f,
+		Tier:                 b[2] >> 7,
+		BitDepth:             8,
+		Monochrome:           b[2]&0x10 != 0,
+		ChromaSubsamplingX:   b[2]&0x08 != 0,
+		ChromaSubsamplingY:   b[2]&0x04 != 0,
+		ChromaSamplePosition: b[2] & 3,
+		ConfigOBUs:           b[4:],
+	}
+	if b[2]&0x40 != 0 {
+		c.BitDepth = 10
+		if b[2]&0x20 != 0 {
+			c.BitDepth = 12
+		}
+	}
+	for b = b[4:]; len(b) > 0; {
+		typ, payload, rest, err := readOBU(b)
+		if err != nil {
+			return nil, err
+		}
+		if typ == AV1OBUSequenceHeader {
+			if err = c.parseSequenceHeader(payload); err != nil {
+				return nil, err
+			}
+			break
+		}
+		b = rest
+	}
+	return c, nil
+}
+
+// readOBU reads the OBU type and payload of the next OBU.
+func readOBU(b []byte) (typ uint8, payload, rest []byte, err error) {
+	if len(b) < 1 {
+		return 0, nil, nil, errShortConfig
+	}
+	typ = b[0] >> 3 & 0xf
+	n := 1
+	if b[0]&0x04 != 0 {
+		n++
+	}
+	if b[0]&0x02 == 0 {
+		if n > len(b) {
+			return 0, nil, nil, errShortConfig
+		}
+		return typ, b[n:], nil, nil
+	}
+	var size uint64
+	for i := 0; ; i++ {
+		if n >= len(b) || i == 8 {
+			return 0, nil, nil, errShortConfig
+		}
+		v := b[n]
+		n++
+		size |= uint64(v&0x7f) << uint(7*i)
+		if v&0x80 == 0 {
+			break
+		}
+	}
+	if size > uint64(len(b)-n) {
+		return 0, nil, nil, errShortConfig
+	}
+	return typ, b[n : n+int(size)], b[n+int(size):], nil
+}
+
+// AV1ConfigFromOBUs returns the configuration record with the Sequence Header OBU found in the temporal unit.
+func AV1ConfigFromOBUs(b []byte) (*AV1Config, error) {
+	for len(b) > 0 {
+		typ, payload, rest, err := readOBU(b)
+		if err != nil {
+			return nil, err
+		}
+		if typ == AV1OBUSequenceHeader {
+			c := &AV1Config{ConfigOBUs: b[:len(b)-len(rest)]}
+			if err = c.parseSequenceHeader(payload); err != nil {
+				return nil, err
+			}
+			return c, nil
+		}
+		b = rest
+	}
+	return nil, errors.New("codec: sequence header not found")
+}
+
+// Bytes returns the av1C configuration record.
+func (c *AV1Config) Bytes() []byte {
+	b := []byte{0x81, c.Profile<<5 | c.Level&0x1f, c.Tier<<7 | c.ChromaSamplePosition&3, 0}
+	if c.BitDepth > 8 {
+		b[2] |= 0x40
+	}
+	if c.BitDepth == 12 {
+		b[2] |= 0x20
+	}
+	if c.Monochrome {
+		b[2] |= 0x10
+	}
+	if c.ChromaSubsamplingX {
+		b[2] |= 0x08
+	}
+	if c.ChromaSubsamplingY {
+		b[2] |= 0x04
+	}
+	return append(b, c.ConfigOBUs...)
+}
+
+// AV1Keyframe reports whether the temporal unit contains a key frame.
+func AV1Keyframe(b []byte) bool {
+	for len(b) > 0 {
+		typ, payload, rest, err := readOBU(b)
+		if err != nil {
+			return false
+		}
+		if typ == AV1OBUFrameHeader || typ == AV1OBUFrame {
+			// show_existing_frame is zero and frame_type is KEY_FRAME
+			return len(payload) > 0 && payload[0]&0xe0 == 0
+		}
+		b = rest
+	}
+	return false
+}
+
+// AV1TrimTemporalDelimiters removes Temporal Delimiter OBUs from the temporal unit,
+// as they must not be stored in Matroska blocks.
+func AV1TrimTemporalDelimiters(b []byte) []byte {
+	var out []byte
+	for p := b; len(p) > 0; {
+		typ, _, rest, err := readOBU(p)
+		if err != nil {
+			return b
+		}
+		if typ != AV1OBUTemporalDelimiter {
+			out = append(out, p[:len(p)-len(rest)]...)
+		}
+		p = rest
+	}
+	return out
+}
+
+func (c *AV1Config) parseSequenceHeader(b []byte) error {
+	r := &bitReader{b: b}
+	c.Profile = uint8(r.read(3))
+	r.skip(1) // still_picture
+	reduced := r.flag()
+	if reduced {
+		c.Level = uint8(r.read(5))
+	} else {
+		var decoderModel bool
+		delay := 0
+		if r.flag() { // timing_info_present_flag
+			r.skip(64)
+			if r.flag() { // equal_picture_interval
+				n := 0
+				for !r.flag() && !r.eof {
+					n++
+				}
+				r.skip(n)
+			}
+			if decoderModel = r.flag(); decoderModel {
+				delay = int(r.read(5)) + 1
+				r.skip(32 + 10)
+			}
+		}
+		display := r.flag()
+		points := int(r.read(5)) + 1
+		for i := 0; i < points && !r.eof; i++ {
+			r.skip(12) // operating_point_idc
+			level, tier := uint8(r.read(5)), uint8(0)
+			if level > 7 {
+				tier = uint8(r.read(1))
+			}
+			if i == 0 {
+				c.Level, c.Tier = level, tier
+			}
+			if decoderModel && r.flag() {
+				r.skip(2*delay + 1)
+			}
+			if display && r.flag() {
+				r.skip(4)
+			}
+		}
+	}
+	wb, hb := int(r.read(4))+1, int(r.read(4))+1
+	c.Width, c.Height = int(r.read(wb))+1, int(r.read(hb))+1
+	if !reduced && r.flag() { // frame_id_numbers_present_flag
+		r.skip(7)
+	}
+	r.skip(3) // use_128x128_superblock, enable_filter_intra, enable_intra_edge_filter
+	if !reduced {
+		r.skip(4) // enable_interintra_compound, enable_masked_compound, enable_warped_motion, enable_dual_filter
+		orderHint := r.flag()
+		if orderHint {
+			r.skip(2) // enable_jnt_comp, enable_ref_frame_mvs
+		}
+		force := true
+		if !r.flag() { // seq_choose_screen_content_tools
+			force = r.flag()
+		}
+		if force && !r.flag() { // seq_choose_integer_mv
+			r.skip(1)
+		}
+		if orderHint {
+			r.skip(3)
+		}
+	}
+	r.skip(3) // enable_superres, enable_cdef, enable_restoration
+	c.BitDepth = 8
+	if r.flag() { // high_bitdepth
+		c.BitDepth = 10
+		if c.Profile == 2 && r.flag() {
+			c.BitDepth = 12
+		}
+	}
+	c.Monochrome = c.Profile != 1 && r.flag()
+	cp, tc, mc := uint64(2), uint64(2), uint64(2)
+	if r.flag() { // color_description_present_flag
+		cp, tc, mc = r.read(8), r.read(8), r.read(8)
+	}
+	c.ChromaSubsamplingX, c.ChromaSubsamplingY, c.ChromaSamplePosition = true, true, 0
+	switch {
+	case c.Monochrome:
+	case cp == 1 && tc == 13 && mc == 0:
+		c.ChromaSubsamplingX, c.ChromaSubsamplingY = false, false
+	default:
+		r.skip(1) // color_range
+		switch c.Profile {
+		case 1:
+			c.ChromaSubsamplingX, c.ChromaSubsamplingY = false, false
+		case 2:
+			c.ChromaSubsamplingY = false
+			if c.BitDepth == 12 {
+				if c.ChromaSubsamplingX = r.flag(); c.ChromaSubsamplingX {
+					c.ChromaSubsamplingY = r.flag()
+				}
+			}
+		}
+		if c.ChromaSubsamplingX && c.ChromaSubsamplingY {
+			c.ChromaSamplePosition = uint8(r.read(2))
+		}
+	}
+	if r.eof {
+		return errShortConfig
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package codec
+
+import "errors"
+
+// AVC NAL unit types
+const (
+	AVCNALIDR = 5
+	AVCNALSPS = 7
+	AVCNALPPS = 8
+	AVCNALAUD = 9
+)
+
+// AVCConfig is the AVCDecoderConfigurationRecord of V_MPEG4/ISO/AVC tracks.
+// See ISO/IEC 14496-15.
+type AVCConfig struct {
+	Profile       uint8
+	Compatibility uint8
+	Level         uint8
+	LengthSize    int // Size of NAL unit length prefixes
+	SPS           [][]byte
+	PPS           [][]byte
+	// Parsed from the first SPS
+	ChromaFormat int
+	BitDepth     int
+	Width        int
+	Height       int
+}
+
+// ParseAVCConfig parses the avcC configuration record.
+func ParseAVCConfig(b []byte) (*AVCConfig, error) {
+	if len(b) < 7 {
+		return nil, errShortConfig
+	}
+	if b[0] != 1 {
+		return nil, errInvalidConfig
+	}
+	c := &AVCConfig{
+		Profile:       b[1],
+		Compatibility: b[2],
+		Level:         b[3],
+		LengthSize:    int(b[4]&3) + 1,
+	}
+	var err error
+	if c.SPS, b, err = readNALUs(b[6:], int(b[5]&0x1f)); err != nil {
+		return nil, err
+	}
+	if len(b) < 1 {
+		return nil, errShortConfig
+	}
+	if c.PPS, _, err = readNALUs(b[1:], int(b[0])); err != nil {
+		return nil, err
+	}
+	if len(c.SPS) > 0 {
+		if err = c.parseSPS(c.SPS[0]); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// AVCConfigFromAnnexB returns the configuration record with parameter sets found in the Annex B byte stream.
+func AVCConfigFromAnnexB(b []byte) (*AVCConfig, error) {
+	c := &AVCConfig{LengthSize: 4}
+	for _, it := range SplitAnnexB(b) {
+		switch it[0] & 0x1f {
+		case AVCNALSPS:
+			c.SPS = append(c.SPS, it)
+		case AVCNALPPS:
+			c.PPS = append(c.PPS, it)
+		}
+	}
+	if len(c.SPS) == 0 || len(c.PPS) == 0 {
+		return nil, errors.New("codec: parameter sets not found")
+	}
+	if err := c.parseSPS(c.SPS[0]); err != nil {
+		return nil, err
+	}
+	c.Profile, c.Compatibility, c.Level = c.SPS[0][1], c.SPS[0][2], c.SPS[0][3]
+	return c, nil
+}
+
+// Bytes returns the avcC configuration record.
+func (c *AVCConfig) Bytes() []byte {
+	b := []byte{1, c.Profile, c.Compatibility, c.Level, 0xfc | byte(c.LengthSize-1), 0xe0 | byte(len(c.SPS))}
+	for _, it := range c.SPS {
+		b = AppendNALUs(b, 2, it)
+	}
+	b = append(b, byte(len(c.PPS)))
+	for _, it := range c.PPS {
+		b = AppendNALUs(b, 2, it)
+	}
+	switch c.Profile {
+	case 100, 110, 122, 144:
+		b = append(b, 0xfc|byte(c.ChromaFormat), 0xf8|byte(c.BitDepth-8), 0xf8|byte(c.BitDepth-8), 0)
+	}
+	return b
+}
+
+func (c *AVCConfig) parseSPS(b []byte) error {
+	if len(b) < 4 {
+		return errShortConfig
+	}
+	r := &bitReader{b: unescapeRBSP(b[4:])}
+	r.ue() // seq_parameter_set_id
+	c.ChromaFormat, c.BitDepth = 1, 8
+	switch b[1] {
+	case 100, 110, 122, 244, 44, 83, 86, 118, 128, 138, 139, 134, 135:
+		c.ChromaFormat = int(r.ue())
+		if c.ChromaFormat == 3 {
+			r.skip(1) // separate_colour_plane_flag
+		}
+		c.BitDepth = int(r.ue()) + 8
+		r.ue()        // bit_depth_chroma_minus8
+		r.skip(1)     // qpprime_y_zero_transform_bypass_flag
+		if r.flag() { // seq_scaling_matrix_present_flag
+			n := 8
+			if c.ChromaFormat == 3 {
+				n = 12
+			}
+			for i := 0; i < n; i++ {
+				if !r.flag() {
+					continue
+				}
+				size := 16
+				if i >= 6 {
+					size = 64
+				}
+				for j, last, next := 0, int64(8), int64(8); j < size; j++ {
+					if next != 0 {
+						next = (last + r.se() + 256) % 256
+					}
+					if next != 0 {
+						last = next
+					}
+				}
+			}
+		}
+	}
+	r.ue() // log2_max_frame_num_minus4
+	switch r.ue() {
+	case 0:
+		r.ue() // log2_max_pic_order_cnt_lsb_minus4
+	case 1:
+		r.skip(1) // delta_pic_order_always_zero_flag
+		r.se()
+		r.se()
+		for n := r.ue(); n > 0 && !r.eof; n-- {
+			r.se()
+		}
+	}
+	r.ue()    // max_num_ref_frames
+	r.skip(1) // gaps_in_frame_num_value_allowed_flag
+	w := int(r.ue()+1) * 16
+	h := int(r.ue()+1) * 16
+	frameMbsOnly := r.flag()
+	if !frameMbsOnly {
+		h *= 2
+		r.skip(1) // mb_adaptive_frame_field_flag
+	}
+	r.skip(1) // direct_8x8_inference_flag
+	if r.flag() {
+		cx, cy := 1, 1
+		if c.ChromaFormat == 1 || c.ChromaFormat == 2 {
+			cx = 2
+		}
+		if c.ChromaFormat == 1 {
+			cy = 2
+		}
+		if !frameMbsOnly {
+			cy *= 2
+		}
+		w -= cx * int(r.ue()+r.ue())
+		h -= cy * int(r.ue()+r.ue())
+	}
+	if r.eof {
+		return errShortConfig
+	}
+	c.Width, c.Height = w, h
+	return nil
+}
@@ -0,0 +1,156 @@
+// Package codec parses codec initialization data (CodecPrivate) of Matroska tracks.
+package codec
+
+import "errors"
+
+var (
+	errShortConfig   = errors.New("codec: configuration is too short")
+	errInvalidConfig = errors.New("codec: invalid configuration")
+)
+
+// SplitXiph splits packets joined with Xiph lacing, as used by CodecPrivate of A_VORBIS and V_THEORA tracks.
+func SplitXiph(b []byte) ([][]byte, error) {
+	if len(b) == 0 {
+		return nil, errShortConfig
+	}
+	n := int(b[0]) + 1
+	b = b[1:]
+	sizes := make([]int, n)
+	for i := 0; i < n-1; i++ {
+		for {
+			if len(b) == 0 {
+				return nil, errShortConfig
+			}
+			v := b[0]
+			b = b[1:]
+			sizes[i] += int(v)
+			if v < 255 {
+				break
+			}
+		}
+	}
+	list := make([][]byte, n)
+	for i := 0; i < n-1; i++ {
+		if sizes[i] > len(b) {
+			return nil, errShortConfig
+		}
+		list[i], b = b[:sizes[i]], b[sizes[i]:]
+	}
+	list[n-1] = b
+	return list, nil
+}
+
+// JoinXiph joins packets with Xiph lacing.
+func JoinXiph(list [][]byte) []byte {
+	if len(list) == 0 {
+		return nil
+	}
+	b := []byte{byte(len(list) - 1)}
+	for _, it := range list[:len(list)-1] {
+		n := len(it)
+		for ; n >= 255; n -= 255 {
+			b = append(b, 255)
+		}
+		b = append(b, byte(n))
+	}
+	for _, it := range list {
+		b = append(b, it...)
+	}
+	return b
+}
+
+// bitReader reads big-endian bit fields and Exp-Golomb codes.
+type bitReader struct {
+	b   []byte
+	off int
+	eof bool
+}
+
+func (r *bitReader) read(n int) uint64 {
+	var v uint64
+	for i := 0; i < n; i++ {
+		if r.off >= len(r.b)*8 {
+			r.eof = true
+			return v
+		}
+		v = v<<1 | uint64(r.b[r.off>>3]>>(7-uint(r.off&7))&1)
+		r.off++
+	}
+	return v
+}
+
+func (r *bitReader) flag() bool {
+	return r.read(1) != 0
+}
+
+func (r *bitReader) skip(n int) {
+	r.off += n
+	if r.off > len(r.b)*8 {
+		r.eof = true
+	}
+}
+
+// ue reads the unsigned Exp-Golomb code.
+func (r *bitReader) ue() uint64 {
+	n := 0
+	for !r.flag() {
+		if n++; n > 32 || r.eof {
+			r.eof = true
+			return 0
+		}
+	}
+	return 1<<uint(n) - 1 + r.read(n)
+}
+
+// se reads the signed Exp-Golomb code.
+func (r *bitReader) se() int64 {
+	v := r.ue()
+	if v&1 != 0 {
+		return int64(v+1) / 2
+	}
+	return -int64(v / 2)
+}
+
+// unescapeRBSP removes emulation prevention bytes from the NAL unit payload.
+func unescapeRBSP(b []byte) []byte {
+	var out []byte
+	zeros := 0
+	for i, v := range b {
+		if zeros >= 2 && v == 3 {
+			if out == nil {
+				out = append(make([]byte, 0, len(b)), b[:i]...)
+			}
+			zeros = 0
+			continue
+		}
+		if v == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+		if out != nil {
+			out = append(out, v)
+		}
+	}
+	if out == nil {
+		return b
+	}
+	return out
+}
+
+// readNALUs reads 16-bit length-prefixed NAL units.
+func readNALUs(b []byte, n int) ([][]byte, []byte, error) {
+	list := make([][]byte, 0, n)
+	for i := 0; i < n; i++ {
+		if len(b) < 2 {
+			return nil, nil, errShortConfig
+		}
+		size := int(b[0])<<8 | int(b[1])
+		if len(b) < 2+size {
+			return nil, nil, errShortConfig
+		}
+		list = append(list, b[2:2+size])
+		b = b[2+size:]
+	}
+	return list, b, nil
+}
@@ -0,0 +1,210 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+func TestXiph(t *testing.T) {
+	list := [][]byte{bytes.Repeat([]byte{1}, 300), {2, 2}, bytes.Repeat([]byte{3}, 255), {}}
+	b := JoinXiph(list)
+	if len(b) != 1+2+1+2+300+2+255 {
+		t.Fatalf("Unexpected size: %d", len(b))
+	}
+	res, err := SplitXiph(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 4 || !bytes.Equal(res[0], list[0]) || !bytes.Equal(res[1], list[1]) || !bytes.Equal(res[2], list[2]) || len(res[3]) != 0 {
+		t.Errorf("Unexpected packets: %v", res)
+	}
+}
+
+func TestParseAVCConfig(t *testing.T) {
+	sps := &bitWriter{b: []byte{0x67, 66, 0xc0, 40}}
+	sps.ue(0).ue(0).ue(0).ue(0).ue(1).bits(0, 1).ue(119).ue(67).bits(1, 1).bits(1, 1)
+	sps.bits(1, 1).ue(0).ue(0).ue(0).ue(4).bits(0, 1).bits(1, 1)
+	pps := []byte{0x68, 0xce, 0x3c, 0x80}
+	b := []byte{1, 66, 0xc0, 40, 0xff, 0xe1}
+	b = AppendNALUs(b, 2, sps.b)
+	b = AppendNALUs(append(b, 1), 2, pps)
+	c, err := ParseAVCConfig(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &AVCConfig{
+		Profile:       66,
+		Compatibility: 0xc0,
+		Level:         40,
+		LengthSize:    4,
+		SPS:           [][]byte{sps.b},
+		PPS:           [][]byte{pps},
+		ChromaFormat:  1,
+		BitDepth:      8,
+		Width:         1920,
+		Height:        1080,
+	}
+	if !reflect.DeepEqual(c, want) {
+		t.Errorf("Unexpected config: %+v", c)
+	}
+}
+
+func TestParseHEVCConfig(t *testing.T) {
+	sps := &bitWriter{b: []byte{0x42, 0x01}}
+	sps.bits(0, 4).bits(0, 3).bits(1, 1).bits(0x01, 8).bits(0x60000000, 32).bits(0x90, 8).bits(0, 40).bits(93, 8)
+	sps.ue(0).ue(1).ue(1920).ue(1088).bits(1, 1).ue(0).ue(0).ue(0).ue(4).ue(0).ue(0)
+	b := []byte{1, 0x01, 0x60, 0, 0, 0, 0x90, 0, 0, 0, 0, 0, 93, 0xf0, 0, 0xfc, 0xfd, 0xf8, 0xf8, 0, 0, 0x0f, 1, 0xa0 | HEVCNALSPS, 0, 1}
+	b = AppendNALUs(b, 2, sps.b)
+	c, err := ParseHEVCConfig(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Profile != 1 || c.Compatibility != 0x60000000 || c.Constraints != 0x900000000000 || c.Level != 93 ||
+		c.ChromaFormat != 1 || c.BitDepthLuma != 8 || c.LengthSize != 4 || len(c.SPS) != 1 || c.Width != 1920 || c.Height != 1080 {
+		t.Errorf("Unexpected config: %+v", c)
+	}
+}
+
+func TestParseAV1Config(t *testing.T) {
+	seq := &bitWriter{}
+	seq.bits(0, 3).bits(0, 1).bits(0, 1).bits(0, 1).bits(0, 1).bits(0, 5).bits(0, 12).bits(8, 5).bits(0, 1)
+	seq.bits(10, 4).bits(10, 4).bits(1279, 11).bits(719, 11)
+	seq.bits(0, 1).bits(0, 3).bits(0, 4).bits(1, 1).bits(0, 2).bits(1, 1).bits(1, 1).bits(0, 3).bits(0, 3)
+	seq.bits(0, 1).bits(0, 1).bits(0, 1).bits(0, 1).bits(0, 2).bits(1, 1)
+	b := append([]byte{0x81, 0x08, 0x0c, 0, AV1OBUSequenceHeader<<3 | 0x02, byte(len(seq.b))}, seq.b...)
+	c, err := ParseAV1Config(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Profile != 0 || c.Level != 8 || c.BitDepth != 8 || !c.ChromaSubsamplingX || !c.ChromaSubsamplingY || c.Width != 1280 || c.Height != 720 {
+		t.Errorf("Unexpected config: %+v", c)
+	}
+	tu := append([]byte{AV1OBUTemporalDelimiter<<3 | 0x02, 0}, b[4:]...)
+	tu = append(tu, AV1OBUFrame<<3|0x02, 2, 0x10, 0)
+	if c, err = AV1ConfigFromOBUs(tu); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(c.Bytes(), b) {
+		t.Errorf("Unexpected config record: %x, want: %x", c.Bytes(), b)
+	}
+	if !AV1Keyframe(tu) || !bytes.Equal(AV1TrimTemporalDelimiters(tu), tu[2:]) {
+		t.Errorf("Unexpected temporal unit")
+	}
+}
+
+func TestParseAACConfig(t *testing.T) {
+	for _, it := range []struct {
+		b    []byte
+		want AACConfig
+	}{
+		{[]byte{0x12, 0x10}, AACConfig{ObjectType: AACLC, SampleRate: 44100, Channels: 2}},
+		{[]byte{0x11, 0x90}, AACConfig{ObjectType: AACLC, SampleRate: 48000, Channels: 2}},
+		{[]byte{0x2b, 0x92, 0x08, 0x00}, AACConfig{ObjectType: AACLC, SampleRate: 22050, Channels: 2, ExtensionObjectType: AACSBR, ExtensionSampleRate: 44100}},
+	} {
+		c, err := ParseAACConfig(it.b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *c != it.want {
+			t.Errorf("Unexpected config: %+v, want: %+v", c, it.want)
+		}
+		if it.want.ExtensionObjectType == 0 && !bytes.Equal(c.Bytes(), it.b) {
+			t.Errorf("Unexpected bytes: %x", c.Bytes())
+		}
+	}
+}
+
+func TestParseOpusConfig(t *testing.T) {
+	want := &OpusConfig{Version: 1, Channels: 6, PreSkip: 312, SampleRate: 48000, MappingFamily: 1, StreamCount: 4, CoupledCount: 2, Mapping: []byte{0, 4, 1, 2, 3, 5}}
+	c, err := ParseOpusConfig(want.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(c, want) {
+		t.Errorf("Unexpected config: %+v", c)
+	}
+}
+
+func TestParseVorbisConfig(t *testing.T) {
+	id := make([]byte, 30)
+	copy(id, "\x01vorbis")
+	id[11] = 2
+	binary.LittleEndian.PutUint32(id[12:], 44100)
+	binary.LittleEndian.PutUint32(id[20:], 128000)
+	id[28], id[29] = 0xb8, 1
+	// Setup header is written backwards: framing bit, two modes and the number of modes
+	w := (&bitWriter{}).bits(0, 3).bits(1, 1)
+	w.bits(0, 40).bits(1, 1).bits(0, 40).bits(0, 1).bits(1, 6)
+	w.bits(0xff, 7).bits(0xffffffffffffffff, 64).bits(0xffffffffffffffff, 64)
+	setup := []byte("\x05vorbis")
+	for i := len(w.b) - 1; i >= 0; i-- {
+		setup = append(setup, w.b[i])
+	}
+	c, err := ParseVorbisConfig(JoinXiph([][]byte{id, []byte("\x03vorbis"), setup}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Channels != 2 || c.SampleRate != 44100 || c.BitrateNominal != 128000 || c.BlockSizes != [2]int{256, 2048} || len(c.Headers) != 3 {
+		t.Errorf("Unexpected config: %+v", c)
+	}
+	if len(c.Modes) != 2 || c.BlockSize([]byte{0}) != 256 || c.BlockSize([]byte{2}) != 2048 || c.BlockSize([]byte{1}) != 0 {
+		t.Errorf("Unexpected modes: %v", c.Modes)
+	}
+}
+
+type bitWriter struct {
+	b []byte
+	n int
+}
+
+func (w *bitWriter) bits(v uint64, n int) *bitWriter {
+	for i := n - 1; i >= 0; i-- {
+		if w.n%8 == 0 {
+			w.b = append(w.b, 0)
+		}
+		w.b[len(w.b)-1] |= byte(v>>uint(i)&1) << uint(7-w.n%8)
+		w.n++
+	}
+	return w
+}
+
+func (w *bitWriter) ue(v uint64) *bitWriter {
+	n := 0
+	for x := v + 1; x > 1; x >>= 1 {
+		n++
+	}
+	return w.bits(0, n).bits(v+1, n+1)
+}
+
+func TestAnnexB(t *testing.T) {
+	sps := []byte{0x67, 0x42, 0xc0, 0x1e, 0xf4, 0x0a, 0x0f, 0xc8}
+	pps := []byte{0x68, 0xce, 0x3c, 0x80}
+	b := append([]byte{0, 0, 1, 0x09, 0xf0, 0}, AppendAnnexB(nil, sps, pps)...)
+	list := SplitAnnexB(b)
+	if len(list) != 3 || !bytes.Equal(list[1], sps) || !bytes.Equal(list[2], pps) {
+		t.Fatalf("Unexpected NAL units: %x", list)
+	}
+	c, err := AVCConfigFromAnnexB(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Profile != 66 || c.Level != 30 || c.Width != 320 || c.Height != 240 {
+		t.Errorf("Unexpected config: %+v", c)
+	}
+	res, err := ParseAVCConfig(c.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(res, c) {
+		t.Errorf("Unexpected config: %+v, want: %+v", res, c)
+	}
+	nalus, err := SplitNALUs(AppendNALUs(nil, 4, sps, pps), 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(nalus, [][]byte{sps, pps}) {
+		t.Errorf("Unexpected NAL units: %x", nalus)
+	}
+}
@@ -0,0 +1,185 @@
+package codec
+
+import "errors"
+
+// HEVC NAL unit types
+const (
+	HEVCNALIDRWRADL = 19
+	HEVCNALIDRNLP   = 20
+	HEVCNALCRA      = 21
+	HEVCNALVPS      = 32
+	HEVCNALSPS      = 33
+	HEVCNALPPS      = 34
+	HEVCNALAUD      = 35
+)
+
+// HEVCConfig is the HEVCDecoderConfigurationRecord of V_MPEGH/ISO/HEVC tracks.
+// See ISO/IEC 14496-15.
+type HEVCConfig struct {
+	ProfileSpace   uint8
+	Tier           uint8
+	Profile        uint8
+	Compatibility  uint32
+	Constraints    uint64 // 48 bits of general constraint indicator flags
+	Level          uint8
+	ChromaFormat   int
+	BitDepthLuma   int
+	BitDepthChroma int
+	LengthSize     int // Size of NAL unit length prefixes
+	VPS            [][]byte
+	SPS            [][]byte
+	PPS            [][]byte
+	// Parsed from the first SPS
+	Width  int
+	Height int
+}
+
+// ParseHEVCConfig parses the hvcC configuration record.
+func ParseHEVCConfig(b []byte) (*HEVCConfig, error) {
+	if len(b) < 23 {
+		return nil, errShortConfig
+	}
+	if b[0] != 1 {
+		return nil, errInvalidConfig
+	}
+	c := &HEVCConfig{
+		ProfileSpace:   b[1] >> 6,
+		Tier:           b[1] >> 5 & 1,
+		Profile:        b[1] & 0x1f,
+		Compatibility:  uint32(b[2])<<24 | uint32(b[3])<<16 | uint32(b[4])<<8 | uint32(b[5]),
+		Level:          b[12],
+		ChromaFormat:   int(b[16] & 3),
+		BitDepthLuma:   int(b[17]&7) + 8,
+		BitDepthChroma: int(b[18]&7) + 8,
+		LengthSize:     int(b[21]&3) + 1,
+	}
+	for _, v := range b[6:12] {
+		c.Constraints = c.Constraints<<8 | uint64(v)
+	}
+	n := int(b[22])
+	b = b[23:]
+	for i := 0; i < n; i++ {
+		if len(b) < 3 {
+			return nil, errShortConfig
+		}
+		typ := b[0] & 0x3f
+		list, rest, err := readNALUs(b[3:], int(b[1])<<8|int(b[2]))
+		if err != nil {
+			return nil, err
+		}
+		switch typ {
+		case HEVCNALVPS:
+			c.VPS = append(c.VPS, list...)
+		case HEVCNALSPS:
+			c.SPS = append(c.SPS, list...)
+		case HEVCNALPPS:
+			c.PPS = append(c.PPS, list...)
+		}
+		b = rest
+	}
+	if len(c.SPS) > 0 {
+		if err := c.parseSPS(c.SPS[0]); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// HEVCConfigFromAnnexB returns the configuration record with parameter sets found in the Annex B byte stream.
+func HEVCConfigFromAnnexB(b []byte) (*HEVCConfig, error) {
+	c := &HEVCConfig{LengthSize: 4}
+	for _, it := range SplitAnnexB(b) {
+		switch it[0] >> 1 & 0x3f {
+		case HEVCNALVPS:
+			c.VPS = append(c.VPS, it)
+		case HEVCNALSPS:
+			c.SPS = append(c.SPS, it)
+		case HEVCNALPPS:
+			c.PPS = append(c.PPS, it)
+		}
+	}
+	if len(c.VPS) == 0 || len(c.SPS) == 0 || len(c.PPS) == 0 {
+		return nil, errors.New("codec: parameter sets not found")
+	}
+	if err := c.parseSPS(c.SPS[0]); err != nil {
+		return nil, err
+	}
+	// General profile, tier and level are byte aligned in the SPS
+	p := unescapeRBSP(c.SPS[0][2:])[1:13]
+	c.ProfileSpace, c.Tier, c.Profile = p[0]>>6, p[0]>>5&1, p[0]&0x1f
+	c.Compatibility = uint32(p[1])<<24 | uint32(p[2])<<16 | uint32(p[3])<<8 | uint32(p[4])
+	for _, v := range p[5:11] {
+		c.Constraints = c.Constraints<<8 | uint64(v)
+	}
+	c.Level = p[11]
+	return c, nil
+}
+
+// Bytes returns the hvcC configuration record.
+func (c *HEVCConfig) Bytes() []byte {
+	b := []byte{1, c.ProfileSpace<<6 | c.Tier<<5 | c.Profile,
+		byte(c.Compatibility >> 24), byte(c.Compatibility >> 16), byte(c.Compatibility >> 8), byte(c.Compatibility)}
+	for i := 5; i >= 0; i-- {
+		b = append(b, byte(c.Constraints>>uint(8*i)))
+	}
+	b = append(b, c.Level, 0xf0, 0, 0xfc, 0xfc|byte(c.ChromaFormat), 0xf8|byte(c.BitDepthLuma-8), 0xf8|byte(c.BitDepthChroma-8), 0, 0,
+		0x0c|byte(c.LengthSize-1), 3)
+	for i, list := range [][][]byte{c.VPS, c.SPS, c.PPS} {
+		b = append(b, 0x80|byte(HEVCNALVPS+i), byte(len(list)>>8), byte(len(list)))
+		for _, it := range list {
+			b = AppendNALUs(b, 2, it)
+		}
+	}
+	return b
+}
+
+func (c *HEVCConfig) parseSPS(b []byte) error {
+	if len(b) < 3 {
+		return errShortConfig
+	}
+	r := &bitReader{b: unescapeRBSP(b[2:])}
+	r.skip(4) // sps_video_parameter_set_id
+	layers := int(r.read(3))
+	r.skip(1)  // sps_temporal_id_nesting_flag
+	r.skip(96) // general profile, tier and level
+	profile := make([]bool, layers)
+	level := make([]bool, layers)
+	for i := 0; i < layers; i++ {
+		profile[i], level[i] = r.flag(), r.flag()
+	}
+	if layers > 0 {
+		r.skip(2 * (8 - layers))
+	}
+	for i := 0; i < layers; i++ {
+		if profile[i] {
+			r.skip(88)
+		}
+		if level[i] {
+			r.skip(8)
+		}
+	}
+	r.ue() // sps_seq_parameter_set_id
+	chroma := int(r.ue())
+	if chroma == 3 {
+		r.skip(1) // separate_colour_plane_flag
+	}
+	w, h := int(r.ue()), int(r.ue())
+	if r.flag() { // conformance_window_flag
+		cx, cy := 1, 1
+		if chroma == 1 || chroma == 2 {
+			cx = 2
+		}
+		if chroma == 1 {
+			cy = 2
+		}
+		w -= cx * int(r.ue()+r.ue())
+		h -= cy * int(r.ue()+r.ue())
+	}
+	luma, depth := int(r.ue())+8, int(r.ue())+8
+	if r.eof {
+		return errShortConfig
+	}
+	c.Width, c.Height = w, h
+	c.ChromaFormat, c.BitDepthLuma, c.BitDepthChroma = chroma, luma, depth
+	return nil
+}
@@ -0,0 +1,81 @@
+package codec
+
+import "errors"
+
+var errNALULength = errors.New("codec: invalid NAL unit length")
+
+// SplitNALUs splits length-prefixed NAL units, as stored in AVC and HEVC frames.
+func SplitNALUs(b []byte, lengthSize int) ([][]byte, error) {
+	var list [][]byte
+	for len(b) > 0 {
+		if len(b) < lengthSize {
+			return nil, errNALULength
+		}
+		n := 0
+		for _, v := range b[:lengthSize] {
+			n = n<<8 | int(v)
+		}
+		b = b[lengthSize:]
+		if n > len(b) {
+			return nil, errNALULength
+		}
+		list = append(list, b[:n])
+		b = b[n:]
+	}
+	return list, nil
+}
+
+// AppendNALUs appends NAL units with length prefixes of the given size to dst.
+func AppendNALUs(dst []byte, lengthSize int, list ...[]byte) []byte {
+	for _, it := range list {
+		for i := lengthSize - 1; i >= 0; i-- {
+			dst = append(dst, byte(len(it)>>uint(8*i)))
+		}
+		dst = append(dst, it...)
+	}
+	return dst
+}
+
+// SplitAnnexB splits the Annex B byte stream into NAL units.
+func SplitAnnexB(b []byte) [][]byte {
+	var list [][]byte
+	start := -1
+	for i := 0; i+2 < len(b); {
+		if b[i+2] > 1 {
+			i += 3
+			continue
+		}
+		if b[i] != 0 || b[i+1] != 0 || b[i+2] != 1 {
+			i++
+			continue
+		}
+		if start >= 0 {
+			list = appendNALU(list, b[start:i])
+		}
+		i += 3
+		start = i
+	}
+	if start >= 0 {
+		list = appendNALU(list, b[start:])
+	}
+	return list
+}
+
+func appendNALU(list [][]byte, b []byte) [][]byte {
+	for len(b) > 0 && b[len(b)-1] == 0 {
+		b = b[:len(b)-1]
+	}
+	if len(b) == 0 {
+		return list
+	}
+	return append(list, b)
+}
+
+// AppendAnnexB appends NAL units with start codes to dst.
+func AppendAnnexB(dst []byte, list ...[]byte) []byte {
+	for _, it := range list {
+		dst = append(dst, 0, 0, 0, 1)
+		dst = append(dst, it...)
+	}
+	return dst
+}
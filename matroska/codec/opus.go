@@ -0,0 +1,99 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"time"
+)
+
+// OpusConfig is the OpusHead identification header of A_OPUS tracks.
+// See RFC 7845.
+type OpusConfig struct {
+	Version       uint8
+	Channels      int
+	PreSkip       int // Number of samples at 48 kHz to discard
+	SampleRate    int // Sample rate of the original input
+	OutputGain    int16
+	MappingFamily uint8
+	StreamCount   int
+	CoupledCount  int
+	Mapping       []byte
+}
+
+// ParseOpusConfig parses the OpusHead header.
+func ParseOpusConfig(b []byte) (*OpusConfig, error) {
+	if len(b) < 19 {
+		return nil, errShortConfig
+	}
+	if !bytes.HasPrefix(b, []byte("OpusHead")) {
+		return nil, errInvalidConfig
+	}
+	c := &OpusConfig{
+		Version:       b[8],
+		Channels:      int(b[9]),
+		PreSkip:       int(binary.LittleEndian.Uint16(b[10:])),
+		SampleRate:    int(binary.LittleEndian.Uint32(b[12:])),
+		OutputGain:    int16(binary.LittleEndian.Uint16(b[16:])),
+		MappingFamily: b[18],
+		StreamCount:   1,
+	}
+	if c.Channels > 1 {
+		c.CoupledCount = 1
+	}
+	if c.MappingFamily != 0 {
+		if len(b) < 21+c.Channels {
+			return nil, errShortConfig
+		}
+		c.StreamCount, c.CoupledCount = int(b[19]), int(b[20])
+		c.Mapping = b[21 : 21+c.Channels]
+	}
+	return c, nil
+}
+
+// Bytes returns the OpusHead header.
+func (c *OpusConfig) Bytes() []byte {
+	b := make([]byte, 19, 21+len(c.Mapping))
+	copy(b, "OpusHead")
+	b[8] = c.Version
+	if b[8] == 0 {
+		b[8] = 1
+	}
+	b[9] = byte(c.Channels)
+	binary.LittleEndian.PutUint16(b[10:], uint16(c.PreSkip))
+	binary.LittleEndian.PutUint32(b[12:], uint32(c.SampleRate))
+	binary.LittleEndian.PutUint16(b[16:], uint16(c.OutputGain))
+	b[18] = c.MappingFamily
+	if c.MappingFamily != 0 {
+		b = append(b, byte(c.StreamCount), byte(c.CoupledCount))
+		b = append(b, c.Mapping...)
+	}
+	return b
+}
+
+// OpusPacketDuration returns the duration of the Opus packet from its TOC byte.
+// See RFC 6716.
+func OpusPacketDuration(b []byte) time.Duration {
+	if len(b) == 0 {
+		return 0
+	}
+	var frame time.Duration
+	switch cfg := b[0] >> 3; {
+	case cfg < 12:
+		frame = []time.Duration{10, 20, 40, 60}[cfg&3] * time.Millisecond
+	case cfg < 16:
+		frame = []time.Duration{10, 20}[cfg&1] * time.Millisecond
+	default:
+		frame = 2500 * time.Microsecond << (cfg & 3)
+	}
+	switch b[0] & 3 {
+	case 0:
+		return frame
+	case 1, 2:
+		return 2 * frame
+	default:
+		if len(b) < 2 {
+			return 0
+		}
+		return time.Duration(b[1]&0x3f) * frame
+	}
+}
@@ -0,0 +1,113 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// VorbisConfig contains Vorbis headers of A_VORBIS tracks.
+// See https://xiph.org/vorbis/doc/Vorbis_I_spec.html
+type VorbisConfig struct {
+	Headers        [][]byte // Identification, comment and setup headers
+	Version        uint32
+	Channels       int
+	SampleRate     int
+	BitrateMax     int
+	BitrateNominal int
+	BitrateMin     int
+	BlockSizes     [2]int
+	Modes          []bool // Block flags of modes, nil if the setup header is not parsed
+}
+
+// ParseVorbisConfig parses Xiph-laced Vorbis headers.
+func ParseVorbisConfig(b []byte) (*VorbisConfig, error) {
+	list, err := SplitXiph(b)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) != 3 {
+		return nil, errInvalidConfig
+	}
+	id := list[0]
+	if len(id) < 30 {
+		return nil, errShortConfig
+	}
+	if id[0] != 1 || !bytes.Equal(id[1:7], []byte("vorbis")) {
+		return nil, errInvalidConfig
+	}
+	c := &VorbisConfig{
+		Headers:        list,
+		Version:        binary.LittleEndian.Uint32(id[7:]),
+		Channels:       int(id[11]),
+		SampleRate:     int(binary.LittleEndian.Uint32(id[12:])),
+		BitrateMax:     int(int32(binary.LittleEndian.Uint32(id[16:]))),
+		BitrateNominal: int(int32(binary.LittleEndian.Uint32(id[20:]))),
+		BitrateMin:     int(int32(binary.LittleEndian.Uint32(id[24:]))),
+		BlockSizes:     [2]int{1 << (id[28] & 0xf), 1 << (id[28] >> 4)},
+	}
+	c.Modes = parseVorbisModes(list[2])
+	return c, nil
+}
+
+// Bytes returns the Xiph-laced headers.
+func (c *VorbisConfig) Bytes() []byte {
+	return JoinXiph(c.Headers)
+}
+
+// BlockSize returns the block size of the audio packet, or zero if it is not known.
+// The packet decodes to a quarter of the sum of its and the previous block sizes.
+func (c *VorbisConfig) BlockSize(b []byte) int {
+	if len(c.Modes) == 0 || len(b) == 0 || b[0]&1 != 0 {
+		return 0
+	}
+	n := uint(0)
+	for 1<<n < len(c.Modes) {
+		n++
+	}
+	mode := int(b[0]>>1) & (1<<n - 1)
+	if mode >= len(c.Modes) {
+		return 0
+	}
+	if c.Modes[mode] {
+		return c.BlockSizes[1]
+	}
+	return c.BlockSizes[0]
+}
+
+// parseVorbisModes returns block flags of modes at the end of the setup header.
+// Codebooks and floors are not parsed, the header is read backwards from the framing bit instead.
+func parseVorbisModes(b []byte) []bool {
+	rev := make([]byte, len(b))
+	for i, v := range b {
+		rev[len(b)-1-i] = v
+	}
+	r := &bitReader{b: rev}
+	for !r.flag() {
+		if r.eof {
+			return nil
+		}
+	}
+	start, count, last := r.off, 0, 0
+	// Each mode is a block flag, zero window and transform types and a mapping number below 64
+	for count < 64 && len(rev)*8-r.off >= 97 {
+		if r.read(8) > 63 || r.read(16) != 0 || r.read(16) != 0 {
+			break
+		}
+		r.skip(1)
+		count++
+		if int(r.read(6))+1 == count {
+			last = count
+		}
+		r.off -= 6
+	}
+	if last == 0 {
+		return nil
+	}
+	r.off = start
+	modes := make([]bool, last)
+	for i := last - 1; i >= 0; i-- {
+		r.skip(40)
+		modes[i] = r.flag()
+	}
+	return modes
+}
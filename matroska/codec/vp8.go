@@ -0,0 +1,7 @@
+package codec
+
+// VP8Keyframe reports whether the VP8 frame is a key frame.
+// See RFC 6386, section 9.1.
+func VP8Keyframe(b []byte) bool {
+	return len(b) > 0 && b[0]&1 == 0
+}
@@ -0,0 +1,53 @@
+package codec
+
+// VP9Config contains VP9 codec features of V_VP9 tracks.
+// See https://www.webmproject.org/docs/container/#vp9-codec-feature-metadata-codecprivate
+type VP9Config struct {
+	Profile           int
+	Level             int // Zero if not known
+	BitDepth          int
+	ChromaSubsampling int // 0 for 4:2:0 vertical, 1 for 4:2:0 colocated, 2 for 4:2:2, 3 for 4:4:4
+}
+
+// ParseVP9Config parses VP9 codec features.
+func ParseVP9Config(b []byte) (*VP9Config, error) {
+	c := &VP9Config{BitDepth: 8, ChromaSubsampling: 1}
+	for len(b) > 0 {
+		if len(b) < 2 || len(b) < 2+int(b[1]) {
+			return nil, errShortConfig
+		}
+		id, v := b[0], b[2:2+int(b[1])]
+		b = b[2+len(v):]
+		if len(v) != 1 {
+			continue
+		}
+		switch id {
+		case 1:
+			c.Profile = int(v[0])
+		case 2:
+			c.Level = int(v[0])
+		case 3:
+			c.BitDepth = int(v[0])
+		case 4:
+			c.ChromaSubsampling = int(v[0])
+		}
+	}
+	return c, nil
+}
+
+// VP9Keyframe reports whether the VP9 frame is a key frame.
+// Only the first frame of a superframe is checked.
+func VP9Keyframe(b []byte) bool {
+	r := &bitReader{b: b}
+	if r.read(2) != 2 { // frame_marker
+		return false
+	}
+	profile := r.read(1) | r.read(1)<<1
+	if profile == 3 {
+		r.skip(1)
+	}
+	if r.flag() { // show_existing_frame
+		return false
+	}
+	return !r.flag() && !r.eof
+}
@@ -0,0 +1,180 @@
+package matroska
+
+import (
+	"errors"
+	"fmt"
+	"github.com/pixelbender/go-matroska/matroska/codec"
+)
+
+// Codec IDs
+const (
+	CodecAVC    = "V_MPEG4/ISO/AVC"
+	CodecHEVC   = "V_MPEGH/ISO/HEVC"
+	CodecAV1    = "V_AV1"
+	CodecVP8    = "V_VP8"
+	CodecVP9    = "V_VP9"
+	CodecAAC    = "A_AAC"
+	CodecOpus   = "A_OPUS"
+	CodecVorbis = "A_VORBIS"
+	CodecFLAC   = "A_FLAC"
+	CodecAC3    = "A_AC3"
+	CodecEAC3   = "A_EAC3"
+	CodecMP3    = "A_MPEG/L3"
+	CodecPCM    = "A_PCM/INT/LIT"
+	CodecPCMBE  = "A_PCM/INT/BIG"
+	CodecFloat  = "A_PCM/FLOAT/IEEE"
+)
+
+// CodecConfig parses CodecPrivate of the track.
+// Returns *codec.AVCConfig, *codec.HEVCConfig, *codec.AV1Config, *codec.AACConfig,
+// *codec.OpusConfig, *codec.VorbisConfig or *codec.VP9Config depending on CodecID.
+func (t *TrackEntry) CodecConfig() (interface{}, error) {
+	if t.CodecID == CodecVP9 {
+		return codec.ParseVP9Config(t.CodecPrivate)
+	}
+	if len(t.CodecPrivate) == 0 {
+		return nil, errors.New("matroska: no codec private data")
+	}
+	switch t.CodecID {
+	case CodecAVC:
+		return codec.ParseAVCConfig(t.CodecPrivate)
+	case CodecHEVC:
+		return codec.ParseHEVCConfig(t.CodecPrivate)
+	case CodecAV1:
+		return codec.ParseAV1Config(t.CodecPrivate)
+	case CodecAAC:
+		return codec.ParseAACConfig(t.CodecPrivate)
+	case CodecOpus:
+		return codec.ParseOpusConfig(t.CodecPrivate)
+	case CodecVorbis:
+		return codec.ParseVorbisConfig(t.CodecPrivate)
+	default:
+		return nil, errors.New("matroska: unsupported codec " + t.CodecID)
+	}
+}
+
+var aacProfiles = map[string]int{
+	"A_AAC/MPEG2/MAIN":   codec.AACMain,
+	"A_AAC/MPEG2/LC":     codec.AACLC,
+	"A_AAC/MPEG2/LC/SBR": codec.AACSBR,
+	"A_AAC/MPEG2/SSR":    codec.AACSSR,
+	"A_AAC/MPEG4/MAIN":   codec.AACMain,
+	"A_AAC/MPEG4/LC":     codec.AACLC,
+	"A_AAC/MPEG4/LC/SBR": codec.AACSBR,
+	"A_AAC/MPEG4/SSR":    codec.AACSSR,
+	"A_AAC/MPEG4/LTP":    codec.AACLTP,
+}
+
+// CodecString returns the codec parameter of the track as defined in RFC 6381,
+// like "avc1.64001f", "vp09.00.10.08", "av01.0.04M.08", "opus" or "mp4a.40.2".
+// The extended form of VP9 and AV1 strings is used if the track contains colour metadata.
+func (t *TrackEntry) CodecString() (string, error) {
+	switch t.CodecID {
+	case CodecVP8:
+		return "vp8", nil
+	case CodecOpus:
+		return "opus", nil
+	case CodecVorbis:
+		return "vorbis", nil
+	case CodecFLAC:
+		return "flac", nil
+	case CodecAC3:
+		return "ac-3", nil
+	case CodecEAC3:
+		return "ec-3", nil
+	case CodecMP3:
+		return "mp4a.6B", nil
+	case "S_TEXT/WEBVTT":
+		return "wvtt", nil
+	}
+	if p, ok := aacProfiles[t.CodecID]; ok {
+		return fmt.Sprintf("mp4a.40.%d", p), nil
+	}
+	v, err := t.CodecConfig()
+	if err != nil {
+		return "", err
+	}
+	switch c := v.(type) {
+	case *codec.AVCConfig:
+		return fmt.Sprintf("avc1.%02x%02x%02x", c.Profile, c.Compatibility, c.Level), nil
+	case *codec.HEVCConfig:
+		var compat uint32
+		for i := uint(0); i < 32; i++ {
+			compat |= c.Compatibility >> i & 1 << (31 - i)
+		}
+		tier := "L"
+		if c.Tier != 0 {
+			tier = "H"
+		}
+		s := fmt.Sprintf("hvc1.%s%d.%X.%s%d", []string{"", "A", "B", "C"}[c.ProfileSpace], c.Profile, compat, tier, c.Level)
+		cons := c.Constraints
+		n := 6
+		for n > 0 && cons&0xff == 0 {
+			cons >>= 8
+			n--
+		}
+		for i := n - 1; i >= 0; i-- {
+			s += fmt.Sprintf(".%X", byte(cons>>uint(8*i)))
+		}
+		return s, nil
+	case *codec.AV1Config:
+		tier := "M"
+		if c.Tier != 0 {
+			tier = "H"
+		}
+		s := fmt.Sprintf("av01.%d.%02d%s.%02d", c.Profile, c.Level, tier, c.BitDepth)
+		if cp, tc, mc, full, ok := t.colour(); ok {
+			s += fmt.Sprintf(".%d.%d%d%d.%02d.%02d.%02d.%d", flag(c.Monochrome), flag(c.ChromaSubsamplingX),
+				flag(c.ChromaSubsamplingY), c.ChromaSamplePosition, cp, tc, mc, flag(full))
+		}
+		return s, nil
+	case *codec.VP9Config:
+		if c.Level == 0 {
+			c.Level = 10
+		}
+		if t.Video != nil && t.Video.Colour != nil && t.Video.Colour.BitsPerChannel > 0 {
+			c.BitDepth = t.Video.Colour.BitsPerChannel
+		}
+		s := fmt.Sprintf("vp09.%02d.%02d.%02d", c.Profile, c.Level, c.BitDepth)
+		if cp, tc, mc, full, ok := t.colour(); ok {
+			s += fmt.Sprintf(".%02d.%02d.%02d.%02d.%02d", c.ChromaSubsampling, cp, tc, mc, flag(full))
+		}
+		return s, nil
+	case *codec.AACConfig:
+		p := c.ObjectType
+		if c.ExtensionObjectType != 0 {
+			p = c.ExtensionObjectType
+		}
+		return fmt.Sprintf("mp4a.40.%d", p), nil
+	}
+	return "", errors.New("matroska: unsupported codec " + t.CodecID)
+}
+
+// colour returns colour primaries, transfer characteristics, matrix coefficients and range of the video track.
+// Returns false if the track has no colour metadata.
+func (t *TrackEntry) colour() (cp, tc, mc int, full, ok bool) {
+	if t.Video == nil || t.Video.Colour == nil {
+		return
+	}
+	c := t.Video.Colour
+	cp, tc, mc = int(c.Primaries), int(c.TransferCharacteristics), int(c.MatrixCoefficients)
+	if cp == 0 || cp == 2 {
+		cp = 1
+	}
+	if tc == 0 || tc == 2 {
+		tc = 1
+	}
+	if mc == 2 {
+		mc = 1
+	}
+	full = c.ColourRange == ColourRangeFull
+	ok = cp != 1 || tc != 1 || mc != 1 || full
+	return
+}
+
+func flag(v bool) int {
+	if v {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,31 @@
+package matroska
+
+import "testing"
+
+func TestCodecString(t *testing.T) {
+	hdr := &Colour{BitsPerChannel: 10, Primaries: 9, TransferCharacteristics: 16, MatrixCoefficients: 9}
+	tests := []struct {
+		track *TrackEntry
+		want  string
+	}{
+		{&TrackEntry{CodecID: CodecAVC, CodecPrivate: []byte{1, 0x64, 0, 0x1f, 0xff, 0xe0, 0}}, "avc1.64001f"},
+		{&TrackEntry{CodecID: CodecHEVC, CodecPrivate: []byte{1, 0x01, 0x60, 0, 0, 0, 0xb0, 0, 0, 0, 0, 0, 93, 0xf0, 0, 0xfc, 0xfd, 0xf8, 0xf8, 0, 0, 0x0f, 0}}, "hvc1.1.6.L93.B0"},
+		{&TrackEntry{CodecID: CodecAV1, CodecPrivate: []byte{0x81, 0x04, 0x0c, 0}}, "av01.0.04M.08"},
+		{&TrackEntry{CodecID: CodecAV1, CodecPrivate: []byte{0x81, 0x04, 0x4c, 0}, Video: &VideoTrack{Colour: hdr}}, "av01.0.04M.10.0.110.09.16.09.0"},
+		{&TrackEntry{CodecID: CodecVP9}, "vp09.00.10.08"},
+		{&TrackEntry{CodecID: CodecVP9, CodecPrivate: []byte{1, 1, 2, 2, 1, 31}, Video: &VideoTrack{Colour: hdr}}, "vp09.02.31.10.01.09.16.09.00"},
+		{&TrackEntry{CodecID: CodecVP8}, "vp8"},
+		{&TrackEntry{CodecID: CodecAAC, CodecPrivate: []byte{0x12, 0x10}}, "mp4a.40.2"},
+		{&TrackEntry{CodecID: "A_AAC/MPEG4/LC/SBR"}, "mp4a.40.5"},
+		{&TrackEntry{CodecID: CodecOpus}, "opus"},
+	}
+	for _, it := range tests {
+		s, err := it.track.CodecString()
+		if err != nil {
+			t.Fatalf("%s: %v", it.track.CodecID, err)
+		}
+		if s != it.want {
+			t.Errorf("Unexpected codec string of %s: %s, want: %s", it.track.CodecID, s, it.want)
+		}
+	}
+}
@@ -0,0 +1,294 @@
+// Package dash generates MPEG-DASH manifests of WebM files for the on-demand profile.
+// See http://wiki.webmproject.org/adaptive-streaming/webm-dash-specification
+package dash
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/pixelbender/go-matroska/ebml"
+	"github.com/pixelbender/go-matroska/matroska"
+	"io"
+	"time"
+)
+
+// Profile is the DASH on-demand profile of WebM files.
+const Profile = "urn:mpeg:dash:profile:isoff-on-demand:2011"
+
+// minBufferTime is the MinBufferTime of the presentation.
+const minBufferTime = time.Second
+
+// MPD is the Media Presentation Description with a single static Period.
+type MPD struct {
+	XMLName                   xml.Name `xml:"urn:mpeg:DASH:schema:MPD:2011 MPD"`
+	Type                      string   `xml:"type,attr"`
+	MediaPresentationDuration string   `xml:"mediaPresentationDuration,attr"`
+	MinBufferTime             string   `xml:"minBufferTime,attr"`
+	Profiles                  string   `xml:"profiles,attr"`
+	Period                    *Period  `xml:"Period"`
+}
+
+// Period contains adaptation sets of the presentation.
+type Period struct {
+	ID             string           `xml:"id,attr"`
+	Start          string           `xml:"start,attr"`
+	Duration       string           `xml:"duration,attr"`
+	AdaptationSets []*AdaptationSet `xml:"AdaptationSet"`
+}
+
+// AdaptationSet contains interchangeable representations of the same content.
+type AdaptationSet struct {
+	ID                      int               `xml:"id,attr"`
+	MimeType                string            `xml:"mimeType,attr"`
+	Codecs                  string            `xml:"codecs,attr"`
+	Lang                    string            `xml:"lang,attr,omitempty"`
+	Width                   int               `xml:"width,attr,omitempty"`
+	Height                  int               `xml:"height,attr,omitempty"`
+	SubsegmentAlignment     bool              `xml:"subsegmentAlignment,attr"`
+	SubsegmentStartsWithSAP int               `xml:"subsegmentStartsWithSAP,attr"`
+	BitstreamSwitching      bool              `xml:"bitstreamSwitching,attr"`
+	Representations         []*Representation `xml:"Representation"`
+}
+
+// Representation is a single WebM file of the adaptation set.
+type Representation struct {
+	ID                string       `xml:"id,attr"`
+	Bandwidth         int          `xml:"bandwidth,attr"`
+	Width             int          `xml:"width,attr,omitempty"`
+	Height            int          `xml:"height,attr,omitempty"`
+	AudioSamplingRate int          `xml:"audioSamplingRate,attr,omitempty"`
+	Codecs            string       `xml:"codecs,attr,omitempty"`
+	BaseURL           string       `xml:"BaseURL"`
+	SegmentBase       *SegmentBase `xml:"SegmentBase"`
+
+	// Properties of the file used to build the adaptation set
+	MimeType string        `xml:"-"`
+	Lang     string        `xml:"-"`
+	Duration time.Duration `xml:"-"`
+}
+
+// SegmentBase contains byte ranges of the Cues and the initialization segment.
+type SegmentBase struct {
+	IndexRange     string          `xml:"indexRange,attr"`
+	Initialization *Initialization `xml:"Initialization"`
+}
+
+// Initialization is the byte range from the start of the file up to the first Cluster.
+type Initialization struct {
+	Range string `xml:"range,attr"`
+}
+
+// NewRepresentation reads the WebM file of a single track from r and returns its representation
+// with the given id and URL. The bandwidth is the peak bitrate of Cue ranges of at least minBufferTime.
+func NewRepresentation(r io.ReadSeeker, id, url string) (*Representation, error) {
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	dec := ebml.NewReader(r, &ebml.DecodeOptions{
+		SkipDamaged: true,
+		Level:       matroska.Level,
+	})
+	var seg *ebml.Reader
+	doc := &matroska.EBML{}
+	for seg == nil {
+		eid, elem, err := dec.ReadElement()
+		if err != nil {
+			if err == io.EOF {
+				err = errors.New("dash: segment not found")
+			}
+			return nil, err
+		}
+		switch matroska.ID(eid) {
+		case matroska.IDEBML:
+			if err = elem.Decode(doc); err != nil {
+				return nil, err
+			}
+		case matroska.IDSegment:
+			if doc.DocType != "webm" {
+				return nil, errors.New("dash: not a webm file")
+			}
+			seg = elem
+		}
+	}
+	s := &matroska.Segment{}
+	start := seg.Offset()
+	var cues, cuesEnd, cluster, clusterEnd int64 = -1, -1, -1, -1
+	for {
+		off := seg.Offset()
+		eid, elem, err := seg.ReadElement()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+		switch matroska.ID(eid) {
+		case matroska.IDCluster:
+			if cluster < 0 {
+				cluster = off
+			}
+			if elem.Len() < 0 {
+				return nil, errors.New("dash: cluster of unknown size")
+			}
+			clusterEnd = elem.Offset() + elem.Len()
+		case matroska.IDCues:
+			cues, cuesEnd = off, elem.Offset()+elem.Len()
+			if err = elem.DecodeElement(eid, s); err != nil {
+				return nil, err
+			}
+		case matroska.IDInfo, matroska.IDTracks:
+			if err = elem.DecodeElement(eid, s); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(s.Cues) == 0 || cluster < 0 {
+		return nil, errors.New("dash: no cues or clusters")
+	}
+	if len(s.Tracks) == 0 || len(s.Tracks[0].Entries) != 1 {
+		return nil, errors.New("dash: file must contain a single track")
+	}
+	t := s.Tracks[0].Entries[0]
+	codecs, err := t.CodecString()
+	if err != nil {
+		return nil, err
+	}
+	rep := &Representation{
+		ID:      id,
+		Codecs:  codecs,
+		BaseURL: url,
+		SegmentBase: &SegmentBase{
+			IndexRange:     fmt.Sprintf("%d-%d", cues, cuesEnd-1),
+			Initialization: &Initialization{Range: fmt.Sprintf("0-%d", cluster-1)},
+		},
+		Lang: t.Language,
+	}
+	if len(s.Info) > 0 {
+		rep.Duration = time.Duration(s.Info[0].Duration * float64(s.TimecodeScale()))
+	}
+	rep.Bandwidth = bandwidth(s, clusterEnd-start, rep.Duration)
+	switch t.Type {
+	case matroska.TrackTypeVideo:
+		rep.MimeType = "video/webm"
+		if t.Video != nil {
+			rep.Width, rep.Height = t.Video.Width, t.Video.Height
+		}
+	case matroska.TrackTypeAudio:
+		rep.MimeType = "audio/webm"
+		if t.Audio != nil {
+			rep.AudioSamplingRate = int(t.Audio.SamplingFreq)
+		}
+	default:
+		return nil, errors.New("dash: unsupported track type")
+	}
+	return rep, nil
+}
+
+// bandwidth returns the peak bitrate of ranges between Cue points of at least minBufferTime.
+// The end is the position of the end of the last Cluster relative to the Segment data.
+func bandwidth(s *matroska.Segment, end int64, d time.Duration) int {
+	scale := s.TimecodeScale()
+	var peak float64
+	for i, it := range s.Cues {
+		if len(it.TrackPositions) == 0 {
+			continue
+		}
+		from, to := time.Duration(it.Time)*scale, d
+		pos, next := int64(it.TrackPositions[0].ClusterPosition), end
+		for _, c := range s.Cues[i+1:] {
+			if t := time.Duration(c.Time) * scale; t-from >= minBufferTime && len(c.TrackPositions) > 0 {
+				to, next = t, int64(c.TrackPositions[0].ClusterPosition)
+				break
+			}
+		}
+		if to <= from || next <= pos {
+			continue
+		}
+		if v := float64((next-pos)*8) / (to - from).Seconds(); v > peak {
+			peak = v
+		}
+	}
+	return int(peak)
+}
+
+// NewAdaptationSet returns the adaptation set of representations with the same mime type and language.
+// Representations are copied, the passed ones are not modified.
+func NewAdaptationSet(id int, reps ...*Representation) (*AdaptationSet, error) {
+	if len(reps) == 0 {
+		return nil, errors.New("dash: no representations")
+	}
+	list := make([]*Representation, len(reps))
+	for i, it := range reps {
+		v := *it
+		list[i] = &v
+	}
+	first := list[0]
+	set := &AdaptationSet{
+		ID:                      id,
+		MimeType:                first.MimeType,
+		Codecs:                  first.Codecs,
+		Lang:                    first.Lang,
+		SubsegmentAlignment:     true,
+		SubsegmentStartsWithSAP: 1,
+		BitstreamSwitching:      true,
+		Representations:         list,
+	}
+	same := true
+	for _, it := range list {
+		if it.MimeType != set.MimeType || it.Lang != set.Lang {
+			return nil, errors.New("dash: representations of different content")
+		}
+		same = same && it.Codecs == set.Codecs
+		if it.Width > set.Width {
+			set.Width, set.Height = it.Width, it.Height
+		}
+	}
+	// Common codecs are set on the adaptation set
+	if same {
+		for _, it := range list {
+			it.Codecs = ""
+		}
+	} else {
+		set.Codecs, set.BitstreamSwitching = "", false
+	}
+	return set, nil
+}
+
+// NewMPD returns the static presentation of the adaptation sets.
+// The duration of the presentation is the longest duration of representations.
+func NewMPD(sets ...*AdaptationSet) *MPD {
+	var d time.Duration
+	for _, set := range sets {
+		for _, it := range set.Representations {
+			if it.Duration > d {
+				d = it.Duration
+			}
+		}
+	}
+	return &MPD{
+		Type:                      "static",
+		MediaPresentationDuration: formatDuration(d),
+		MinBufferTime:             "PT1S",
+		Profiles:                  Profile,
+		Period: &Period{
+			ID:             "0",
+			Start:          "PT0S",
+			Duration:       formatDuration(d),
+			AdaptationSets: sets,
+		},
+	}
+}
+
+// WriteTo writes the XML document of the manifest to w.
+func (m *MPD) WriteTo(w io.Writer) (int64, error) {
+	b, err := xml.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.WriteString(w, xml.Header+string(b)+"\n")
+	return int64(n), err
+}
+
+func formatDuration(d time.Duration) string {
+	return fmt.Sprintf("PT%.3fS", d.Seconds())
+}
@@ -0,0 +1,122 @@
+package dash
+
+import (
+	"bytes"
+	"github.com/pixelbender/go-matroska/matroska"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMPD(t *testing.T) {
+	f, err := ioutil.TempFile("", "dash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	seg := &matroska.Segment{Tracks: []*matroska.Track{{Entries: []*matroska.TrackEntry{
+		{Number: 1, Type: matroska.TrackTypeVideo, CodecID: matroska.CodecVP8, Enabled: true, Default: true, Video: &matroska.VideoTrack{Width: 640, Height: 360}},
+	}}}}
+	w, err := matroska.NewWriter(f, seg, &matroska.WriterOptions{DocType: "webm", ClusterDuration: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		size := 100
+		if i/25 == 1 {
+			size = 400
+		}
+		p := &matroska.Packet{Track: 1, Time: time.Duration(i) * 40 * time.Millisecond, Keyframe: i%25 == 0, Data: make([]byte, size)}
+		if err = w.WritePacket(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	rep, err := NewRepresentation(f, "360p", "video.webm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var init, start, end int
+	parse := func(s string) (int, int) {
+		var a, b int
+		for i, it := range strings.SplitN(s, "-", 2) {
+			for _, c := range it {
+				if i == 0 {
+					a = a*10 + int(c-'0')
+				} else {
+					b = b*10 + int(c-'0')
+				}
+			}
+		}
+		return a, b
+	}
+	_, init = parse(rep.SegmentBase.Initialization.Range)
+	start, end = parse(rep.SegmentBase.IndexRange)
+	if !bytes.HasPrefix(b[init+1:], []byte{0x1f, 0x43, 0xb6, 0x75}) || !bytes.HasPrefix(b[start:], []byte{0x1c, 0x53, 0xbb, 0x6b}) || end >= len(b) {
+		t.Errorf("Unexpected ranges: %+v", rep.SegmentBase)
+	}
+	if rep.Duration != 3960*time.Millisecond || rep.Width != 640 || rep.Bandwidth < 25*400*8 || rep.Bandwidth > 26*400*8 || rep.MimeType != "video/webm" {
+		t.Errorf("Unexpected representation: %+v", rep)
+	}
+	low := *rep
+	low.ID, low.Width, low.Height = "180p", 320, 180
+	set, err := NewAdaptationSet(0, rep, &low)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Codecs != "vp8" || low.Codecs != "vp8" || set.Representations[0].Codecs != "" {
+		t.Errorf("Unexpected codecs: %q %q", rep.Codecs, set.Representations[0].Codecs)
+	}
+	out := &bytes.Buffer{}
+	if _, err = NewMPD(set).WriteTo(out); err != nil {
+		t.Fatal(err)
+	}
+	for _, it := range []string{
+		`<MPD xmlns="urn:mpeg:DASH:schema:MPD:2011" type="static" mediaPresentationDuration="PT3.960S"`,
+		`<AdaptationSet id="0" mimeType="video/webm" codecs="vp8" lang="eng" width="640" height="360" subsegmentAlignment="true" subsegmentStartsWithSAP="1" bitstreamSwitching="true">`,
+		`<Representation id="180p" bandwidth="` + strconv.Itoa(rep.Bandwidth) + `" width="320" height="180">`,
+		`<BaseURL>video.webm</BaseURL>`,
+		`<SegmentBase indexRange="` + rep.SegmentBase.IndexRange + `">`,
+	} {
+		if !strings.Contains(out.String(), it) {
+			t.Errorf("MPD does not contain %s:\n%s", it, out)
+		}
+	}
+}
+
+func TestRepresentationDocType(t *testing.T) {
+	seg := &matroska.Segment{Tracks: []*matroska.Track{{Entries: []*matroska.TrackEntry{
+		{Number: 1, Type: matroska.TrackTypeVideo, CodecID: matroska.CodecVP8, Enabled: true, Default: true, Video: &matroska.VideoTrack{Width: 640, Height: 360}},
+	}}}}
+	f, err := ioutil.TempFile("", "dash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	w, err := matroska.NewWriter(f, seg, &matroska.WriterOptions{DocType: "matroska", ClusterDuration: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 50; i++ {
+		if err = w.WritePacket(&matroska.Packet{Track: 1, Time: time.Duration(i) * 40 * time.Millisecond, Keyframe: i%25 == 0, Data: make([]byte, 100)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = NewRepresentation(f, "360p", "video.mkv"); err == nil {
+		t.Error("Expected error for the matroska file")
+	}
+}
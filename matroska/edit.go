@@ -0,0 +1,376 @@
+package matroska
+
+import (
+	"bytes"
+	"errors"
+	"github.com/pixelbender/go-matroska/ebml"
+	"io"
+	"os"
+)
+
+// EditInPlace decodes metadata of the Matroska file, calls fn to modify the Segment
+// and writes changed Info, Tracks, Chapters and Tags elements back to the file.
+//
+// The element is overwritten in place if the new encoding fits into the space taken by the
+// old element and the following Void elements. Otherwise the old element is replaced by Void,
+// the new one is appended to the end of the Segment and the SeekHead is updated.
+// Clusters, Cues and Attachments are not loaded.
+// Changed elements with children unknown to this package are not written, an error is returned instead.
+func EditInPlace(file string, fn func(*Segment) error) error {
+	f, err := os.OpenFile(file, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	e, err := newEditor(f)
+	if err != nil {
+		return err
+	}
+	if err = fn(e.seg); err != nil {
+		return err
+	}
+	if err = e.update(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+var editIDs = []ID{IDInfo, IDTracks, IDChapters, IDTags}
+
+type editor struct {
+	f     io.ReadWriteSeeker
+	seg   *Segment
+	start int64
+	width int
+	size  int64
+	end   int64
+	elems []*elementPos
+	orig  map[ID][]byte
+	// unknown is set for elements with children not known to the Segment structs.
+	unknown map[ID]bool
+}
+
+// elementPos is a position of the Top-Level Element in the file.
+type elementPos struct {
+	id   ID
+	off  int64
+	size int64
+}
+
+func newEditor(f io.ReadWriteSeeker) (*editor, error) {
+	r := ebml.NewReader(f, nil)
+	e := &editor{f: f}
+	var seg *ebml.Reader
+	for seg == nil {
+		off := r.Offset()
+		id, elem, err := r.ReadElement()
+		if err != nil {
+			if err == io.EOF {
+				err = errors.New("matroska: segment not found")
+			}
+			return nil, err
+		}
+		if ID(id) == IDSegment {
+			seg, e.start, e.size = elem, elem.Offset(), elem.Len()
+			e.width = int(e.start-off) - 4
+		}
+	}
+	meta := &bytes.Buffer{}
+	w := ebml.NewWriter(meta)
+	e.unknown = make(map[ID]bool)
+	for {
+		off := seg.Offset()
+		id, elem, err := seg.ReadElement()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+		if elem.Len() < 0 {
+			// Element of unknown size takes the rest of the Segment
+			break
+		}
+		e.elems = append(e.elems, &elementPos{ID(id), off, elem.Offset() - off + elem.Len()})
+		switch ID(id) {
+		case IDSeekHead, IDInfo, IDTracks, IDChapters, IDTags:
+			b := make([]byte, elem.Len())
+			if _, err = io.ReadFull(elem, b); err != nil {
+				return nil, err
+			}
+			n := meta.Len()
+			if err = w.WriteElementHeader(id, int64(len(b))); err != nil {
+				return nil, err
+			}
+			w.Write(b)
+			if hasUnknown(meta.Bytes()[n:]) {
+				e.unknown[ID(id)] = true
+			}
+		}
+	}
+	if e.size < 0 {
+		end, err := f.Seek(0, io.SeekEnd)
+		if err != nil {
+			return nil, err
+		}
+		e.end = end
+	} else {
+		e.end = e.start + e.size
+	}
+	e.seg = &Segment{}
+	dec := ebml.NewReaderBytes(meta.Bytes(), &ebml.DecodeOptions{SkipDamaged: true})
+	if err := dec.Decode(e.seg); err != nil {
+		return nil, err
+	}
+	e.orig = make(map[ID][]byte)
+	for _, id := range editIDs {
+		b, err := e.encode(id)
+		if err != nil {
+			return nil, err
+		}
+		e.orig[id] = b
+	}
+	return e, nil
+}
+
+func (e *editor) encode(id ID) ([]byte, error) {
+	switch id {
+	case IDSeekHead:
+		return ebml.Marshal(&Segment{SeekHead: e.seg.SeekHead})
+	case IDInfo:
+		return ebml.Marshal(&Segment{Info: e.seg.Info})
+	case IDTracks:
+		return ebml.Marshal(&Segment{Tracks: e.seg.Tracks})
+	case IDChapters:
+		return ebml.Marshal(&Segment{Chapters: e.seg.Chapters})
+	case IDTags:
+		return ebml.Marshal(&Segment{Tags: e.seg.Tags})
+	default:
+		return nil, errors.New("matroska: element can not be edited")
+	}
+}
+
+func (e *editor) update() error {
+	moved := false
+	for _, id := range editIDs {
+		b, err := e.encode(id)
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(b, e.orig[id]) {
+			continue
+		}
+		if e.unknown[id] {
+			return errors.New("matroska: element to edit contains unknown elements")
+		}
+		ok, err := e.overwrite(id, b)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			if err = e.relocate(id, b); err != nil {
+				return err
+			}
+			moved = true
+		}
+	}
+	if moved {
+		if err := e.updateSeekHead(); err != nil {
+			return err
+		}
+	}
+	return e.updateSize()
+}
+
+// hasUnknown reports whether the encoded element b has children
+// that would be lost when the element is encoded again.
+func hasUnknown(b []byte) bool {
+	found := false
+	opt := &ebml.DecodeOptions{
+		SkipDamaged: true,
+		DecodeUnknown: func(id uint32, elem *ebml.Reader) error {
+			if id != ebml.IDVoid && id != ebml.IDCRC32 {
+				found = true
+			}
+			return nil
+		},
+	}
+	ebml.NewReaderBytes(b, opt).Decode(&Segment{})
+	return found
+}
+
+// overwrite writes b in place of the first element with the given ID
+// and replaces other elements with the same ID by Void.
+func (e *editor) overwrite(id ID, b []byte) (bool, error) {
+	i := e.index(id)
+	if i < 0 || len(b) == 0 {
+		return false, nil
+	}
+	if ok, err := e.writeInto(i, id, b); !ok || err != nil {
+		return false, err
+	}
+	return true, e.clear(id, i+1)
+}
+
+// writeInto writes b into the space taken by the element at the index i
+// and the following Void elements.
+func (e *editor) writeInto(i int, id ID, b []byte) (bool, error) {
+	n, space := i+1, e.elems[i].size
+	for n < len(e.elems) && e.elems[n].id == ebml.IDVoid {
+		space += e.elems[n].size
+		n++
+	}
+	if space == int64(len(b))+1 {
+		b = widen(b)
+	}
+	pad := space - int64(len(b))
+	if pad < 0 || pad == 1 {
+		return false, nil
+	}
+	off := e.elems[i].off
+	elems := []*elementPos{{id, off, int64(len(b))}}
+	if pad > 0 {
+		elems = append(elems, &elementPos{ebml.IDVoid, off + int64(len(b)), pad})
+	}
+	if err := e.writeAt(off, b, pad); err != nil {
+		return false, err
+	}
+	e.elems = append(e.elems[:i], append(elems, e.elems[n:]...)...)
+	return true, nil
+}
+
+// relocate replaces elements with the given ID by Void and appends b to the end of the Segment.
+func (e *editor) relocate(id ID, b []byte) error {
+	if err := e.clear(id, 0); err != nil {
+		return err
+	}
+	if len(b) == 0 {
+		return nil
+	}
+	end, err := e.f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	if end != e.end {
+		return errors.New("matroska: segment is not at the end of file")
+	}
+	if err = e.writeAt(e.end, b, 0); err != nil {
+		return err
+	}
+	e.elems = append(e.elems, &elementPos{id, e.end, int64(len(b))})
+	e.end += int64(len(b))
+	return nil
+}
+
+// updateSeekHead writes positions of the edited elements into the SeekHead.
+func (e *editor) updateSeekHead() error {
+	var seeks []*Seek
+	for _, h := range e.seg.SeekHead {
+		for _, it := range h.Seeks {
+			if it.ID != IDSeekHead && !isEditID(it.ID) {
+				seeks = append(seeks, it)
+			}
+		}
+	}
+	for _, id := range editIDs {
+		if i := e.index(id); i >= 0 {
+			seeks = append(seeks, &Seek{id, Position(e.elems[i].off - e.start)})
+		}
+	}
+	e.seg.SeekHead = []*SeekHead{{Seeks: seeks}}
+	b, err := e.encode(IDSeekHead)
+	if err != nil {
+		return err
+	}
+	if ok, err := e.overwrite(IDSeekHead, b); ok || err != nil {
+		return err
+	}
+	i := e.index(IDSeekHead)
+	if err = e.relocate(IDSeekHead, b); err != nil || i < 0 {
+		return err
+	}
+	// Leave a reference to the relocated SeekHead at the old position
+	ref := []*SeekHead{{Seeks: []*Seek{{IDSeekHead, Position(e.end - int64(len(b)) - e.start)}}}}
+	if b, err = ebml.Marshal(&Segment{SeekHead: ref}); err != nil {
+		return err
+	}
+	_, err = e.writeInto(i, IDSeekHead, b)
+	return err
+}
+
+func (e *editor) updateSize() error {
+	if e.size < 0 || e.size == e.end-e.start {
+		return nil
+	}
+	b := &bytes.Buffer{}
+	if err := ebml.NewWriter(b).WriteElementHeaderWidth(uint32(IDSegment), e.end-e.start, e.width); err != nil {
+		return err
+	}
+	e.size = e.end - e.start
+	return e.writeAt(e.start-int64(b.Len()), b.Bytes(), 0)
+}
+
+// clear replaces elements with the given ID starting from the index i by Void.
+func (e *editor) clear(id ID, i int) error {
+	for _, it := range e.elems[i:] {
+		if it.id != id {
+			continue
+		}
+		if err := e.writeAt(it.off, nil, it.size); err != nil {
+			return err
+		}
+		it.id = ebml.IDVoid
+	}
+	return nil
+}
+
+func (e *editor) writeAt(off int64, b []byte, pad int64) error {
+	if _, err := e.f.Seek(off, io.SeekStart); err != nil {
+		return err
+	}
+	w := ebml.NewWriter(e.f)
+	if _, err := w.Write(b); err != nil {
+		return err
+	}
+	if pad > 0 {
+		return w.WriteVoid(pad)
+	}
+	return nil
+}
+
+func (e *editor) index(id ID) int {
+	for i, it := range e.elems {
+		if it.id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func isEditID(id ID) bool {
+	for _, it := range editIDs {
+		if it == id {
+			return true
+		}
+	}
+	return false
+}
+
+// widen returns b with the size of the first element encoded one byte longer.
+func widen(b []byte) []byte {
+	r := ebml.NewReaderBytes(b, nil)
+	id, elem, err := r.ReadElement()
+	if err != nil {
+		return b
+	}
+	off := elem.Offset()
+	width := int(off) - 1
+	for v := id >> 8; v > 0; v >>= 8 {
+		width--
+	}
+	h := &bytes.Buffer{}
+	if err = ebml.NewWriter(h).WriteElementHeaderWidth(id, elem.Len(), width+1); err != nil {
+		return b
+	}
+	return append(h.Bytes(), b[off:]...)
+}
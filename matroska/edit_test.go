@@ -0,0 +1,168 @@
+package matroska
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/pixelbender/go-matroska/ebml"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEditInPlace(t *testing.T) {
+	dir, err := ioutil.TempDir("", "matroska")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "edit.mkv")
+	if err = writeTestFile(file); err != nil {
+		t.Fatal(err)
+	}
+	langs := []string{"eng", "fre", "ger"}
+	for i, title := range []string{"Short", strings.Repeat("Long title ", 20), "Short again"} {
+		lang, def, forced := langs[i], i != 1, i == 1
+		err = EditInPlace(file, func(s *Segment) error {
+			s.Info[0].Title = title
+			entry := s.Tracks[0].Entries[0]
+			entry.Name, entry.Language, entry.Default, entry.Forced = title, lang, def, forced
+			s.Tags = []*Tag{{SimpleTags: []*SimpleTag{NewSimpleTag("TITLE", title)}}}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		doc, err := Decode(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := doc.Segment
+		if len(s.Info) != 1 || s.Info[0].Title != title {
+			t.Errorf("Unexpected info: %s", dump(s.Info))
+		}
+		if len(s.Tracks) != 1 || s.Tracks[0].Entries[0].Name != title || s.Tracks[0].Entries[0].CodecID != "V_VP8" {
+			t.Errorf("Unexpected tracks: %s", dump(s.Tracks))
+		}
+		if entry := s.Tracks[0].Entries[0]; entry.Language != lang || entry.Default != def || entry.Forced != forced || entry.Video.AlphaMode != AlphaModePresent {
+			t.Errorf("Unexpected track flags: %s", dump(entry))
+		}
+		if len(s.Tags) != 1 || s.Tags[0].SimpleTags[0].String != title {
+			t.Errorf("Unexpected tags: %s", dump(s.Tags))
+		}
+		if err = checkSeekHead(file); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestEditUnknownElements(t *testing.T) {
+	dir, err := ioutil.TempDir("", "matroska")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "unknown.mkv")
+	type entry struct {
+		Number  TrackNumber `ebml:"D7"`
+		CodecID string      `ebml:"86"`
+		Mapping []byte      `ebml:"41E4"`
+	}
+	type segment struct {
+		Info   []*Info `ebml:"1549A966"`
+		Tracks struct {
+			Entries []*entry `ebml:"AE"`
+		} `ebml:"1654AE6B"`
+	}
+	v := &struct {
+		EBML    *EBML    `ebml:"1A45DFA3"`
+		Segment *segment `ebml:"18538067"`
+	}{NewFile("matroska").EBML, &segment{Info: []*Info{{TimecodeScale: time.Millisecond}}}}
+	v.Segment.Tracks.Entries = []*entry{{1, "V_VP8", []byte{1, 2, 3}}}
+	b, err := ebml.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(file, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	err = EditInPlace(file, func(s *Segment) error {
+		s.Tracks[0].Entries[0].Name = "Video"
+		return nil
+	})
+	if err == nil {
+		t.Error("Expected error on editing tracks with unknown elements")
+	}
+	err = EditInPlace(file, func(s *Segment) error {
+		s.Info[0].Title = "Title"
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := Decode(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Segment.Info[0].Title != "Title" {
+		t.Errorf("Unexpected info: %s", dump(doc.Segment.Info))
+	}
+	after, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(after, []byte{0x41, 0xE4, 0x83, 1, 2, 3}) {
+		t.Error("Unknown element is lost")
+	}
+}
+
+func writeTestFile(file string) error {
+	f := NewFile("matroska")
+	f.Segment.SeekHead = []*SeekHead{{Seeks: []*Seek{{IDInfo, 0}}}}
+	f.Segment.Info = []*Info{{
+		TimecodeScale: time.Millisecond,
+		Title:         "Initial title",
+		MuxingApp:     "go-matroska",
+		WritingApp:    "go-matroska",
+	}}
+	f.Segment.Tracks = []*Track{{Entries: []*TrackEntry{{
+		Number:   1,
+		ID:       1,
+		Type:     TrackTypeVideo,
+		CodecID:  "V_VP8",
+		Language: "und",
+		Video:    &VideoTrack{Width: 320, Height: 240, AlphaMode: AlphaModePresent},
+	}}}}
+	out, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	return ebml.NewWriter(out).Encode(f)
+}
+
+func checkSeekHead(file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	e, err := newEditor(f)
+	if err != nil {
+		return err
+	}
+	pos := make(map[Seek]bool)
+	for _, it := range e.elems {
+		pos[Seek{it.id, Position(it.off - e.start)}] = true
+	}
+	for _, h := range e.seg.SeekHead {
+		for _, it := range h.Seeks {
+			if !pos[*it] {
+				return fmt.Errorf("Unexpected seek entry: %s", dump(it))
+			}
+		}
+	}
+	return nil
+}
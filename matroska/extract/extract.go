@@ -0,0 +1,274 @@
+// Package extract writes tracks of Matroska files to raw elementary stream files, like mkvextract.
+package extract
+
+import (
+	"errors"
+	"github.com/pixelbender/go-matroska/matroska"
+	"github.com/pixelbender/go-matroska/matroska/codec"
+	"github.com/pixelbender/go-matroska/matroska/ivf"
+	"github.com/pixelbender/go-matroska/matroska/subtitles"
+	"github.com/pixelbender/go-matroska/matroska/wav"
+	"io"
+	"strings"
+	"time"
+)
+
+// Writer writes packets of a single track.
+type Writer interface {
+	WritePacket(p *matroska.Packet) error
+	Close() error
+}
+
+// NewWriter returns a writer of the track to w in the natural container of its codec:
+// IVF for VP8, VP9 and AV1, Annex B for AVC and HEVC, ADTS for AAC, Ogg for Opus and Vorbis,
+// WAVE for PCM, native FLAC and SRT, ASS or WebVTT for text subtitles.
+func NewWriter(w io.Writer, t *matroska.TrackEntry) (Writer, error) {
+	switch t.CodecID {
+	case matroska.CodecVP8, matroska.CodecVP9, matroska.CodecAV1:
+		return newIVFWriter(w, t)
+	case matroska.CodecAVC, matroska.CodecHEVC:
+		f, err := matroska.NewAnnexBFilter(t)
+		if err != nil {
+			return nil, err
+		}
+		return &rawWriter{w: w, filter: f}, nil
+	case matroska.CodecOpus, matroska.CodecVorbis:
+		return newOggWriter(w, t)
+	case matroska.CodecPCM, matroska.CodecPCMBE, matroska.CodecFloat:
+		return newWAVWriter(w, t)
+	case matroska.CodecFLAC:
+		b := t.CodecPrivate
+		if !strings.HasPrefix(string(b), "fLaC") {
+			b = append([]byte("fLaC"), b...)
+		}
+		if _, err := w.Write(b); err != nil {
+			return nil, err
+		}
+		return &rawWriter{w: w}, nil
+	case subtitles.CodecUTF8, subtitles.CodecASCII:
+		return subtitles.NewWriter(w, t, subtitles.SRT)
+	case subtitles.CodecASS, subtitles.CodecSSA, "S_ASS", "S_SSA":
+		return subtitles.NewWriter(w, t, subtitles.ASS)
+	case subtitles.CodecWebVTT:
+		return subtitles.NewWriter(w, t, subtitles.WebVTT)
+	}
+	if strings.HasPrefix(t.CodecID, matroska.CodecAAC) {
+		return newADTSWriter(w, t)
+	}
+	return nil, errors.New("extract: unsupported codec " + t.CodecID)
+}
+
+// Extension returns the file extension for the track written by NewWriter.
+// Returns an empty string if the codec is not supported.
+func Extension(t *matroska.TrackEntry) string {
+	switch t.CodecID {
+	case matroska.CodecVP8, matroska.CodecVP9, matroska.CodecAV1:
+		return ".ivf"
+	case matroska.CodecAVC:
+		return ".h264"
+	case matroska.CodecHEVC:
+		return ".h265"
+	case matroska.CodecOpus:
+		return ".opus"
+	case matroska.CodecVorbis:
+		return ".ogg"
+	case matroska.CodecPCM, matroska.CodecPCMBE, matroska.CodecFloat:
+		return ".wav"
+	case matroska.CodecFLAC:
+		return ".flac"
+	case subtitles.CodecUTF8, subtitles.CodecASCII:
+		return ".srt"
+	case subtitles.CodecASS, subtitles.CodecSSA, "S_ASS", "S_SSA":
+		return ".ass"
+	case subtitles.CodecWebVTT:
+		return ".vtt"
+	}
+	if strings.HasPrefix(t.CodecID, matroska.CodecAAC) {
+		return ".aac"
+	}
+	return ""
+}
+
+// Tracks reads packets from r and writes them to outputs returned by create for each track.
+// Tracks for which create returns nil are skipped. Outputs are closed at the end.
+func Tracks(r *matroska.Reader, create func(t *matroska.TrackEntry) (io.WriteCloser, error)) (err error) {
+	type output struct {
+		w Writer
+		c io.Closer
+	}
+	outs := make(map[matroska.TrackNumber]*output)
+	defer func() {
+		for _, it := range outs {
+			if e := it.w.Close(); err == nil {
+				err = e
+			}
+			if e := it.c.Close(); err == nil {
+				err = e
+			}
+		}
+	}()
+	for _, tr := range r.Segment.Tracks {
+		for _, t := range tr.Entries {
+			f, err := create(t)
+			if err != nil {
+				return err
+			}
+			if f == nil {
+				continue
+			}
+			w, err := NewWriter(f, t)
+			if err != nil {
+				f.Close()
+				return err
+			}
+			outs[t.Number] = &output{w, f}
+		}
+	}
+	for {
+		p, err := r.ReadPacket()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if it := outs[p.Track]; it != nil {
+			if err = it.w.WritePacket(p); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// rawWriter writes packet data as is.
+type rawWriter struct {
+	w      io.Writer
+	filter matroska.PacketFilter
+}
+
+func (w *rawWriter) WritePacket(p *matroska.Packet) error {
+	if w.filter != nil {
+		c := *p
+		if err := w.filter(&c); err != nil {
+			return err
+		}
+		p = &c
+	}
+	_, err := w.w.Write(p.Data)
+	return err
+}
+
+func (w *rawWriter) Close() error {
+	return nil
+}
+
+type ivfWriter struct {
+	*ivf.Writer
+}
+
+func newIVFWriter(w io.Writer, t *matroska.TrackEntry) (Writer, error) {
+	h := &ivf.Header{
+		FourCC: map[string]string{matroska.CodecVP8: "VP80", matroska.CodecVP9: "VP90", matroska.CodecAV1: "AV01"}[t.CodecID],
+		Rate:   1000,
+		Scale:  1,
+	}
+	if t.Video != nil {
+		h.Width, h.Height = t.Video.Width, t.Video.Height
+	}
+	enc, err := ivf.NewWriter(w, h)
+	if err != nil {
+		return nil, err
+	}
+	return &ivfWriter{enc}, nil
+}
+
+func (w *ivfWriter) WritePacket(p *matroska.Packet) error {
+	return w.WriteFrame(&ivf.Frame{PTS: int64(p.Time / time.Millisecond), Data: p.Data})
+}
+
+type adtsWriter struct {
+	w      io.Writer
+	config *codec.AACConfig
+}
+
+func newADTSWriter(w io.Writer, t *matroska.TrackEntry) (Writer, error) {
+	var c *codec.AACConfig
+	if len(t.CodecPrivate) > 0 {
+		var err error
+		if c, err = codec.ParseAACConfig(t.CodecPrivate); err != nil {
+			return nil, err
+		}
+	} else {
+		c = &codec.AACConfig{ObjectType: codec.AACLC}
+		switch {
+		case strings.HasSuffix(t.CodecID, "/MAIN"):
+			c.ObjectType = codec.AACMain
+		case strings.HasSuffix(t.CodecID, "/SSR"):
+			c.ObjectType = codec.AACSSR
+		case strings.HasSuffix(t.CodecID, "/LTP"):
+			c.ObjectType = codec.AACLTP
+		}
+		if t.Audio != nil {
+			c.SampleRate, c.Channels = int(t.Audio.SamplingFreq), t.Audio.Channels
+		}
+	}
+	if c.ADTSHeader(0) == nil {
+		return nil, errors.New("extract: AAC configuration is not supported by ADTS")
+	}
+	return &adtsWriter{w: w, config: c}, nil
+}
+
+func (w *adtsWriter) WritePacket(p *matroska.Packet) error {
+	if _, err := w.w.Write(w.config.ADTSHeader(len(p.Data))); err != nil {
+		return err
+	}
+	_, err := w.w.Write(p.Data)
+	return err
+}
+
+func (w *adtsWriter) Close() error {
+	return nil
+}
+
+type wavWriter struct {
+	*wav.Writer
+	swap int
+}
+
+func newWAVWriter(w io.Writer, t *matroska.TrackEntry) (Writer, error) {
+	if t.Audio == nil {
+		return nil, errors.New("extract: no audio track settings")
+	}
+	f := &wav.Format{
+		Tag:        wav.FormatPCM,
+		Channels:   t.Audio.Channels,
+		SampleRate: int(t.Audio.SamplingFreq),
+		BitDepth:   t.Audio.BitDepth,
+	}
+	if t.CodecID == matroska.CodecFloat {
+		f.Tag = wav.FormatFloat
+	}
+	enc, err := wav.NewWriter(w, f)
+	if err != nil {
+		return nil, err
+	}
+	res := &wavWriter{Writer: enc}
+	if t.CodecID == matroska.CodecPCMBE {
+		res.swap = (f.BitDepth + 7) / 8
+	}
+	return res, nil
+}
+
+func (w *wavWriter) WritePacket(p *matroska.Packet) error {
+	b := p.Data
+	if w.swap > 1 {
+		b = make([]byte, len(p.Data))
+		for i := 0; i+w.swap <= len(b); i += w.swap {
+			for j := 0; j < w.swap; j++ {
+				b[i+j] = p.Data[i+w.swap-1-j]
+			}
+		}
+	}
+	_, err := w.Write(b)
+	return err
+}
@@ -0,0 +1,87 @@
+package extract
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/pixelbender/go-matroska/matroska"
+	"github.com/pixelbender/go-matroska/matroska/codec"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestTracks(t *testing.T) {
+	opus := &codec.OpusConfig{Channels: 2, PreSkip: 312, SampleRate: 48000}
+	seg := &matroska.Segment{Tracks: []*matroska.Track{{Entries: []*matroska.TrackEntry{
+		{Number: 1, Type: matroska.TrackTypeVideo, CodecID: matroska.CodecVP8, Video: &matroska.VideoTrack{Width: 320, Height: 240}},
+		{Number: 2, Type: matroska.TrackTypeAudio, CodecID: matroska.CodecOpus, CodecPrivate: opus.Bytes()},
+		{Number: 3, Type: matroska.TrackTypeAudio, CodecID: matroska.CodecAAC, CodecPrivate: []byte{0x12, 0x10}},
+		{Number: 4, Type: matroska.TrackTypeAudio, CodecID: matroska.CodecPCMBE, Audio: &matroska.AudioTrack{SamplingFreq: 8000, Channels: 1, BitDepth: 16}},
+	}}}}
+	packets := []*matroska.Packet{
+		{Track: 1, Time: 0, Keyframe: true, Data: []byte{1, 2, 3}},
+		{Track: 2, Time: 0, Keyframe: true, Data: []byte{0xfc, 1}},
+		{Track: 3, Time: 0, Keyframe: true, Data: []byte{0x21, 0x00}},
+		{Track: 4, Time: 0, Keyframe: true, Data: []byte{0x12, 0x34, 0x56, 0x78}},
+		{Track: 1, Time: 40 * time.Millisecond, Data: []byte{4, 5}},
+		{Track: 2, Time: 20 * time.Millisecond, Keyframe: true, DiscardPadding: 5 * time.Millisecond, Data: []byte{0xfc, 2}},
+	}
+	b := &bytes.Buffer{}
+	w, err := matroska.NewWriter(b, seg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, it := range packets {
+		if err = w.WritePacket(it); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	r, err := matroska.NewReader(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := make(map[string]*buffer)
+	err = Tracks(r, func(t *matroska.TrackEntry) (io.WriteCloser, error) {
+		f := &buffer{}
+		out[Extension(t)] = f
+		return f, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for ext, f := range out {
+		if !f.closed {
+			t.Errorf("%s: output is not closed", ext)
+		}
+	}
+	if v := out[".ivf"].Bytes(); len(v) != 32+12*2+5 || string(v[:4]) != "DKIF" || string(v[8:12]) != "VP80" || binary.LittleEndian.Uint64(v[32+12+3+4:]) != 40 {
+		t.Errorf("Unexpected IVF: %x", v)
+	}
+	if v := out[".aac"].Bytes(); !bytes.Equal(v, []byte{0xff, 0xf1, 0x50, 0x80, 0x01, 0x3f, 0xfc, 0x21, 0x00}) {
+		t.Errorf("Unexpected ADTS: %x", v)
+	}
+	if v := out[".wav"].Bytes(); len(v) != 48 || string(v[:4]) != "RIFF" || !bytes.Equal(v[44:], []byte{0x34, 0x12, 0x78, 0x56}) {
+		t.Errorf("Unexpected WAVE: %x", v)
+	}
+	v := out[".opus"].Bytes()
+	if n := bytes.Count(v, []byte("OggS")); n != 3 {
+		t.Fatalf("Unexpected number of pages: %d", n)
+	}
+	last := v[bytes.LastIndex(v, []byte("OggS")):]
+	if last[5] != 0x04 || binary.LittleEndian.Uint64(last[6:]) != 2*960-240 {
+		t.Errorf("Unexpected last page: %x", last)
+	}
+}
+
+type buffer struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (b *buffer) Close() error {
+	b.closed = true
+	return nil
+}
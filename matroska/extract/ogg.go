@@ -0,0 +1,95 @@
+package extract
+
+import (
+	"encoding/binary"
+	"errors"
+	"github.com/pixelbender/go-matroska/matroska"
+	"github.com/pixelbender/go-matroska/matroska/codec"
+	"github.com/pixelbender/go-matroska/matroska/ogg"
+	"io"
+	"time"
+)
+
+// oggWriter writes Opus and Vorbis packets into the Ogg bitstream.
+// Packets are written one behind to compute granule positions of Vorbis packets and apply DiscardPadding.
+type oggWriter struct {
+	w       *ogg.Writer
+	opus    bool
+	rate    int64
+	base    time.Duration
+	pending *matroska.Packet
+}
+
+func newOggWriter(w io.Writer, t *matroska.TrackEntry) (Writer, error) {
+	if len(t.CodecPrivate) == 0 {
+		return nil, errors.New("extract: no codec private data")
+	}
+	res := &oggWriter{w: ogg.NewWriter(w, uint32(t.ID))}
+	var headers [][]byte
+	if t.CodecID == matroska.CodecOpus {
+		if _, err := codec.ParseOpusConfig(t.CodecPrivate); err != nil {
+			return nil, err
+		}
+		vendor := "go-matroska"
+		tags := make([]byte, 8+4+len(vendor)+4)
+		copy(tags, "OpusTags")
+		binary.LittleEndian.PutUint32(tags[8:], uint32(len(vendor)))
+		copy(tags[12:], vendor)
+		headers = [][]byte{t.CodecPrivate, tags}
+		res.opus, res.rate = true, 48000
+	} else {
+		c, err := codec.ParseVorbisConfig(t.CodecPrivate)
+		if err != nil {
+			return nil, err
+		}
+		headers, res.rate = c.Headers, int64(c.SampleRate)
+	}
+	for i, it := range headers {
+		if err := res.w.WritePacket(it, 0); err != nil {
+			return nil, err
+		}
+		// The first header is alone on the first page, others end on a separate page
+		if i == 0 || i == len(headers)-1 {
+			if err := res.w.Flush(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return res, nil
+}
+
+func (w *oggWriter) WritePacket(p *matroska.Packet) error {
+	if w.pending == nil {
+		w.base = p.Time
+	} else if err := w.write(w.pending, p); err != nil {
+		return err
+	}
+	w.pending = p
+	return nil
+}
+
+func (w *oggWriter) Close() error {
+	if w.pending != nil {
+		if err := w.write(w.pending, nil); err != nil {
+			return err
+		}
+	}
+	return w.w.Close()
+}
+
+// write writes the packet with the granule position at its end.
+func (w *oggWriter) write(p, next *matroska.Packet) error {
+	end := p.Time + p.Duration
+	if w.opus {
+		if d := codec.OpusPacketDuration(p.Data); d > 0 {
+			end = p.Time + d
+		}
+	} else if next != nil {
+		end = next.Time
+	}
+	if next == nil {
+		end -= p.DiscardPadding
+	}
+	granule := int64(end-w.base) * w.rate / int64(time.Second)
+	return w.w.WritePacket(p.Data, granule)
+}
@@ -0,0 +1,118 @@
+package matroska
+
+import (
+	"bytes"
+	"errors"
+	"github.com/pixelbender/go-matroska/matroska/codec"
+)
+
+// PacketFilter modifies packets of a track, see Reader.Filters and Writer.Filters.
+type PacketFilter func(p *Packet) error
+
+// NewAnnexBFilter returns a filter converting length-prefixed NAL units of
+// V_MPEG4/ISO/AVC and V_MPEGH/ISO/HEVC tracks to the Annex B byte stream format.
+// Parameter sets of CodecPrivate are inserted before keyframes not containing them.
+func NewAnnexBFilter(t *TrackEntry) (PacketFilter, error) {
+	f, err := newNALFormat(t)
+	if err != nil {
+		return nil, err
+	}
+	return func(p *Packet) error {
+		list, err := codec.SplitNALUs(p.Data, f.size)
+		if err != nil {
+			return err
+		}
+		b := make([]byte, 0, len(p.Data)+len(list)*4+128)
+		if p.Keyframe && !f.hasParams(list) {
+			b = codec.AppendAnnexB(b, f.params...)
+		}
+		p.Data = codec.AppendAnnexB(b, list...)
+		return nil
+	}, nil
+}
+
+// NewLengthPrefixFilter returns a filter converting Annex B packets of
+// V_MPEG4/ISO/AVC and V_MPEGH/ISO/HEVC tracks to length-prefixed NAL units.
+// Access unit delimiters and parameter sets equal to ones of CodecPrivate are removed.
+func NewLengthPrefixFilter(t *TrackEntry) (PacketFilter, error) {
+	f, err := newNALFormat(t)
+	if err != nil {
+		return nil, err
+	}
+	return func(p *Packet) error {
+		b := make([]byte, 0, len(p.Data)+16)
+		for _, it := range codec.SplitAnnexB(p.Data) {
+			if f.isAUD(it) || f.isParam(it) && f.known(it) {
+				continue
+			}
+			b = codec.AppendNALUs(b, f.size, it)
+		}
+		p.Data = b
+		return nil
+	}, nil
+}
+
+// nalFormat describes NAL units of AVC and HEVC tracks.
+type nalFormat struct {
+	hevc   bool
+	size   int
+	params [][]byte
+}
+
+func newNALFormat(t *TrackEntry) (*nalFormat, error) {
+	v, err := t.CodecConfig()
+	if err != nil {
+		return nil, err
+	}
+	switch c := v.(type) {
+	case *codec.AVCConfig:
+		return &nalFormat{size: c.LengthSize, params: append(append([][]byte{}, c.SPS...), c.PPS...)}, nil
+	case *codec.HEVCConfig:
+		params := append(append(append([][]byte{}, c.VPS...), c.SPS...), c.PPS...)
+		return &nalFormat{hevc: true, size: c.LengthSize, params: params}, nil
+	}
+	return nil, errors.New("matroska: codec " + t.CodecID + " has no NAL units")
+}
+
+func (f *nalFormat) kind(b []byte) int {
+	if len(b) == 0 {
+		return -1
+	}
+	if f.hevc {
+		return int(b[0] >> 1 & 0x3f)
+	}
+	return int(b[0] & 0x1f)
+}
+
+func (f *nalFormat) isParam(b []byte) bool {
+	k := f.kind(b)
+	if f.hevc {
+		return k >= codec.HEVCNALVPS && k <= codec.HEVCNALPPS
+	}
+	return k == codec.AVCNALSPS || k == codec.AVCNALPPS
+}
+
+func (f *nalFormat) isAUD(b []byte) bool {
+	if f.hevc {
+		return f.kind(b) == codec.HEVCNALAUD
+	}
+	return f.kind(b) == codec.AVCNALAUD
+}
+
+func (f *nalFormat) hasParams(list [][]byte) bool {
+	for _, it := range list {
+		if f.isParam(it) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *nalFormat) known(b []byte) bool {
+	for _, it := range f.params {
+		if bytes.Equal(it, b) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+package matroska
+
+import (
+	"bytes"
+	"github.com/pixelbender/go-matroska/matroska/codec"
+	"testing"
+	"time"
+)
+
+var (
+	testSPS = []byte{0x67, 0x42, 0xc0, 0x1e, 0xf4, 0x0a, 0x0f, 0xc8}
+	testPPS = []byte{0x68, 0xce, 0x3c, 0x80}
+)
+
+func TestAnnexBFilters(t *testing.T) {
+	c, err := codec.AVCConfigFromAnnexB(codec.AppendAnnexB(nil, testSPS, testPPS))
+	if err != nil {
+		t.Fatal(err)
+	}
+	seg := &Segment{Tracks: []*Track{{Entries: []*TrackEntry{{
+		Number:       1,
+		Type:         TrackTypeVideo,
+		CodecID:      CodecAVC,
+		CodecPrivate: c.Bytes(),
+		Video:        &VideoTrack{Width: c.Width, Height: c.Height},
+	}}}}}
+	track := seg.Tracks[0].Entries[0]
+	aud := []byte{0x09, 0xf0}
+	idr := []byte{0x65, 0x88, 0x84, 0x00, 0x21}
+	slice := []byte{0x41, 0x9a, 0x02}
+	in := []*Packet{
+		{Track: 1, Time: 0, Keyframe: true, Data: codec.AppendAnnexB(nil, aud, testSPS, testPPS, idr)},
+		{Track: 1, Time: 40 * time.Millisecond, Data: codec.AppendAnnexB(nil, aud, slice)},
+	}
+	out := &bytes.Buffer{}
+	mux, err := NewLengthPrefixFilter(track)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := NewWriter(out, seg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Filters = map[TrackNumber]PacketFilter{1: mux}
+	for _, it := range in {
+		if err = w.WritePacket(it); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewReader(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	demux, err := NewAnnexBFilter(r.Segment.Track(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []struct {
+		stored, annexB []byte
+	}{
+		{codec.AppendNALUs(nil, 4, idr), codec.AppendAnnexB(nil, testSPS, testPPS, idr)},
+		{codec.AppendNALUs(nil, 4, slice), codec.AppendAnnexB(nil, slice)},
+	} {
+		p, err := r.ReadPacket()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(p.Data, want.stored) {
+			t.Errorf("Unexpected packet %d: %x, want: %x", i, p.Data, want.stored)
+		}
+		if err = demux(p); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(p.Data, want.annexB) {
+			t.Errorf("Unexpected Annex B packet %d: %x, want: %x", i, p.Data, want.annexB)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package matroska
+
+import (
+	"io"
+	"time"
+)
+
+// Finalize rewrites the Matroska stream of unknown duration, like WebM recorded by MediaRecorder,
+// into a seekable file with known element sizes, the duration, the SeekHead and Cues.
+// The duration of the last packet of each track is the interval between its last two packets.
+func Finalize(in io.Reader, out io.WriteSeeker) error {
+	r, err := NewReader(in)
+	if err != nil {
+		return err
+	}
+	seg := &Segment{
+		Tracks:      r.Segment.Tracks,
+		Chapters:    r.Segment.Chapters,
+		Attachments: r.Segment.Attachments,
+	}
+	for _, it := range r.Segment.Info {
+		c := *it
+		c.Duration = 0
+		seg.Info = append(seg.Info, &c)
+	}
+	w, err := NewWriter(out, seg, &WriterOptions{DocType: r.EBML.DocType})
+	if err != nil {
+		return err
+	}
+	last := make(map[TrackNumber]*Packet)
+	gap := make(map[TrackNumber]time.Duration)
+	var end time.Duration
+	for {
+		p, err := r.ReadPacket()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return err
+		}
+		if prev := last[p.Track]; prev != nil && p.Time > prev.Time {
+			gap[p.Track] = p.Time - prev.Time
+		}
+		last[p.Track] = p
+		d := p.Duration
+		if d == 0 {
+			d = gap[p.Track]
+		}
+		if p.Time+d > end {
+			end = p.Time + d
+		}
+		if err = w.WritePacket(p); err != nil {
+			return err
+		}
+	}
+	if end > w.end {
+		w.end = end
+	}
+	seg.Tags = r.Segment.Tags
+	return w.Close()
+}
@@ -0,0 +1,97 @@
+package matroska
+
+import (
+	"bytes"
+	"github.com/pixelbender/go-matroska/ebml"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFinalize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "matroska")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	seg := &Segment{Tracks: []*Track{{Entries: []*TrackEntry{
+		{Number: 1, Type: TrackTypeVideo, CodecID: CodecVP8, Enabled: true, Default: true, Video: &VideoTrack{Width: 320, Height: 240}},
+		{Number: 2, Type: TrackTypeAudio, CodecID: CodecOpus, Enabled: true, Default: true, Audio: &AudioTrack{SamplingFreq: 48000, Channels: 2}},
+	}}}}
+	// The recording of MediaRecorder with Clusters of unknown size
+	b := &bytes.Buffer{}
+	if _, err = NewWriter(b, seg, &WriterOptions{DocType: "webm"}); err != nil {
+		t.Fatal(err)
+	}
+	enc := ebml.NewWriter(b)
+	for i := 0; i < 4; i++ {
+		if err = enc.WriteElementHeader(uint32(IDCluster), -1); err != nil {
+			t.Fatal(err)
+		}
+		if err = enc.EncodeElement(0xE7, int64(i*1000)); err != nil {
+			t.Fatal(err)
+		}
+		for j := 0; j < 50; j++ {
+			audio := &Block{TrackNumber: 2, Timecode: int16(j * 20), Flags: BlockFlagKeyframe, Frames: [][]byte{{0xfc}}}
+			if j%2 == 0 {
+				flags := uint8(0)
+				if j == 0 {
+					flags = BlockFlagKeyframe
+				}
+				err = enc.EncodeElement(0xA3, &Block{TrackNumber: 1, Timecode: int16(j * 20), Flags: flags, Frames: [][]byte{{byte(j)}}})
+			}
+			if err == nil {
+				err = enc.EncodeElement(0xA3, audio)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	file := filepath.Join(dir, "finalized.webm")
+	out, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	if err = Finalize(bytes.NewReader(b.Bytes()), out); err != nil {
+		t.Fatal(err)
+	}
+	doc, err := Decode(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := doc.Segment
+	if len(s.Info) != 1 || s.Info[0].Duration != 4000 {
+		t.Errorf("Unexpected info: %s", dump(s.Info))
+	}
+	if len(s.Cluster) != 1 || len(s.Cues) != 4 || len(s.SeekHead) != 1 {
+		t.Errorf("Unexpected clusters: %d, cues: %d, seek heads: %d", len(s.Cluster), len(s.Cues), len(s.SeekHead))
+	}
+	if err = checkSeekHead(file); err != nil {
+		t.Error(err)
+	}
+	if _, err = out.Seek(0, 0); err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewReader(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for {
+		p, err := r.ReadPacket()
+		if err != nil {
+			break
+		}
+		if p.Track == 1 && p.Keyframe != (p.Time%time.Second == 0) {
+			t.Errorf("Unexpected packet: %+v", p)
+		}
+		n++
+	}
+	if n != 300 {
+		t.Errorf("Unexpected number of packets: %d", n)
+	}
+}
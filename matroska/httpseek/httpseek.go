@@ -0,0 +1,222 @@
+// Package httpseek implements io.ReadSeeker over HTTP range requests,
+// so metadata of remote files can be parsed without downloading them.
+package httpseek
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Default cache settings
+const (
+	DefaultBlockSize = 64 << 10
+	DefaultMaxBlocks = 16
+)
+
+// Reader reads the remote file in blocks of fixed size using range requests.
+// Recently read blocks are cached.
+type Reader struct {
+	Client *http.Client
+	URL    string
+	// Size is the size of the remote file
+	Size int64
+
+	block  int
+	max    int
+	etag   string
+	off    int64
+	blocks map[int64][]byte
+	recent []int64
+}
+
+// NewReader requests the first block of the file at url and returns a new reader.
+// The client is http.DefaultClient if nil.
+// Blocks of the given size are cached up to max blocks, zero values use defaults.
+func NewReader(client *http.Client, url string, block, max int) (*Reader, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if block <= 0 {
+		block = DefaultBlockSize
+	}
+	if max <= 0 {
+		max = DefaultMaxBlocks
+	}
+	r := &Reader{Client: client, URL: url, Size: -1, block: block, max: max, blocks: make(map[int64][]byte)}
+	if _, err := r.load(0); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Read reads up to len(b) bytes from the current block.
+func (r *Reader) Read(b []byte) (int, error) {
+	if r.off >= r.Size {
+		return 0, io.EOF
+	}
+	i := r.off / int64(r.block)
+	v, err := r.load(i)
+	if err != nil {
+		return 0, err
+	}
+	pos := r.off - i*int64(r.block)
+	if pos >= int64(len(v)) {
+		return 0, io.ErrUnexpectedEOF
+	}
+	n := copy(b, v[pos:])
+	r.off += int64(n)
+	return n, nil
+}
+
+// Seek sets the offset of the next Read.
+func (r *Reader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.off
+	case io.SeekEnd:
+		offset += r.Size
+	default:
+		return 0, errors.New("httpseek: invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("httpseek: negative position")
+	}
+	r.off = offset
+	return offset, nil
+}
+
+// load returns the cached block or requests it.
+// The rest of the block is requested again if the server returns a shorter range.
+func (r *Reader) load(i int64) ([]byte, error) {
+	if b, ok := r.blocks[i]; ok {
+		r.touch(i)
+		return b, nil
+	}
+	start := i * int64(r.block)
+	var b []byte
+	for {
+		end := start + int64(r.block) - 1
+		if r.Size >= 0 && end >= r.Size {
+			end = r.Size - 1
+		}
+		off := start + int64(len(b))
+		if off > end {
+			break
+		}
+		v, err := r.fetch(off, end)
+		if err != nil {
+			return nil, err
+		}
+		if len(v) == 0 {
+			return nil, nil
+		}
+		b = append(b, v...)
+	}
+	if len(r.recent) >= r.max {
+		delete(r.blocks, r.recent[0])
+		r.recent = r.recent[1:]
+	}
+	r.blocks[i] = b
+	r.recent = append(r.recent, i)
+	return b, nil
+}
+
+// fetch requests the range from start to end and returns the received part of it.
+func (r *Reader) fetch(start, end int64) ([]byte, error) {
+	req, err := http.NewRequest("GET", r.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+	if r.etag != "" {
+		req.Header.Set("If-Range", r.etag)
+	}
+	res, err := r.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	switch res.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusRequestedRangeNotSatisfiable:
+		if r.Size < 0 {
+			// Empty file
+			r.Size = 0
+			return nil, nil
+		}
+		fallthrough
+	case http.StatusOK:
+		if r.Size >= 0 {
+			return nil, errors.New("httpseek: file has changed")
+		}
+		return nil, errors.New("httpseek: range requests are not supported")
+	default:
+		return nil, fmt.Errorf("httpseek: unexpected status %s", res.Status)
+	}
+	last, size, err := parseContentRange(res.Header.Get("Content-Range"), start)
+	if err != nil {
+		return nil, err
+	}
+	if r.Size < 0 {
+		r.Size, r.etag = size, res.Header.Get("ETag")
+	} else if size != r.Size {
+		return nil, errors.New("httpseek: file has changed")
+	}
+	if last > end {
+		return nil, errors.New("httpseek: invalid content range")
+	}
+	b := make([]byte, last-start+1)
+	if _, err = io.ReadFull(res.Body, b); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	io.Copy(ioutil.Discard, res.Body)
+	return b, nil
+}
+
+// touch moves the block to the end of the recently used list.
+func (r *Reader) touch(i int64) {
+	for j, it := range r.recent {
+		if it == i {
+			copy(r.recent[j:], r.recent[j+1:])
+			r.recent[len(r.recent)-1] = i
+			return
+		}
+	}
+}
+
+// parseContentRange returns the last byte position and the complete length
+// from the Content-Range header of the range starting at start.
+func parseContentRange(v string, start int64) (end, size int64, err error) {
+	errRange := errors.New("httpseek: invalid content range")
+	if !strings.HasPrefix(v, "bytes ") {
+		return 0, 0, errRange
+	}
+	v = v[6:]
+	i, j := strings.IndexByte(v, '-'), strings.IndexByte(v, '/')
+	if i < 0 || j < i {
+		return 0, 0, errRange
+	}
+	if v[j+1:] == "*" {
+		return 0, 0, errors.New("httpseek: unknown file size")
+	}
+	first, err := strconv.ParseInt(v[:i], 10, 64)
+	if err != nil || first != start {
+		return 0, 0, errRange
+	}
+	if end, err = strconv.ParseInt(v[i+1:j], 10, 64); err != nil || end < start {
+		return 0, 0, errRange
+	}
+	if size, err = strconv.ParseInt(v[j+1:], 10, 64); err != nil || end >= size {
+		return 0, 0, errRange
+	}
+	return end, size, nil
+}
@@ -0,0 +1,135 @@
+package httpseek
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/pixelbender/go-matroska/matroska"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestReader(t *testing.T) {
+	b := make([]byte, 10000)
+	for i := range b {
+		b[i] = byte(i * 7)
+	}
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		http.ServeContent(w, r, "file", time.Time{}, bytes.NewReader(b))
+	}))
+	defer srv.Close()
+	r, err := NewReader(nil, srv.URL, 1000, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Size != int64(len(b)) {
+		t.Fatalf("Unexpected size: %d", r.Size)
+	}
+	for _, it := range []struct{ off, n int }{{0, 100}, {900, 300}, {9950, 50}, {500, 2000}, {1500, 10}} {
+		v := make([]byte, it.n)
+		if _, err = r.Seek(int64(it.off), io.SeekStart); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = io.ReadFull(r, v); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(v, b[it.off:it.off+it.n]) {
+			t.Errorf("Unexpected data at %d", it.off)
+		}
+	}
+	// Blocks 0, 1, 9 and 2 are requested once
+	if requests != 4 {
+		t.Errorf("Unexpected number of requests: %d", requests)
+	}
+	// The least recently used block 9 is evicted
+	if _, err = r.Seek(-1, io.SeekEnd); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := r.Read(make([]byte, 10)); err != nil || n != 1 || requests != 5 {
+		t.Fatal(n, err, requests)
+	}
+	if _, err = r.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("Expected EOF, got %v", err)
+	}
+}
+
+func TestCappedRanges(t *testing.T) {
+	b := make([]byte, 2600)
+	for i := range b {
+		b[i] = byte(i * 7)
+	}
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		var start, end int
+		if _, err := fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-%d", &start, &end); err == nil && end-start >= 500 {
+			r.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, start+499))
+		}
+		http.ServeContent(w, r, "file", time.Time{}, bytes.NewReader(b))
+	}))
+	defer srv.Close()
+	r, err := NewReader(nil, srv.URL, 1000, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(v, b) {
+		t.Error("Unexpected data")
+	}
+	// Blocks 0 and 1 are requested in two ranges and block 2 in two ranges of 500 and 100 bytes
+	if requests != 6 {
+		t.Errorf("Unexpected number of requests: %d", requests)
+	}
+}
+
+func TestMatroska(t *testing.T) {
+	f, err := ioutil.TempFile("", "httpseek")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	seg := &matroska.Segment{Tracks: []*matroska.Track{{Entries: []*matroska.TrackEntry{
+		{Number: 1, Type: matroska.TrackTypeVideo, CodecID: matroska.CodecVP8, Enabled: true, Default: true, Video: &matroska.VideoTrack{Width: 320, Height: 240}},
+	}}}}
+	w, err := matroska.NewWriter(f, seg, &matroska.WriterOptions{DocType: "webm", ClusterDuration: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		p := &matroska.Packet{Track: 1, Time: time.Duration(i) * 40 * time.Millisecond, Keyframe: i%25 == 0, Data: make([]byte, 1000)}
+		if err = w.WritePacket(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "file.webm", time.Time{}, f)
+	}))
+	defer srv.Close()
+	r, err := NewReader(srv.Client(), srv.URL, 4096, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec, err := matroska.NewReader(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dec.Segment.Tracks) != 1 || dec.Segment.Tracks[0].Entries[0].Video.Width != 320 {
+		t.Errorf("Unexpected tracks: %+v", dec.Segment.Tracks)
+	}
+	if len(r.blocks) != 1 {
+		t.Errorf("Unexpected number of blocks read: %d", len(r.blocks))
+	}
+}
@@ -0,0 +1,169 @@
+// Package ivf implements the IVF container of VP8, VP9 and AV1 video frames.
+package ivf
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+)
+
+const (
+	headerSize      = 32
+	frameHeaderSize = 12
+	// maxFrameSize limits the size of frames read from damaged or malicious input.
+	maxFrameSize = 256 << 20
+)
+
+// Header is the IVF file header.
+type Header struct {
+	FourCC string // VP80, VP90 or AV01
+	Width  int
+	Height int
+	// Time base of frame timestamps is Scale/Rate seconds
+	Rate   int
+	Scale  int
+	Frames int
+}
+
+// Frame is a single compressed video frame.
+type Frame struct {
+	PTS  int64 // Presentation timestamp in the time base
+	Data []byte
+}
+
+// Reader reads frames from the IVF file.
+type Reader struct {
+	Header *Header
+
+	r io.Reader
+}
+
+// NewReader reads the IVF header from r and returns a new reader.
+func NewReader(r io.Reader) (*Reader, error) {
+	b := make([]byte, headerSize)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, err
+	}
+	if string(b[:4]) != "DKIF" {
+		return nil, errors.New("ivf: invalid signature")
+	}
+	n := int(binary.LittleEndian.Uint16(b[6:]))
+	if n < headerSize {
+		return nil, errors.New("ivf: invalid header size")
+	}
+	if _, err := io.CopyN(ioutil.Discard, r, int64(n-headerSize)); err != nil {
+		return nil, err
+	}
+	h := &Header{
+		FourCC: string(b[8:12]),
+		Width:  int(binary.LittleEndian.Uint16(b[12:])),
+		Height: int(binary.LittleEndian.Uint16(b[14:])),
+		Rate:   int(binary.LittleEndian.Uint32(b[16:])),
+		Scale:  int(binary.LittleEndian.Uint32(b[20:])),
+		Frames: int(binary.LittleEndian.Uint32(b[24:])),
+	}
+	if h.Rate == 0 || h.Scale == 0 {
+		return nil, errors.New("ivf: invalid time base")
+	}
+	return &Reader{Header: h, r: r}, nil
+}
+
+// ReadFrame reads the next frame. Returns io.EOF at the end of the file.
+func (r *Reader) ReadFrame() (*Frame, error) {
+	var b [frameHeaderSize]byte
+	if _, err := io.ReadFull(r.r, b[:]); err != nil {
+		return nil, err
+	}
+	n := binary.LittleEndian.Uint32(b[:])
+	if n > maxFrameSize {
+		return nil, errors.New("ivf: frame size too large")
+	}
+	f := &Frame{
+		PTS:  int64(binary.LittleEndian.Uint64(b[4:])),
+		Data: make([]byte, n),
+	}
+	if _, err := io.ReadFull(r.r, f.Data); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Writer writes frames into the IVF file.
+type Writer struct {
+	Header *Header
+
+	w      io.Writer
+	seek   io.WriteSeeker
+	base   int64
+	frames int
+}
+
+// NewWriter writes the IVF header to w and returns a new writer.
+// The number of frames is updated by Close if w is an io.WriteSeeker.
+func NewWriter(w io.Writer, h *Header) (*Writer, error) {
+	if len(h.FourCC) != 4 {
+		return nil, errors.New("ivf: invalid fourcc")
+	}
+	if h.Rate == 0 || h.Scale == 0 {
+		return nil, errors.New("ivf: invalid time base")
+	}
+	wr := &Writer{Header: h, w: w}
+	if s, ok := w.(io.WriteSeeker); ok {
+		if off, err := s.Seek(0, io.SeekCurrent); err == nil {
+			wr.seek, wr.base = s, off
+		}
+	}
+	if _, err := w.Write(h.bytes()); err != nil {
+		return nil, err
+	}
+	return wr, nil
+}
+
+// WriteFrame writes the frame.
+func (w *Writer) WriteFrame(f *Frame) error {
+	var b [frameHeaderSize]byte
+	binary.LittleEndian.PutUint32(b[:], uint32(len(f.Data)))
+	binary.LittleEndian.PutUint64(b[4:], uint64(f.PTS))
+	if _, err := w.w.Write(b[:]); err != nil {
+		return err
+	}
+	if _, err := w.w.Write(f.Data); err != nil {
+		return err
+	}
+	w.frames++
+	return nil
+}
+
+// Close updates the number of frames in the header if the output is seekable.
+func (w *Writer) Close() error {
+	if w.seek == nil {
+		return nil
+	}
+	w.Header.Frames = w.frames
+	end, err := w.seek.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err = w.seek.Seek(w.base, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err = w.seek.Write(w.Header.bytes()); err != nil {
+		return err
+	}
+	_, err = w.seek.Seek(end, io.SeekStart)
+	return err
+}
+
+func (h *Header) bytes() []byte {
+	b := make([]byte, headerSize)
+	copy(b, "DKIF")
+	binary.LittleEndian.PutUint16(b[6:], headerSize)
+	copy(b[8:], h.FourCC)
+	binary.LittleEndian.PutUint16(b[12:], uint16(h.Width))
+	binary.LittleEndian.PutUint16(b[14:], uint16(h.Height))
+	binary.LittleEndian.PutUint32(b[16:], uint32(h.Rate))
+	binary.LittleEndian.PutUint32(b[20:], uint32(h.Scale))
+	binary.LittleEndian.PutUint32(b[24:], uint32(h.Frames))
+	return b
+}
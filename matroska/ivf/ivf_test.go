@@ -0,0 +1,99 @@
+package ivf
+
+import (
+	"bytes"
+	"github.com/pixelbender/go-matroska/matroska"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestTrack(t *testing.T) {
+	b := &bytes.Buffer{}
+	w, err := NewWriter(b, &Header{FourCC: "VP80", Width: 320, Height: 240, Rate: 25, Scale: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 50; i++ {
+		// Bit 0 of the frame tag is cleared for key frames
+		data := []byte{0x11, byte(i)}
+		if i%25 == 0 {
+			data[0] = 0x10
+		}
+		if err = w.WriteFrame(&Frame{PTS: int64(i), Data: data}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewReader(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h := r.Header; h.FourCC != "VP80" || h.Width != 320 || h.Height != 240 || h.Rate != 25 || h.Scale != 1 {
+		t.Fatalf("Unexpected header: %+v", h)
+	}
+	tr, err := NewTrack(r, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := tr.Entry; e.CodecID != matroska.CodecVP8 || e.DefaultDuration != 40*time.Millisecond || e.Video.Width != 320 {
+		t.Fatalf("Unexpected track entry: %+v", e)
+	}
+	out := &bytes.Buffer{}
+	mw, err := matroska.NewWriter(out, &matroska.Segment{Tracks: []*matroska.Track{{Entries: []*matroska.TrackEntry{tr.Entry}}}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		p, err := tr.ReadPacket()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = mw.WritePacket(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	mr, err := matroska.NewReader(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; ; i++ {
+		p, err := mr.ReadPacket()
+		if err == io.EOF {
+			if i != 50 {
+				t.Errorf("Unexpected number of packets: %d", i)
+			}
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Time != time.Duration(i)*40*time.Millisecond || p.Keyframe != (i%25 == 0) || p.Data[1] != byte(i) {
+			t.Errorf("Unexpected packet %d: %+v", i, p)
+		}
+	}
+}
+
+func TestReadFrameSize(t *testing.T) {
+	b := &bytes.Buffer{}
+	if _, err := NewWriter(b, &Header{FourCC: "VP80", Width: 320, Height: 240, Rate: 25, Scale: 1}); err != nil {
+		t.Fatal(err)
+	}
+	// Frame header of 4 GiB without data
+	b.Write([]byte{0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0, 0, 0, 0, 0})
+	r, err := NewReader(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = r.ReadFrame(); err == nil || err == io.ErrUnexpectedEOF {
+		t.Errorf("Expected error for the large frame, got %v", err)
+	}
+}
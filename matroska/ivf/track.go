@@ -0,0 +1,91 @@
+package ivf
+
+import (
+	"errors"
+	"github.com/pixelbender/go-matroska/matroska"
+	"github.com/pixelbender/go-matroska/matroska/codec"
+	"io"
+	"time"
+)
+
+var codecs = map[string]string{
+	"VP80": matroska.CodecVP8,
+	"VP90": matroska.CodecVP9,
+	"AV01": matroska.CodecAV1,
+}
+
+// Track reads frames of the IVF file as packets of the Matroska track.
+type Track struct {
+	Entry *matroska.TrackEntry
+
+	r    *Reader
+	next *Frame
+}
+
+// NewTrack returns the track with the given number reading frames from r.
+// The first frame is read ahead to fill the codec private data of AV1 tracks.
+// Frames must be in decoding order with presentation timestamps.
+func NewTrack(r *Reader, number matroska.TrackNumber) (*Track, error) {
+	h := r.Header
+	id, ok := codecs[h.FourCC]
+	if !ok {
+		return nil, errors.New("ivf: unsupported fourcc " + h.FourCC)
+	}
+	e := &matroska.TrackEntry{
+		Number:         number,
+		Type:           matroska.TrackTypeVideo,
+		Enabled:        true,
+		Default:        true,
+		CodecDecodeAll: true,
+		CodecID:        id,
+		Video:          &matroska.VideoTrack{Width: h.Width, Height: h.Height},
+	}
+	// Time base of most files is the frame rate
+	if d := time.Duration(h.Scale) * time.Second / time.Duration(h.Rate); d >= time.Second/240 {
+		e.DefaultDuration = d
+	}
+	t := &Track{Entry: e, r: r}
+	f, err := r.ReadFrame()
+	if err != nil && (err != io.EOF || id == matroska.CodecAV1) {
+		return nil, err
+	}
+	t.next = f
+	if id == matroska.CodecAV1 {
+		c, err := codec.AV1ConfigFromOBUs(f.Data)
+		if err != nil {
+			return nil, err
+		}
+		e.CodecPrivate = c.Bytes()
+	}
+	return t, nil
+}
+
+// ReadPacket reads the next packet. Returns io.EOF at the end of the file.
+func (t *Track) ReadPacket() (*matroska.Packet, error) {
+	f := t.next
+	if f != nil {
+		t.next = nil
+	} else {
+		var err error
+		if f, err = t.r.ReadFrame(); err != nil {
+			return nil, err
+		}
+	}
+	h := t.r.Header
+	p := &matroska.Packet{
+		Track:    t.Entry.Number,
+		Time:     time.Duration(f.PTS) * time.Second * time.Duration(h.Scale) / time.Duration(h.Rate),
+		Duration: t.Entry.DefaultDuration,
+		Data:     f.Data,
+	}
+	switch t.Entry.CodecID {
+	case matroska.CodecVP8:
+		p.Keyframe = codec.VP8Keyframe(f.Data)
+	case matroska.CodecVP9:
+		p.Keyframe = codec.VP9Keyframe(f.Data)
+	case matroska.CodecAV1:
+		p.Keyframe = codec.AV1Keyframe(f.Data)
+		p.Data = codec.AV1TrimTemporalDelimiters(f.Data)
+	}
+	return p, nil
+}
@@ -0,0 +1,251 @@
+// Package live serves Matroska streams to HTTP clients as live WebM.
+package live
+
+import (
+	"bytes"
+	"errors"
+	"github.com/pixelbender/go-matroska/ebml"
+	"github.com/pixelbender/go-matroska/matroska"
+	"io"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// PacketReader is a source of packets, like matroska.Reader.
+type PacketReader interface {
+	ReadPacket() (*matroska.Packet, error)
+}
+
+// Handler streams packets to HTTP clients as WebM with unknown-size Segment and Clusters.
+// Blocks are sent as soon as packets are written.
+// Clusters start with a keyframe of the video track or of any track if there is no video.
+// New clients receive the stream header and start at the next such Cluster.
+type Handler struct {
+	// Buffer is the number of pending writes before a slow client is dropped, 256 if zero.
+	Buffer int
+	// ClusterDuration is the minimum duration of Clusters, 1 second if zero.
+	ClusterDuration time.Duration
+
+	mu      sync.Mutex
+	init    []byte
+	seg     *matroska.Segment
+	scale   time.Duration
+	video   bool
+	cluster int64
+	last    map[matroska.TrackNumber]int64
+	started bool
+	closed  bool
+	buf     bytes.Buffer
+	clients map[*client]bool
+}
+
+type client struct {
+	ch      chan []byte
+	started bool
+}
+
+// NewHandler returns a new handler of the tracks and timecode scale of seg.
+func NewHandler(seg *matroska.Segment) (*Handler, error) {
+	h := &Handler{last: make(map[matroska.TrackNumber]int64), clients: make(map[*client]bool)}
+	s := &matroska.Segment{Tracks: seg.Tracks}
+	for _, it := range seg.Info {
+		c := *it
+		c.Duration = 0
+		s.Info = append(s.Info, &c)
+	}
+	for _, t := range s.Tracks {
+		for _, it := range t.Entries {
+			h.video = h.video || it.Type == matroska.TrackTypeVideo
+		}
+	}
+	if _, err := matroska.NewWriter(&h.buf, s, &matroska.WriterOptions{DocType: "webm"}); err != nil {
+		return nil, err
+	}
+	h.seg, h.scale, h.init = s, s.TimecodeScale(), h.take()
+	return h, nil
+}
+
+// Run writes packets read from r until the end of the stream and closes the handler.
+func (h *Handler) Run(r PacketReader) error {
+	for {
+		p, err := r.ReadPacket()
+		if err != nil {
+			h.Close()
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if err = h.WritePacket(p); err != nil {
+			h.Close()
+			return err
+		}
+	}
+}
+
+// WritePacket sends the packet to clients.
+// Packets preceding the first keyframe are skipped.
+func (h *Handler) WritePacket(p *matroska.Packet) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return errors.New("live: handler is closed")
+	}
+	t := h.seg.Track(p.Track)
+	if t == nil {
+		return errors.New("live: unknown track")
+	}
+	tc := int64(p.Time / h.scale)
+	key := p.Keyframe && (t.Type == matroska.TrackTypeVideo || !h.video)
+	if !h.started && !key {
+		return nil
+	}
+	dur := h.ClusterDuration
+	if dur <= 0 {
+		dur = time.Second
+	}
+	d := tc - h.cluster
+	start := key && (!h.started || time.Duration(d)*h.scale >= dur)
+	split := start || d < math.MinInt16 || d > math.MaxInt16
+	enc := ebml.NewWriter(&h.buf)
+	if split {
+		h.started, h.cluster, d = true, tc, 0
+		if err := enc.WriteElementHeader(uint32(matroska.IDCluster), -1); err != nil {
+			return err
+		}
+		if err := enc.EncodeElement(0xE7, tc); err != nil {
+			return err
+		}
+	}
+	b := &matroska.Block{
+		TrackNumber: p.Track,
+		Timecode:    int16(d),
+		Frames:      [][]byte{p.Data},
+	}
+	var err error
+	if p.Duration != 0 && p.Duration != t.DefaultDuration || p.DiscardPadding != 0 {
+		g := &matroska.BlockGroup{
+			Block:          b,
+			DiscardPadding: p.DiscardPadding,
+		}
+		if p.Duration != 0 {
+			g.Duration = matroska.Duration(p.Duration / h.scale)
+		}
+		if last, ok := h.last[p.Track]; ok && !p.Keyframe {
+			g.ReferenceBlock = []matroska.Time{matroska.Time(last - tc)}
+		}
+		err = enc.EncodeElement(0xA0, g)
+	} else {
+		if p.Keyframe {
+			b.Flags |= matroska.BlockFlagKeyframe
+		}
+		err = enc.EncodeElement(0xA3, b)
+	}
+	if err != nil {
+		return err
+	}
+	h.last[p.Track] = tc
+	h.send(h.take(), start)
+	return nil
+}
+
+// send queues data for clients, new clients start with the data starting a Cluster with a keyframe.
+func (h *Handler) send(b []byte, start bool) {
+	size := h.Buffer
+	if size <= 0 {
+		size = 256
+	}
+	for c := range h.clients {
+		if !c.started {
+			if !start {
+				continue
+			}
+			c.started = true
+			c.ch <- h.init
+		}
+		if len(c.ch) >= size {
+			// The client does not keep up with the stream
+			delete(h.clients, c)
+			close(c.ch)
+			continue
+		}
+		c.ch <- b
+	}
+}
+
+// Close ends responses of all clients.
+func (h *Handler) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.closed {
+		h.closed = true
+		for c := range h.clients {
+			close(c.ch)
+		}
+		h.clients = nil
+	}
+	return nil
+}
+
+// ServeHTTP streams WebM to the client until the handler is closed or the client goes away.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	size := h.Buffer
+	if size <= 0 {
+		size = 256
+	}
+	// Reserve room for the stream header
+	c := &client{ch: make(chan []byte, size+1)}
+	h.mu.Lock()
+	if h.closed {
+		h.mu.Unlock()
+		http.Error(w, "stream has ended", http.StatusNotFound)
+		return
+	}
+	h.clients[c] = true
+	h.mu.Unlock()
+	defer h.remove(c)
+	if h.video {
+		w.Header().Set("Content-Type", "video/webm")
+	} else {
+		w.Header().Set("Content-Type", "audio/webm")
+	}
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	f, _ := w.(http.Flusher)
+	if f != nil {
+		f.Flush()
+	}
+	for {
+		select {
+		case b, ok := <-c.ch:
+			if !ok {
+				return
+			}
+			if _, err := w.Write(b); err != nil {
+				return
+			}
+			if f != nil && len(c.ch) == 0 {
+				f.Flush()
+			}
+		case <-req.Context().Done():
+			return
+		}
+	}
+}
+
+func (h *Handler) remove(c *client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.clients[c] {
+		delete(h.clients, c)
+		close(c.ch)
+	}
+}
+
+func (h *Handler) take() []byte {
+	b := append([]byte(nil), h.buf.Bytes()...)
+	h.buf.Reset()
+	return b
+}
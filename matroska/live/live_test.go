@@ -0,0 +1,179 @@
+package live
+
+import (
+	"bytes"
+	"github.com/pixelbender/go-matroska/matroska"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHandler(t *testing.T) {
+	seg := &matroska.Segment{Tracks: []*matroska.Track{{Entries: []*matroska.TrackEntry{
+		{Number: 1, Type: matroska.TrackTypeVideo, CodecID: matroska.CodecVP8, Enabled: true, Default: true, Video: &matroska.VideoTrack{Width: 320, Height: 240}},
+	}}}}
+	h, err := NewHandler(seg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.ClusterDuration = 400 * time.Millisecond
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	write := func(from, to int) {
+		for i := from; i < to; i++ {
+			p := &matroska.Packet{Track: 1, Time: time.Duration(i) * 40 * time.Millisecond, Keyframe: i%10 == 0, Data: []byte{byte(i)}}
+			if err := h.WritePacket(p); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	write(0, 5)
+	res, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.Header.Get("Content-Type") != "video/webm" {
+		t.Errorf("Unexpected content type: %s", res.Header.Get("Content-Type"))
+	}
+	write(5, 20)
+	h.Close()
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(b, h.init) || !bytes.HasPrefix(b[len(h.init):], []byte{0x1f, 0x43, 0xb6, 0x75, 0xff}) {
+		t.Fatalf("Unexpected stream start: %x", b[len(h.init):])
+	}
+	r, err := matroska.NewReader(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 10; i < 20; i++ {
+		p, err := r.ReadPacket()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Time != time.Duration(i)*40*time.Millisecond || p.Keyframe != (i == 10) || p.Data[0] != byte(i) {
+			t.Errorf("Unexpected packet: %+v", p)
+		}
+	}
+	if _, err = r.ReadPacket(); err != io.EOF {
+		t.Errorf("Expected EOF, got %v", err)
+	}
+	if res, err = http.Get(srv.URL); err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusNotFound {
+		t.Errorf("Unexpected status of the closed handler: %s", res.Status)
+	}
+}
+
+func TestHandlerClusters(t *testing.T) {
+	seg := &matroska.Segment{Tracks: []*matroska.Track{{Entries: []*matroska.TrackEntry{
+		{Number: 1, Type: matroska.TrackTypeVideo, CodecID: matroska.CodecVP8, Enabled: true, Default: true, Video: &matroska.VideoTrack{Width: 320, Height: 240}},
+		{Number: 2, Type: matroska.TrackTypeAudio, CodecID: matroska.CodecOpus, Enabled: true, Default: true, Audio: &matroska.AudioTrack{SamplingFreq: 48000, Channels: 2}},
+	}}}}
+	h, err := NewHandler(seg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	write := func(track matroska.TrackNumber, ms int, key bool) {
+		p := &matroska.Packet{Track: track, Time: time.Duration(ms) * time.Millisecond, Keyframe: key, Data: []byte{byte(track)}}
+		if err := h.WritePacket(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(1, 0, true)
+	res, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	// Audio keyframes and the non-key Cluster after the timecode overflow do not start clients
+	for ms := 0; ms < 2000; ms += 20 {
+		write(2, ms, true)
+	}
+	write(1, 40000, false)
+	write(1, 40500, true)
+	write(1, 41000, true)
+	write(2, 41000, true)
+	h.Close()
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := matroska.NewReader(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := bytes.Count(b, []byte{0x1f, 0x43, 0xb6, 0x75}); n != 1 {
+		t.Errorf("Unexpected number of clusters: %d", n)
+	}
+	for _, want := range []matroska.TrackNumber{1, 2} {
+		p, err := r.ReadPacket()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Track != want || p.Time != 41*time.Second || !p.Keyframe {
+			t.Errorf("Unexpected packet: %+v", p)
+		}
+	}
+}
+
+func TestHandlerAudio(t *testing.T) {
+	seg := &matroska.Segment{Tracks: []*matroska.Track{{Entries: []*matroska.TrackEntry{
+		{Number: 1, Type: matroska.TrackTypeAudio, CodecID: matroska.CodecOpus, Enabled: true, Default: true, Audio: &matroska.AudioTrack{SamplingFreq: 48000, Channels: 2}},
+	}}}}
+	h, err := NewHandler(seg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	write := func(from, to int) {
+		for i := from; i < to; i++ {
+			p := &matroska.Packet{Track: 1, Time: time.Duration(i) * 20 * time.Millisecond, Keyframe: true, Data: []byte{byte(i)}}
+			if err := h.WritePacket(p); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	write(0, 1)
+	res, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.Header.Get("Content-Type") != "audio/webm" {
+		t.Errorf("Unexpected content type: %s", res.Header.Get("Content-Type"))
+	}
+	write(1, 100)
+	h.Close()
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := bytes.Count(b, []byte{0x1f, 0x43, 0xb6, 0x75}); n != 1 {
+		t.Errorf("Unexpected number of clusters: %d", n)
+	}
+	r, err := matroska.NewReader(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 50; i < 100; i++ {
+		p, err := r.ReadPacket()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Time != time.Duration(i)*20*time.Millisecond || p.Data[0] != byte(i) {
+			t.Errorf("Unexpected packet: %+v", p)
+		}
+	}
+}
@@ -2,7 +2,6 @@ package matroska
 
 import (
 	"github.com/pixelbender/go-matroska/ebml"
-	"log"
 	"os"
 	"time"
 )
@@ -14,6 +13,7 @@ func Decode(file string) (*File, error) {
 	}
 	dec := ebml.NewReader(r, &ebml.DecodeOptions{
 		SkipDamaged: true,
+		Level:       Level,
 	})
 	v := new(File)
 	if err = dec.Decode(&v); err != nil {
@@ -50,6 +50,32 @@ type EBML struct {
 // ID is a binary EBML element identifier.
 type ID uint32
 
+// Top-Level Element IDs
+const (
+	IDEBML        ID = 0x1A45DFA3
+	IDSegment     ID = 0x18538067
+	IDSeekHead    ID = 0x114D9B74
+	IDInfo        ID = 0x1549A966
+	IDTracks      ID = 0x1654AE6B
+	IDCluster     ID = 0x1F43B675
+	IDCues        ID = 0x1C53BB6B
+	IDAttachments ID = 0x1941A469
+	IDChapters    ID = 0x1043A770
+	IDTags        ID = 0x1254C367
+)
+
+// Level returns the depth of the EBML header, the Segment and Top-Level Elements, -1 for other elements.
+// Live streams contain Segments and Clusters of unknown size, which end with the element of the same or lower depth.
+func Level(id uint32) int {
+	switch ID(id) {
+	case IDEBML, IDSegment:
+		return 0
+	case IDSeekHead, IDInfo, IDTracks, IDCluster, IDCues, IDAttachments, IDChapters, IDTags:
+		return 1
+	}
+	return -1
+}
+
 // SegmentID is a randomly generated unique 128bit identifier of Segment/SegmentFamily.
 type SegmentID []byte
 
@@ -64,8 +90,8 @@ type Duration int64
 type Segment struct {
 	SeekHead    []*SeekHead   `ebml:"114D9B74,omitempty" json:",omitempty"`
 	Info        []*Info       `ebml:"1549A966" json:",omitempty"`
-	Cluster     []*Cluster    `ebml:"1F43B675,omitempty" json:",omitempty"`
 	Tracks      []*Track      `ebml:"1654AE6B,omitempty" json:",omitempty"`
+	Cluster     []*Cluster    `ebml:"1F43B675,omitempty" json:",omitempty"`
 	Cues        []*CuePoint   `ebml:"1C53BB6B>BB,omitempty" json:",omitempty"`
 	Attachments []*Attachment `ebml:"1941A469>61A7"`
 	Chapters    []*Edition    `ebml:"1043A770>45B9"`
@@ -133,10 +159,17 @@ type Block struct {
 	TrackNumber TrackNumber
 	Timecode    int16
 	Flags       uint8
-	Frames      int
-	//Data []byte
+	Frames      [][]byte `json:"-"`
 }
 
+// Block flags
+const (
+	BlockFlagKeyframe    uint8 = 0x80
+	BlockFlagInvisible   uint8 = 0x08
+	BlockFlagDiscardable uint8 = 0x01
+)
+
+// Block lacing types
 const (
 	LacingNone uint8 = iota
 	LacingXiph
@@ -144,44 +177,15 @@ const (
 	LacingEBML
 )
 
-func (b *Block) UnmarshalEBML(r *ebml.Reader) error {
-	//log.Printf("\t Block %#v", r.Len())
-	v, err := r.ReadVInt()
-	if err != nil {
-		log.Println(err)
-		return err
-	}
-	b.TrackNumber = TrackNumber(v)
-	p, err := r.Next(3)
-	if err != nil {
-		log.Println(err)
-		return err
-	}
-	b.Timecode = int16(p[0])<<8 | int16(p[1])
-	b.Flags = p[2]
-	if (b.Flags>>1)&3 == 0 {
-		return nil
-	}
-	log.Printf("\t Block %#v", b)
-	p, err = r.Next(1)
-	if err != nil {
-		log.Println(err)
-		return err
-	}
-	b.Frames = int(p[0])
-	log.Printf("\t Block %#v", b)
-	return nil
-}
-
 // BlockGroup contains a single Block and a relative information.
 type BlockGroup struct {
 	Block             *Block           `ebml:"A1" json:",omitempty"`
 	Additions         []*BlockAddition `ebml:"75A1>A6,omitempty" json:",omitempty"`
 	Duration          Duration         `ebml:"9B,omitempty" json:",omitempty"`
 	ReferencePriority int64            `ebml:"FA"`
-	ReferenceBlock    []Time           `ebml:"FB,omitempty" json:",omitempty"`
+	ReferenceBlock    []Time           `ebml:"FB,signed,omitempty" json:",omitempty"`
 	CodecState        []byte           `ebml:"A4,omitempty" json:",omitempty"`
-	DiscardPadding    time.Duration    `ebml:"75A2,omitempty" json:",omitempty"`
+	DiscardPadding    time.Duration    `ebml:"75A2,signed,omitempty" json:",omitempty"`
 	Slices            []*TimeSlice     `ebml:"8E>E8,omitempty" json:",omitempty"`
 }
 
@@ -262,7 +266,7 @@ type VideoTrack struct {
 	Interlaced      InterlaceType   `ebml:"9A"`
 	FieldOrder      FieldOrder      `ebml:"9D,2"`
 	StereoMode      StereoMode      `ebml:"53B8,omitempty" json:"stereoMode,omitempty"`
-	AlphaMode       *AlphaMode      `ebml:"53C0,omitempty" json:"alphaMode,omitempty"`
+	AlphaMode       AlphaMode       `ebml:"53C0,omitempty" json:"alphaMode,omitempty"`
 	Width           int             `ebml:"B0"`
 	Height          int             `ebml:"BA"`
 	CropBottom      int             `ebml:"54AA,omitempty" json:",omitempty"`
@@ -317,8 +321,14 @@ const (
 	StereoModeLacedRight
 )
 
-type AlphaMode struct {
-}
+// AlphaMode indicates whether BlockAdditions of the video track contain alpha channel data.
+type AlphaMode uint8
+
+// AlphaModes
+const (
+	AlphaModeNone AlphaMode = iota
+	AlphaModePresent
+)
 
 type DisplayUnit uint8
 
@@ -527,15 +537,17 @@ type ChapterAtom struct {
 	Tracks        []TrackID         `ebml:"8F>89,omitempty" json:",omitempty"`
 	Displays      []*ChapterDisplay `ebml:"80,omitempty" json:",omitempty"`
 	Processes     []*ChapterProcess `ebml:"6944,omitempty" json:",omitempty"`
+	Atoms         []*ChapterAtom    `ebml:"B6,omitempty" json:",omitempty"`
 }
 
 type ChapterID uint64
 
 // ChapterDisplay contains all possible strings to use for the chapter display.
 type ChapterDisplay struct {
-	String   string `ebml:"85"`
-	Language string `ebml:"437C,eng"`                         // See ISO-639-2
-	Country  string `ebml:"437E,omitempty" json:",omitempty"` // See IANA ccTLDs
+	String       string `ebml:"85"`
+	Language     string `ebml:"437C,eng"`                         // See ISO-639-2
+	LanguageIETF string `ebml:"437D,omitempty" json:",omitempty"` // See BCP 47
+	Country      string `ebml:"437E,omitempty" json:",omitempty"` // See IANA ccTLDs
 }
 
 // ChapterProcess describes the atom processing commands.
@@ -558,6 +570,7 @@ type Tag struct {
 }
 
 // Target contains all IDs where the specified meta data apply.
+// Tags without IDs of some kind apply to all elements of that kind.
 type Target struct {
 	TypeValue     int            `ebml:"68CA,50,omitempty" json:",omitempty"`
 	Type          string         `ebml:"63CA,omitempty" json:",omitempty"`
@@ -569,11 +582,13 @@ type Target struct {
 
 // SimpleTag contains general information about the target.
 type SimpleTag struct {
-	Name     string `ebml:"45A3"`
-	Language string `ebml:"447A,und"`
-	Default  bool   `ebml:"4484,true"`
-	String   string `ebml:"4487,omitempty" json:",omitempty"`
-	Binary   []byte `ebml:"4485,omitempty" json:",omitempty"`
+	Name         string       `ebml:"45A3"`
+	Language     string       `ebml:"447A,und"`
+	LanguageIETF string       `ebml:"447B,omitempty" json:",omitempty"` // See BCP 47
+	Default      bool         `ebml:"4484,true"`
+	String       string       `ebml:"4487,omitempty" json:",omitempty"`
+	Binary       []byte       `ebml:"4485,omitempty" json:",omitempty"`
+	SimpleTags   []*SimpleTag `ebml:"67C8,omitempty" json:",omitempty"`
 }
 
 func NewSimpleTag(name, text string) *SimpleTag {
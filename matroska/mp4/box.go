@@ -0,0 +1,157 @@
+// Package mp4 converts between fragmented ISO BMFF (fMP4, CMAF) and Matroska.
+// See ISO/IEC 14496-12.
+package mp4
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"time"
+)
+
+var errInvalidBox = errors.New("mp4: invalid box")
+
+// box is the parsed box with its payload.
+type box struct {
+	typ  string
+	data []byte
+}
+
+// readBoxHeader reads the box header and returns the box type, the payload size and the header size.
+// The payload size is -1 if the box extends to the end of the file.
+func readBoxHeader(r io.Reader) (string, int64, int, error) {
+	var b [16]byte
+	if _, err := io.ReadFull(r, b[:8]); err != nil {
+		return "", 0, 0, err
+	}
+	typ, size := string(b[4:8]), int64(binary.BigEndian.Uint32(b[:]))
+	switch size {
+	case 0:
+		return typ, -1, 8, nil
+	case 1:
+		if _, err := io.ReadFull(r, b[8:]); err != nil {
+			return "", 0, 0, unexpected(err)
+		}
+		size = int64(binary.BigEndian.Uint64(b[8:]))
+		if size < 16 {
+			return "", 0, 0, errInvalidBox
+		}
+		return typ, size - 16, 16, nil
+	}
+	if size < 8 {
+		return "", 0, 0, errInvalidBox
+	}
+	return typ, size - 8, 8, nil
+}
+
+// parseBoxes parses boxes in b.
+func parseBoxes(b []byte) ([]*box, error) {
+	var list []*box
+	for len(b) > 0 {
+		if len(b) < 8 {
+			return nil, errInvalidBox
+		}
+		typ, size, n := string(b[4:8]), uint64(binary.BigEndian.Uint32(b)), uint64(8)
+		switch size {
+		case 0:
+			size = uint64(len(b))
+		case 1:
+			if len(b) < 16 {
+				return nil, errInvalidBox
+			}
+			size, n = binary.BigEndian.Uint64(b[8:]), 16
+		}
+		if size < n || size > uint64(len(b)) {
+			return nil, errInvalidBox
+		}
+		list = append(list, &box{typ, b[n:size]})
+		b = b[size:]
+	}
+	return list, nil
+}
+
+// find returns the first box of the given type.
+func find(list []*box, typ string) *box {
+	for _, it := range list {
+		if it.typ == typ {
+			return it
+		}
+	}
+	return nil
+}
+
+// findPath returns the first box at the path of box types below the list, or nil.
+func findPath(list []*box, path ...string) *box {
+	var b *box
+	for i, typ := range path {
+		if b = find(list, typ); b == nil {
+			return nil
+		}
+		if i < len(path)-1 {
+			var err error
+			if list, err = parseBoxes(b.data); err != nil {
+				return nil
+			}
+		}
+	}
+	return b
+}
+
+// fullBox returns the version, flags and payload of the full box.
+func fullBox(b *box) (uint8, uint32, []byte, error) {
+	if b == nil || len(b.data) < 4 {
+		return 0, 0, nil, errInvalidBox
+	}
+	return b.data[0], binary.BigEndian.Uint32(b.data) & 0xffffff, b.data[4:], nil
+}
+
+// appendBox appends the box with the payload parts to dst.
+func appendBox(dst []byte, typ string, data ...[]byte) []byte {
+	n := 8
+	for _, it := range data {
+		n += len(it)
+	}
+	dst = append(dst, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+	dst = append(dst, typ...)
+	for _, it := range data {
+		dst = append(dst, it...)
+	}
+	return dst
+}
+
+// appendFullBox appends the full box with the version, flags and payload parts to dst.
+func appendFullBox(dst []byte, typ string, v uint8, flags uint32, data ...[]byte) []byte {
+	h := []byte{v, byte(flags >> 16), byte(flags >> 8), byte(flags)}
+	return appendBox(dst, typ, append([][]byte{h}, data...)...)
+}
+
+func u16(v int) []byte {
+	return []byte{byte(v >> 8), byte(v)}
+}
+
+func u32(v uint32) []byte {
+	return []byte{byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)}
+}
+
+func u64(v uint64) []byte {
+	return append(u32(uint32(v>>32)), u32(uint32(v))...)
+}
+
+// scaleTime converts v in units of the timescale to the duration.
+func scaleTime(v int64, timescale uint32) time.Duration {
+	ts := int64(timescale)
+	return time.Duration(v/ts)*time.Second + time.Duration(v%ts)*time.Second/time.Duration(ts)
+}
+
+// mediaTime converts the duration to units of the timescale.
+func mediaTime(d time.Duration, timescale uint32) int64 {
+	ts := int64(timescale)
+	return int64(d/time.Second)*ts + int64(d%time.Second)*ts/int64(time.Second)
+}
+
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,245 @@
+package mp4
+
+import (
+	"bytes"
+	"github.com/pixelbender/go-matroska/matroska"
+	"github.com/pixelbender/go-matroska/matroska/codec"
+	"io"
+	"testing"
+	"time"
+)
+
+func full(typ string, v byte, flags uint32, data ...[]byte) []byte {
+	return appendFullBox(nil, typ, v, flags, data...)
+}
+
+func testTrack(id, timescale uint32, entry []byte, edits ...[]byte) []byte {
+	stbl := appendBox(nil, "stbl", full("stsd", 0, 0, u32(1), entry))
+	mdia := appendBox(nil, "mdia",
+		full("mdhd", 0, 0, make([]byte, 8), u32(timescale), u32(0), u16(0x55c4), u16(0)),
+		appendBox(nil, "minf", stbl))
+	var edts []byte
+	if len(edits) > 0 {
+		edts = appendBox(nil, "edts", full("elst", 0, 0, u32(1), edits[0]))
+	}
+	return appendBox(nil, "trak", full("tkhd", 0, 3, make([]byte, 8), u32(id), make([]byte, 68)), edts, mdia)
+}
+
+func testFile() []byte {
+	visual := make([]byte, 78)
+	copy(visual[24:], u16(320))
+	copy(visual[26:], u16(240))
+	avc1 := appendBox(nil, "avc1", visual, appendBox(nil, "avcC", []byte{1, 0x42, 0, 0x1e, 0xff, 0xe0, 0}))
+	audio := make([]byte, 28)
+	copy(audio[16:], u16(2))
+	copy(audio[18:], u16(16))
+	copy(audio[24:], u16(48000))
+	opus := appendBox(nil, "Opus", audio, appendBox(nil, "dOps", []byte{0, 2}, u16(312), u32(48000), u16(0), []byte{0}))
+	moov := appendBox(nil, "moov",
+		full("mvhd", 0, 0, make([]byte, 8), u32(1000), make([]byte, 84)),
+		testTrack(1, 90000, avc1, append(append(u32(0), u32(3000)...), u32(0x10000)...)),
+		testTrack(2, 48000, opus),
+		appendBox(nil, "mvex",
+			full("trex", 0, 0, u32(1), u32(1), u32(3000), u32(0), u32(0x10000)),
+			full("trex", 0, 0, u32(2), u32(1), u32(960), u32(0), u32(0))))
+	moof := func(video, audio uint32) []byte {
+		return appendBox(nil, "moof",
+			full("mfhd", 0, 0, u32(1)),
+			appendBox(nil, "traf",
+				full("tfhd", 0, 0x20000, u32(1)),
+				full("tfdt", 1, 0, make([]byte, 8)),
+				full("trun", 0, 0xa05, u32(3), u32(video), u32(0),
+					u32(2), u32(3000), u32(2), u32(9000), u32(2), u32(0))),
+			appendBox(nil, "traf",
+				full("tfhd", 0, 0x20000, u32(2)),
+				full("tfdt", 0, 0, u32(0)),
+				full("trun", 0, 0x201, u32(2), u32(audio), u32(1), u32(1))))
+	}
+	n := uint32(len(moof(0, 0)) + 8)
+	b := appendBox(nil, "ftyp", []byte("iso6"), u32(0), []byte("iso6cmfc"))
+	b = append(b, moov...)
+	b = append(b, moof(n, n+6)...)
+	return appendBox(b, "mdat", []byte{0, 1, 0, 2, 0, 3, 0xf8, 0xf9})
+}
+
+func TestReader(t *testing.T) {
+	r, err := NewReader(bytes.NewReader(testFile()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Tracks) != 2 {
+		t.Fatalf("Unexpected tracks: %d", len(r.Tracks))
+	}
+	v, a := r.Tracks[0], r.Tracks[1]
+	if v.Number != 1 || v.CodecID != matroska.CodecAVC || v.Video.Width != 320 || len(v.CodecPrivate) != 7 || v.Language != "und" || v.DefaultDuration != time.Second/30 {
+		t.Errorf("Unexpected video track: %+v", v)
+	}
+	if c, err := codec.ParseOpusConfig(a.CodecPrivate); err != nil || c.PreSkip != 312 || c.Channels != 2 || a.CodecID != matroska.CodecOpus || a.CodecDelay != 6500*time.Microsecond {
+		t.Errorf("Unexpected audio track: %+v", a)
+	}
+	want := []struct {
+		track    matroska.TrackNumber
+		time     time.Duration
+		keyframe bool
+		data     byte
+	}{
+		{1, 0, true, 1},
+		{2, 0, true, 0xf8},
+		{2, 20 * time.Millisecond, true, 0xf9},
+		{1, 100 * time.Millisecond, false, 2},
+		{1, time.Second / 30, false, 3},
+	}
+	for i, it := range want {
+		p, err := r.ReadPacket()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Track != it.track || p.Time != it.time || p.Keyframe != it.keyframe || p.Data[len(p.Data)-1] != it.data {
+			t.Errorf("Unexpected packet %d: %+v", i, p)
+		}
+	}
+	if _, err = r.ReadPacket(); err != io.EOF {
+		t.Errorf("Unexpected end: %v", err)
+	}
+}
+
+func TestReaderLargeBox(t *testing.T) {
+	b := appendBox(nil, "ftyp", []byte("iso6"), u32(0), []byte("iso6cmfc"))
+	moov := append(append(u32(1), "moov"...), u64(1<<63-1)...)
+	if _, err := NewReader(bytes.NewReader(append(b, moov...))); err != errTooLarge {
+		t.Errorf("Unexpected error for the large moov box: %v", err)
+	}
+	// Samples are read from the truncated mdat box without reading it whole
+	b = testFile()
+	copy(b[len(b)-16:], u32(1<<32-1))
+	if _, err := NewReader(bytes.NewReader(b)); err != io.ErrUnexpectedEOF {
+		t.Errorf("Unexpected error for the truncated mdat box: %v", err)
+	}
+}
+
+func TestRemux(t *testing.T) {
+	b := &bytes.Buffer{}
+	if err := Remux(b, bytes.NewReader(testFile()), nil); err != nil {
+		t.Fatal(err)
+	}
+	r, err := matroska.NewReader(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for {
+		if _, err = r.ReadPacket(); err != nil {
+			break
+		}
+		n++
+	}
+	if err != io.EOF || n != 5 || len(r.Segment.Tracks[0].Entries) != 2 {
+		t.Errorf("Unexpected result: %v, packets: %d", err, n)
+	}
+}
+
+func TestFragment(t *testing.T) {
+	tracks := []*matroska.TrackEntry{
+		{Number: 1, Type: matroska.TrackTypeVideo, CodecID: matroska.CodecAVC, CodecPrivate: []byte{1, 0x42, 0, 0x1e, 0xff, 0xe0, 0},
+			Enabled: true, Default: true, DefaultDuration: 40 * time.Millisecond, Video: &matroska.VideoTrack{Width: 320, Height: 240}},
+		{Number: 2, Type: matroska.TrackTypeAudio, CodecID: matroska.CodecOpus, CodecPrivate: (&codec.OpusConfig{Channels: 2, PreSkip: 312}).Bytes(),
+			Enabled: true, Default: true, Audio: &matroska.AudioTrack{SamplingFreq: 48000, Channels: 2}},
+		{Number: 3, Type: matroska.TrackTypeSubtitle, CodecID: "S_TEXT/UTF8", Enabled: true, Default: true},
+	}
+	// Video frames in decoding order with a B-frame, a Cluster per second
+	var packets []*matroska.Packet
+	for i := 0; i < 50; i++ {
+		pts := i
+		switch {
+		case i%25 == 0:
+		case i%2 == 1:
+			pts = i + 1
+		default:
+			pts = i - 1
+		}
+		packets = append(packets, &matroska.Packet{Track: 1, Time: time.Duration(pts) * 40 * time.Millisecond, Keyframe: i%25 == 0, Data: []byte{byte(i)}})
+		packets = append(packets, &matroska.Packet{Track: 2, Time: time.Duration(i) * 40 * time.Millisecond, Keyframe: true, Data: []byte{0xf9, byte(i)}})
+	}
+	packets = append(packets, &matroska.Packet{Track: 3, Time: time.Second, Keyframe: true, Data: []byte("text")})
+	b := &bytes.Buffer{}
+	w, err := matroska.NewWriter(b, &matroska.Segment{Tracks: []*matroska.Track{{Entries: tracks}}}, &matroska.WriterOptions{ClusterDuration: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, it := range packets {
+		if err = w.WritePacket(it); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	mr, err := matroska.NewReader(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := &bytes.Buffer{}
+	if err = Fragment(out, mr); err != nil {
+		t.Fatal(err)
+	}
+	if n := bytes.Count(out.Bytes(), []byte("moof")); n != 2 {
+		t.Errorf("Unexpected number of fragments: %d", n)
+	}
+	r, err := NewReader(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Tracks) != 2 || !bytes.Equal(r.Tracks[0].CodecPrivate, tracks[0].CodecPrivate) || r.Tracks[0].Language != "eng" {
+		t.Fatalf("Unexpected tracks: %+v", r.Tracks)
+	}
+	if c, err := codec.ParseOpusConfig(r.Tracks[1].CodecPrivate); err != nil || c.PreSkip != 312 {
+		t.Errorf("Unexpected opus config: %+v", c)
+	}
+	n := 0
+	for ; ; n++ {
+		p, err := r.ReadPacket()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		var want *matroska.Packet
+		for _, it := range packets {
+			if it.Track == p.Track && bytes.Equal(it.Data, p.Data) {
+				want = it
+			}
+		}
+		if want == nil || want.Time != p.Time || want.Keyframe != p.Keyframe {
+			t.Errorf("Unexpected packet: %+v, want: %+v", p, want)
+		}
+	}
+	if n != 100 {
+		t.Errorf("Unexpected number of packets: %d", n)
+	}
+}
+
+func TestParseESDS(t *testing.T) {
+	config := append([]byte{4, 17, 0x40}, make([]byte, 12)...)
+	config = append(config, 5, 2, 0x12, 0x10)
+	es := append([]byte{3, byte(8 + len(config)), 0, 1, 0xe0, 0, 2, 0, 0, 3}, config...)
+	oti, dsi, err := parseESDS(&box{"esds", append(u32(0), es...)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if oti != 0x40 || !bytes.Equal(dsi, []byte{0x12, 0x10}) {
+		t.Errorf("Unexpected decoder config: %x %x", oti, dsi)
+	}
+	for _, es := range [][]byte{
+		{3, 3, 0, 1, 0x80},
+		{3, 4, 0, 1, 0x80, 0},
+		{3, 4, 0, 1, 0x40, 10},
+		{3, 3, 0, 1, 0x40},
+		{3, 3, 0, 1, 0x20},
+		{3, 5, 0, 1, 0xe0, 0, 2},
+	} {
+		if _, _, err = parseESDS(&box{"esds", append(u32(0), es...)}); err == nil {
+			t.Errorf("Expected error on truncated descriptor %x", es)
+		}
+	}
+}
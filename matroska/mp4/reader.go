@@ -0,0 +1,702 @@
+package mp4
+
+import (
+	"encoding/binary"
+	"errors"
+	"github.com/pixelbender/go-matroska/matroska"
+	"github.com/pixelbender/go-matroska/matroska/codec"
+	"io"
+	"io/ioutil"
+	"sort"
+	"time"
+)
+
+// Reader reads samples of the fragmented MP4 file as Matroska packets.
+// Packets of each movie fragment are ordered by decode time.
+type Reader struct {
+	// Tracks contains entries of supported tracks numbered in order of the moov box.
+	Tracks []*matroska.TrackEntry
+
+	r      *countReader
+	tracks map[uint32]*track
+	queue  []*matroska.Packet
+}
+
+type track struct {
+	entry     *matroska.TrackEntry
+	timescale uint32
+	shift     int64         // Media time of the first edit
+	delay     time.Duration // Duration of leading empty edits
+	duration  uint32        // Default sample duration, size and flags of the trex box
+	size      uint32
+	flags     uint32
+	dts       int64 // Decode time of the next sample
+}
+
+// time returns the presentation time of the media time.
+func (t *track) time(v int64) time.Duration {
+	return scaleTime(v-t.shift, t.timescale) + t.delay
+}
+
+// NewReader reads boxes from r up to the first movie fragment and returns a new reader.
+// Unsupported tracks are skipped. DefaultDuration of tracks is set from their first samples.
+func NewReader(r io.Reader) (*Reader, error) {
+	rd := &Reader{r: &countReader{r: r}, tracks: make(map[uint32]*track)}
+	for {
+		typ, size, _, err := readBoxHeader(rd.r)
+		if err != nil {
+			if err == io.EOF {
+				return nil, errors.New("mp4: no moov box")
+			}
+			return nil, err
+		}
+		if typ != "moov" {
+			if err = rd.skip(size); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		b, err := rd.read(size)
+		if err != nil {
+			return nil, err
+		}
+		if err = rd.parseMovie(b); err != nil {
+			return nil, err
+		}
+		break
+	}
+	if err := rd.readFragment(); err != nil && err != io.EOF {
+		return nil, err
+	}
+	for _, it := range rd.queue {
+		if e := rd.Tracks[it.Track-1]; e.DefaultDuration == 0 {
+			e.DefaultDuration = it.Duration
+		}
+	}
+	return rd, nil
+}
+
+// ReadPacket reads the next packet. Returns io.EOF at the end of the file.
+func (r *Reader) ReadPacket() (*matroska.Packet, error) {
+	for len(r.queue) == 0 {
+		if err := r.readFragment(); err != nil {
+			return nil, err
+		}
+	}
+	p := r.queue[0]
+	r.queue = r.queue[1:]
+	return p, nil
+}
+
+// Remux reads the fragmented MP4 file from r and writes it to w as Matroska.
+func Remux(w io.Writer, r io.Reader, opt *matroska.WriterOptions) error {
+	rd, err := NewReader(r)
+	if err != nil {
+		return err
+	}
+	seg := &matroska.Segment{Tracks: []*matroska.Track{{Entries: rd.Tracks}}}
+	mw, err := matroska.NewWriter(w, seg, opt)
+	if err != nil {
+		return err
+	}
+	for {
+		p, err := rd.ReadPacket()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if err = mw.WritePacket(p); err != nil {
+			return err
+		}
+	}
+	return mw.Close()
+}
+
+// maxBoxSize limits the size of boxes and samples read into memory from damaged or malicious input.
+const maxBoxSize = 256 << 20
+
+var errTooLarge = errors.New("mp4: box is too large")
+
+func (r *Reader) read(size int64) ([]byte, error) {
+	if size < 0 {
+		b, err := ioutil.ReadAll(io.LimitReader(r.r, maxBoxSize+1))
+		if err == nil && len(b) > maxBoxSize {
+			err = errTooLarge
+		}
+		return b, err
+	}
+	if size > maxBoxSize {
+		return nil, errTooLarge
+	}
+	b := make([]byte, size)
+	_, err := io.ReadFull(r.r, b)
+	return b, unexpected(err)
+}
+
+func (r *Reader) skip(size int64) error {
+	if size < 0 {
+		_, err := io.Copy(ioutil.Discard, r.r)
+		return err
+	}
+	_, err := io.CopyN(ioutil.Discard, r.r, size)
+	return unexpected(err)
+}
+
+func (r *Reader) parseMovie(b []byte) error {
+	list, err := parseBoxes(b)
+	if err != nil {
+		return err
+	}
+	v, _, p, err := fullBox(find(list, "mvhd"))
+	if v == 1 && len(p) >= 20 {
+		p = p[8:]
+	}
+	if err != nil || len(p) < 12 {
+		return errors.New("mp4: invalid mvhd box")
+	}
+	scale := binary.BigEndian.Uint32(p[8:])
+	mvex := find(list, "mvex")
+	if mvex == nil {
+		return errors.New("mp4: file is not fragmented")
+	}
+	trex := make(map[uint32][]byte)
+	if ext, err := parseBoxes(mvex.data); err == nil {
+		for _, it := range ext {
+			if _, _, p, err := fullBox(it); err == nil && it.typ == "trex" && len(p) >= 20 {
+				trex[binary.BigEndian.Uint32(p)] = p
+			}
+		}
+	}
+	for _, it := range list {
+		if it.typ != "trak" {
+			continue
+		}
+		id, t, err := parseTrack(it, scale)
+		if err != nil {
+			return err
+		}
+		if t == nil {
+			continue
+		}
+		if p := trex[id]; p != nil {
+			t.duration, t.size, t.flags = binary.BigEndian.Uint32(p[8:]), binary.BigEndian.Uint32(p[12:]), binary.BigEndian.Uint32(p[16:])
+		}
+		r.Tracks = append(r.Tracks, t.entry)
+		t.entry.Number = matroska.TrackNumber(len(r.Tracks))
+		r.tracks[id] = t
+	}
+	return nil
+}
+
+// parseTrack returns the track ID and the track of the trak box, or nil if the track is not supported.
+func parseTrack(trak *box, movieScale uint32) (uint32, *track, error) {
+	list, err := parseBoxes(trak.data)
+	if err != nil {
+		return 0, nil, err
+	}
+	v, _, p, err := fullBox(find(list, "tkhd"))
+	if v == 1 && len(p) >= 8 {
+		p = p[8:]
+	}
+	if err != nil || len(p) < 12 {
+		return 0, nil, errors.New("mp4: invalid tkhd box")
+	}
+	id := binary.BigEndian.Uint32(p[8:])
+	b := find(list, "mdia")
+	if b == nil {
+		return 0, nil, errors.New("mp4: no mdia box")
+	}
+	mdia, err := parseBoxes(b.data)
+	if err != nil {
+		return 0, nil, err
+	}
+	v, _, p, err = fullBox(find(mdia, "mdhd"))
+	if v == 1 && len(p) >= 8 {
+		p = p[8:]
+	}
+	if err != nil || len(p) < 20 {
+		return 0, nil, errors.New("mp4: invalid mdhd box")
+	}
+	t := &track{timescale: binary.BigEndian.Uint32(p[8:])}
+	if t.timescale == 0 {
+		return 0, nil, errors.New("mp4: invalid timescale")
+	}
+	e := &matroska.TrackEntry{
+		Enabled:        true,
+		Default:        true,
+		CodecDecodeAll: true,
+	}
+	if v := p[len(p)-4:]; v[0]|v[1] != 0 {
+		lang := binary.BigEndian.Uint16(v)
+		e.Language = string([]byte{byte(lang>>10&0x1f) + 0x60, byte(lang>>5&0x1f) + 0x60, byte(lang&0x1f) + 0x60})
+	}
+	t.entry = e
+	if elst := findPath(list, "edts", "elst"); elst != nil && movieScale > 0 {
+		t.parseEdits(elst, movieScale)
+	}
+	_, _, p, err = fullBox(findPath(mdia, "minf", "stbl", "stsd"))
+	if err != nil || len(p) < 4 {
+		return 0, nil, errors.New("mp4: invalid stsd box")
+	}
+	entries, err := parseBoxes(p[4:])
+	if err != nil || len(entries) == 0 {
+		return 0, nil, errors.New("mp4: invalid sample entry")
+	}
+	ok, err := parseSampleEntry(e, entries[0])
+	if !ok || err != nil {
+		return 0, nil, err
+	}
+	return id, t, nil
+}
+
+func (t *track) parseEdits(elst *box, movieScale uint32) {
+	v, _, p, err := fullBox(elst)
+	if err != nil || len(p) < 4 {
+		return
+	}
+	n, size := int(binary.BigEndian.Uint32(p)), 12
+	if v == 1 {
+		size = 20
+	}
+	p = p[4:]
+	for i := 0; i < n && len(p) >= size; i++ {
+		var dur, pos int64
+		if v == 1 {
+			dur, pos = int64(binary.BigEndian.Uint64(p)), int64(binary.BigEndian.Uint64(p[8:]))
+		} else {
+			dur, pos = int64(binary.BigEndian.Uint32(p)), int64(int32(binary.BigEndian.Uint32(p[4:])))
+		}
+		p = p[size:]
+		if pos < 0 {
+			t.delay += scaleTime(dur, movieScale)
+			continue
+		}
+		t.shift = pos
+		return
+	}
+}
+
+// parseSampleEntry fills the track entry from the sample entry.
+// Returns false if the codec is not supported.
+func parseSampleEntry(e *matroska.TrackEntry, b *box) (bool, error) {
+	const (
+		visualSize = 78
+		audioSize  = 28
+	)
+	switch b.typ {
+	case "avc1", "avc3", "hev1", "hvc1", "av01", "vp08", "vp09":
+		if len(b.data) < visualSize {
+			return false, errInvalidBox
+		}
+		e.Type = matroska.TrackTypeVideo
+		e.Video = &matroska.VideoTrack{
+			Width:  int(binary.BigEndian.Uint16(b.data[24:])),
+			Height: int(binary.BigEndian.Uint16(b.data[26:])),
+		}
+		list, err := parseBoxes(b.data[visualSize:])
+		if err != nil {
+			return false, err
+		}
+		var cfg *box
+		switch b.typ {
+		case "avc1", "avc3":
+			e.CodecID, cfg = matroska.CodecAVC, find(list, "avcC")
+		case "hev1", "hvc1":
+			e.CodecID, cfg = matroska.CodecHEVC, find(list, "hvcC")
+		case "av01":
+			e.CodecID, cfg = matroska.CodecAV1, find(list, "av1C")
+		case "vp08":
+			e.CodecID = matroska.CodecVP8
+		case "vp09":
+			e.CodecID = matroska.CodecVP9
+		}
+		if cfg != nil {
+			e.CodecPrivate = cfg.data
+		} else if e.CodecID != matroska.CodecVP8 && e.CodecID != matroska.CodecVP9 {
+			return false, errors.New("mp4: no decoder configuration of " + b.typ)
+		}
+		return true, nil
+	case "mp4a", "Opus":
+		if len(b.data) < audioSize {
+			return false, errInvalidBox
+		}
+		e.Type, e.Lacing = matroska.TrackTypeAudio, true
+		e.Audio = &matroska.AudioTrack{
+			Channels:     int(binary.BigEndian.Uint16(b.data[16:])),
+			BitDepth:     int(binary.BigEndian.Uint16(b.data[18:])),
+			SamplingFreq: float64(binary.BigEndian.Uint16(b.data[24:])),
+		}
+		// QuickTime sound sample description versions
+		n := audioSize
+		switch binary.BigEndian.Uint16(b.data[8:]) {
+		case 1:
+			n += 16
+		case 2:
+			n += 36
+		}
+		if len(b.data) < n {
+			return false, errInvalidBox
+		}
+		list, err := parseBoxes(b.data[n:])
+		if err != nil {
+			return false, err
+		}
+		if b.typ == "Opus" {
+			c, err := parseOpusBox(find(list, "dOps"))
+			if err != nil {
+				return false, err
+			}
+			e.CodecID, e.CodecPrivate = matroska.CodecOpus, c.Bytes()
+			e.CodecDelay = time.Duration(c.PreSkip) * time.Second / 48000
+			e.SeekPreRoll = 80 * time.Millisecond
+			e.Audio.SamplingFreq, e.Audio.BitDepth = 48000, 0
+			return true, nil
+		}
+		oti, dsi, err := parseESDS(find(list, "esds"))
+		if err != nil {
+			return false, err
+		}
+		switch oti {
+		case 0x40, 0x66, 0x67, 0x68:
+			e.CodecID, e.CodecPrivate, e.Audio.BitDepth = matroska.CodecAAC, dsi, 0
+			if c, err := codec.ParseAACConfig(dsi); err == nil {
+				e.Audio.SamplingFreq = float64(c.SampleRate)
+				if c.ExtensionSampleRate > 0 {
+					e.Audio.OutputSamplingFreq = float64(c.ExtensionSampleRate)
+				}
+			}
+		case 0x69, 0x6b:
+			e.CodecID, e.Audio.BitDepth = matroska.CodecMP3, 0
+		default:
+			return false, nil
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// parseOpusBox returns the Opus configuration of the dOps box.
+func parseOpusBox(b *box) (*codec.OpusConfig, error) {
+	if b == nil || len(b.data) < 11 {
+		return nil, errors.New("mp4: invalid dOps box")
+	}
+	p := b.data
+	c := &codec.OpusConfig{
+		Channels:      int(p[1]),
+		PreSkip:       int(binary.BigEndian.Uint16(p[2:])),
+		SampleRate:    int(binary.BigEndian.Uint32(p[4:])),
+		OutputGain:    int16(binary.BigEndian.Uint16(p[8:])),
+		MappingFamily: p[10],
+		StreamCount:   1,
+	}
+	if c.Channels > 1 {
+		c.CoupledCount = 1
+	}
+	if c.MappingFamily != 0 {
+		if len(p) < 13+c.Channels {
+			return nil, errors.New("mp4: invalid dOps box")
+		}
+		c.StreamCount, c.CoupledCount, c.Mapping = int(p[11]), int(p[12]), p[13:13+c.Channels]
+	}
+	return c, nil
+}
+
+// parseESDS returns the object type indication and the decoder specific info of the esds box.
+// See ISO/IEC 14496-1.
+func parseESDS(b *box) (byte, []byte, error) {
+	errESDS := errors.New("mp4: invalid esds box")
+	_, _, p, err := fullBox(b)
+	if err != nil {
+		return 0, nil, errESDS
+	}
+	tag, p, _ := readDescriptor(p)
+	if tag != 3 || len(p) < 3 {
+		return 0, nil, errESDS
+	}
+	flags, n := p[2], 3
+	if flags&0x80 != 0 {
+		n += 2
+	}
+	if flags&0x40 != 0 {
+		if n >= len(p) {
+			return 0, nil, errESDS
+		}
+		n += 1 + int(p[n])
+	}
+	if flags&0x20 != 0 {
+		n += 2
+	}
+	if n > len(p) {
+		return 0, nil, errESDS
+	}
+	p = p[n:]
+	if tag, p, _ = readDescriptor(p); tag != 4 || len(p) < 13 {
+		return 0, nil, errESDS
+	}
+	oti := p[0]
+	if tag, p, _ = readDescriptor(p[13:]); tag != 5 {
+		return oti, nil, nil
+	}
+	return oti, p, nil
+}
+
+// readDescriptor returns the tag, the payload and the rest of the descriptor list.
+// Returns zero tag if the descriptor is truncated.
+func readDescriptor(b []byte) (byte, []byte, []byte) {
+	if len(b) < 2 {
+		return 0, nil, nil
+	}
+	tag, n, i := b[0], 0, 1
+	for ; i < len(b) && i <= 4; i++ {
+		n = n<<7 | int(b[i]&0x7f)
+		if b[i]&0x80 == 0 {
+			break
+		}
+	}
+	if i++; i+n > len(b) {
+		return 0, nil, nil
+	}
+	return tag, b[i : i+n], b[i+n:]
+}
+
+// readFragment reads the next movie fragment and queues its samples.
+func (r *Reader) readFragment() error {
+	var moof []*box
+	var moofOff int64
+	for {
+		off := r.r.n
+		typ, size, hdr, err := readBoxHeader(r.r)
+		if err != nil {
+			return err
+		}
+		switch {
+		case typ == "moof":
+			b, err := r.read(size)
+			if err != nil {
+				return err
+			}
+			if moof, err = parseBoxes(b); err != nil {
+				return err
+			}
+			moofOff = off
+		case typ == "mdat" && moof != nil:
+			list, err := r.parseFragment(moof, moofOff, off+int64(hdr), size)
+			if err != nil {
+				return err
+			}
+			if err = r.readSamples(list, size); err != nil {
+				return err
+			}
+			sort.SliceStable(list, func(i, j int) bool {
+				return list[i].dts < list[j].dts
+			})
+			for _, it := range list {
+				r.queue = append(r.queue, it.p)
+			}
+			return nil
+		default:
+			if err = r.skip(size); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+type sample struct {
+	p    *matroska.Packet
+	dts  time.Duration
+	off  int64 // Offset of the data in the mdat box
+	size int64
+}
+
+// readSamples reads data of samples from the mdat box of the given size, -1 if it extends to the end of the file.
+// Data between samples is skipped.
+func (r *Reader) readSamples(list []*sample, size int64) error {
+	order := append([]*sample(nil), list...)
+	sort.SliceStable(order, func(i, j int) bool {
+		return order[i].off < order[j].off
+	})
+	var pos int64
+	for _, it := range order {
+		if it.off < pos {
+			return errors.New("mp4: samples overlap")
+		}
+		if err := r.skip(it.off - pos); err != nil {
+			return err
+		}
+		it.p.Data = make([]byte, it.size)
+		if _, err := io.ReadFull(r.r, it.p.Data); err != nil {
+			return unexpected(err)
+		}
+		pos = it.off + it.size
+	}
+	if size < 0 {
+		return r.skip(-1)
+	}
+	return r.skip(size - pos)
+}
+
+// trackFragment is the state of the track fragment being parsed.
+type trackFragment struct {
+	*track
+	base     int64 // Base data offset
+	off      int64 // Data offset of the next sample
+	duration uint32
+	size     uint32
+	flags    uint32
+}
+
+// parseFragment returns samples of the movie fragment in the mdat box at the given offset and of the given size.
+func (r *Reader) parseFragment(moof []*box, moofOff, mdatOff, mdatSize int64) ([]*sample, error) {
+	errHeader := errors.New("mp4: invalid tfhd box")
+	var list []*sample
+	for _, traf := range moof {
+		if traf.typ != "traf" {
+			continue
+		}
+		boxes, err := parseBoxes(traf.data)
+		if err != nil {
+			return nil, err
+		}
+		_, flags, p, err := fullBox(find(boxes, "tfhd"))
+		if err != nil || len(p) < 4 {
+			return nil, errHeader
+		}
+		t := r.tracks[binary.BigEndian.Uint32(p)]
+		if t == nil {
+			continue
+		}
+		p = p[4:]
+		f := &trackFragment{track: t, base: moofOff, duration: t.duration, size: t.size, flags: t.flags}
+		if flags&0x01 != 0 {
+			if len(p) < 8 {
+				return nil, errHeader
+			}
+			f.base, p = int64(binary.BigEndian.Uint64(p)), p[8:]
+		}
+		// Sample description index is ignored
+		if flags&0x02 != 0 {
+			if len(p) < 4 {
+				return nil, errHeader
+			}
+			p = p[4:]
+		}
+		for _, it := range []struct {
+			flag uint32
+			v    *uint32
+		}{{0x08, &f.duration}, {0x10, &f.size}, {0x20, &f.flags}} {
+			if flags&it.flag == 0 {
+				continue
+			}
+			if len(p) < 4 {
+				return nil, errHeader
+			}
+			*it.v, p = binary.BigEndian.Uint32(p), p[4:]
+		}
+		if v, _, p, err := fullBox(find(boxes, "tfdt")); err == nil {
+			if v == 1 && len(p) >= 8 {
+				t.dts = int64(binary.BigEndian.Uint64(p))
+			} else if len(p) >= 4 {
+				t.dts = int64(binary.BigEndian.Uint32(p))
+			}
+		}
+		f.off = f.base
+		for _, it := range boxes {
+			if it.typ != "trun" {
+				continue
+			}
+			if list, err = f.parseRun(list, it, mdatOff, mdatSize); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return list, nil
+}
+
+// parseRun appends samples of the track run to the list.
+func (f *trackFragment) parseRun(list []*sample, trun *box, mdatOff, mdatSize int64) ([]*sample, error) {
+	errRun := errors.New("mp4: invalid trun box")
+	v, flags, p, err := fullBox(trun)
+	if err != nil || len(p) < 4 {
+		return nil, errRun
+	}
+	count := int(binary.BigEndian.Uint32(p))
+	p = p[4:]
+	if flags&0x01 != 0 {
+		if len(p) < 4 {
+			return nil, errRun
+		}
+		f.off, p = f.base+int64(int32(binary.BigEndian.Uint32(p))), p[4:]
+	}
+	first, hasFirst := uint32(0), flags&0x04 != 0
+	if hasFirst {
+		if len(p) < 4 {
+			return nil, errRun
+		}
+		first, p = binary.BigEndian.Uint32(p), p[4:]
+	}
+	for i := 0; i < count; i++ {
+		dur, size, sflags, cto := f.duration, f.size, f.flags, int64(0)
+		for _, it := range []uint32{0x100, 0x200, 0x400, 0x800} {
+			if flags&it == 0 {
+				continue
+			}
+			if len(p) < 4 {
+				return nil, errRun
+			}
+			x := binary.BigEndian.Uint32(p)
+			p = p[4:]
+			switch it {
+			case 0x100:
+				dur = x
+			case 0x200:
+				size = x
+			case 0x400:
+				sflags = x
+			case 0x800:
+				cto = int64(x)
+				if v == 1 {
+					cto = int64(int32(x))
+				}
+			}
+		}
+		if i == 0 && hasFirst {
+			sflags = first
+		}
+		start := f.off - mdatOff
+		if start < 0 || mdatSize >= 0 && start+int64(size) > mdatSize {
+			return nil, errors.New("mp4: sample is out of mdat box")
+		}
+		if size > maxBoxSize {
+			return nil, errors.New("mp4: sample is too large")
+		}
+		list = append(list, &sample{
+			p: &matroska.Packet{
+				Track:    f.entry.Number,
+				Time:     f.time(f.dts + cto),
+				Duration: scaleTime(int64(dur), f.timescale),
+				Keyframe: sflags&0x10000 == 0, // sample_is_non_sync_sample
+			},
+			dts:  scaleTime(f.dts, f.timescale) + f.delay,
+			off:  start,
+			size: int64(size),
+		})
+		f.dts += int64(dur)
+		f.off += int64(size)
+	}
+	return list, nil
+}
+
+// countReader counts bytes read.
+type countReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *countReader) Read(b []byte) (int, error) {
+	n, err := r.r.Read(b)
+	r.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,372 @@
+package mp4
+
+import (
+	"errors"
+	"github.com/pixelbender/go-matroska/matroska"
+	"github.com/pixelbender/go-matroska/matroska/codec"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Sample flags of sync and non-sync samples
+const (
+	flagsSync    = 0x02000000
+	flagsNonSync = 0x01010000
+)
+
+// Writer writes Matroska packets into the fragmented MP4 file.
+type Writer struct {
+	w      io.Writer
+	tracks []*writerTrack
+	seq    uint32
+}
+
+type writerTrack struct {
+	entry     *matroska.TrackEntry
+	id        uint32
+	timescale uint32
+	next      int64 // Decode time of the next sample, -1 if not known
+	packets   []*matroska.Packet
+}
+
+// NewWriter writes the initialization segment of the tracks to w and returns a new writer.
+func NewWriter(w io.Writer, tracks []*matroska.TrackEntry) (*Writer, error) {
+	wr := &Writer{w: w}
+	b := appendBox(nil, "ftyp", []byte("iso6"), u32(0), []byte("iso6cmfcmp41"))
+	moov := appendFullBox(nil, "mvhd", 0, 0, make([]byte, 8), u32(1000), u32(0),
+		u32(0x10000), u16(0x100), make([]byte, 10), matrix, make([]byte, 24), u32(uint32(len(tracks)+1)))
+	var mvex []byte
+	for i, e := range tracks {
+		t := &writerTrack{entry: e, id: uint32(i + 1), timescale: 90000, next: -1}
+		if e.Type == matroska.TrackTypeAudio && e.Audio != nil {
+			t.timescale = uint32(e.Audio.SamplingFreq)
+		}
+		entry, err := sampleEntry(e)
+		if err != nil {
+			return nil, err
+		}
+		moov = append(moov, t.trak(entry)...)
+		mvex = appendFullBox(mvex, "trex", 0, 0, u32(t.id), u32(1), u32(0), u32(0), u32(0))
+		wr.tracks = append(wr.tracks, t)
+	}
+	b = appendBox(b, "moov", moov, appendBox(nil, "mvex", mvex))
+	if _, err := w.Write(b); err != nil {
+		return nil, err
+	}
+	return wr, nil
+}
+
+// Fragment writes the Matroska stream read from r as the fragmented MP4 file with a fragment per Cluster.
+// Tracks with codecs not supported by MP4 are skipped.
+func Fragment(w io.Writer, r *matroska.Reader) error {
+	var tracks []*matroska.TrackEntry
+	for _, it := range r.Segment.Tracks {
+		for _, e := range it.Entries {
+			if _, err := sampleEntry(e); err == nil {
+				tracks = append(tracks, e)
+			}
+		}
+	}
+	if len(tracks) == 0 {
+		return errors.New("mp4: no supported tracks")
+	}
+	wr, err := NewWriter(w, tracks)
+	if err != nil {
+		return err
+	}
+	for {
+		list, err := r.ReadCluster()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		for _, p := range list {
+			if wr.track(p.Track) == nil {
+				continue
+			}
+			if err = wr.WritePacket(p); err != nil {
+				return err
+			}
+		}
+		if err = wr.Flush(); err != nil {
+			return err
+		}
+	}
+}
+
+func (w *Writer) track(n matroska.TrackNumber) *writerTrack {
+	for _, it := range w.tracks {
+		if it.entry.Number == n {
+			return it
+		}
+	}
+	return nil
+}
+
+// WritePacket adds the packet to the current fragment.
+// Packets of each track must be in decoding order.
+func (w *Writer) WritePacket(p *matroska.Packet) error {
+	t := w.track(p.Track)
+	if t == nil {
+		return errors.New("mp4: unknown track")
+	}
+	t.packets = append(t.packets, p)
+	return nil
+}
+
+// Flush writes packets added since the last call as a movie fragment.
+func (w *Writer) Flush() error {
+	w.seq++
+	type run struct {
+		t       *writerTrack
+		samples []*fragmentSample
+	}
+	var runs []*run
+	for _, t := range w.tracks {
+		if len(t.packets) > 0 {
+			runs = append(runs, &run{t, t.samples()})
+			t.packets = nil
+		}
+	}
+	if len(runs) == 0 {
+		return nil
+	}
+	moof := func(offsets []uint32) []byte {
+		b := appendFullBox(nil, "mfhd", 0, 0, u32(w.seq))
+		for i, it := range runs {
+			trun := []byte(nil)
+			for _, s := range it.samples {
+				trun = append(trun, u32(s.duration)...)
+				trun = append(trun, u32(uint32(len(s.data)))...)
+				trun = append(trun, u32(s.flags)...)
+				trun = append(trun, u32(uint32(s.cto))...)
+			}
+			b = appendBox(b, "traf",
+				appendFullBox(nil, "tfhd", 0, 0x20000, u32(it.t.id)),
+				appendFullBox(nil, "tfdt", 1, 0, u64(uint64(it.samples[0].dts))),
+				appendFullBox(nil, "trun", 1, 0xf01, u32(uint32(len(it.samples))), u32(offsets[i]), trun))
+		}
+		return appendBox(nil, "moof", b)
+	}
+	offsets := make([]uint32, len(runs))
+	off := uint32(len(moof(offsets)) + 8)
+	var data [][]byte
+	for i, it := range runs {
+		offsets[i] = off
+		for _, s := range it.samples {
+			data = append(data, s.data)
+			off += uint32(len(s.data))
+		}
+	}
+	b := moof(offsets)
+	b = appendBox(b, "mdat", data...)
+	_, err := w.w.Write(b)
+	return err
+}
+
+// Close writes the last fragment.
+func (w *Writer) Close() error {
+	return w.Flush()
+}
+
+type fragmentSample struct {
+	dts      int64
+	cto      int32
+	duration uint32
+	flags    uint32
+	data     []byte
+}
+
+// samples returns samples of packets of the track.
+// Decode times of video samples are presentation times in increasing order.
+// Audio samples have codec frame durations if they are known.
+func (t *writerTrack) samples() []*fragmentSample {
+	n := len(t.packets)
+	pts := make([]int64, n)
+	for i, p := range t.packets {
+		pts[i] = mediaTime(p.Time, t.timescale)
+	}
+	dts := append([]int64(nil), pts...)
+	sort.Slice(dts, func(i, j int) bool {
+		return dts[i] < dts[j]
+	})
+	frames := make([]int64, n)
+	known := n > 0
+	for i, p := range t.packets {
+		if frames[i] = t.frameDuration(p); frames[i] == 0 {
+			known = false
+		}
+	}
+	if known {
+		// Continue decode times of the previous fragment unless there is a gap
+		start := pts[0]
+		if t.next >= 0 && abs(start-t.next) < frames[0] {
+			start = t.next
+		}
+		for i := range dts {
+			dts[i], start = start, start+frames[i]
+		}
+	}
+	list := make([]*fragmentSample, n)
+	for i, p := range t.packets {
+		s := &fragmentSample{dts: dts[i], cto: int32(pts[i] - dts[i]), flags: flagsNonSync, data: p.Data}
+		if p.Keyframe || t.entry.Type == matroska.TrackTypeAudio {
+			s.flags = flagsSync
+		}
+		switch {
+		case known:
+			s.duration = uint32(frames[i])
+		case i < n-1:
+			s.duration = uint32(dts[i+1] - dts[i])
+		case p.Duration > 0:
+			s.duration = uint32(mediaTime(p.Duration, t.timescale))
+		case t.entry.DefaultDuration > 0:
+			s.duration = uint32(mediaTime(t.entry.DefaultDuration, t.timescale))
+		case i > 0:
+			s.duration = list[i-1].duration
+		}
+		list[i] = s
+	}
+	if n > 0 {
+		t.next = list[n-1].dts + int64(list[n-1].duration)
+	}
+	return list
+}
+
+// frameDuration returns the duration of the audio frame in units of the timescale, or zero if not known.
+func (t *writerTrack) frameDuration(p *matroska.Packet) int64 {
+	switch {
+	case t.entry.CodecID == matroska.CodecOpus:
+		return mediaTime(codec.OpusPacketDuration(p.Data), t.timescale)
+	case strings.HasPrefix(t.entry.CodecID, matroska.CodecAAC):
+		return 1024
+	case t.entry.CodecID == matroska.CodecMP3:
+		return 1152
+	}
+	return 0
+}
+
+func abs(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// Unity transformation matrix
+var matrix = []byte{0, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x40, 0, 0, 0}
+
+func (t *writerTrack) trak(entry []byte) []byte {
+	e := t.entry
+	var width, height uint32
+	volume, handler, header := 0, "vide", appendFullBox(nil, "vmhd", 0, 1, make([]byte, 8))
+	if e.Type == matroska.TrackTypeAudio {
+		volume, handler, header = 0x100, "soun", appendFullBox(nil, "smhd", 0, 0, make([]byte, 4))
+	} else if e.Video != nil {
+		width, height = uint32(e.Video.Width)<<16, uint32(e.Video.Height)<<16
+	}
+	tkhd := appendFullBox(nil, "tkhd", 0, 3, make([]byte, 8), u32(t.id), make([]byte, 16),
+		u16(0), u16(0), u16(volume), u16(0), matrix, u32(width), u32(height))
+	lang := 0
+	if l := e.Language; len(l) == 3 {
+		lang = int(l[0]-0x60)<<10 | int(l[1]-0x60)<<5 | int(l[2]-0x60)
+	} else if l == "" {
+		lang = 0x15c7 // eng
+	}
+	mdhd := appendFullBox(nil, "mdhd", 0, 0, make([]byte, 8), u32(t.timescale), u32(0), u16(lang), u16(0))
+	hdlr := appendFullBox(nil, "hdlr", 0, 0, u32(0), []byte(handler), make([]byte, 12), []byte("go-matroska\x00"))
+	dinf := appendBox(nil, "dinf", appendFullBox(nil, "dref", 0, 0, u32(1), appendFullBox(nil, "url ", 0, 1)))
+	stbl := appendBox(nil, "stbl",
+		appendFullBox(nil, "stsd", 0, 0, u32(1), entry),
+		appendFullBox(nil, "stts", 0, 0, u32(0)),
+		appendFullBox(nil, "stsc", 0, 0, u32(0)),
+		appendFullBox(nil, "stsz", 0, 0, u32(0), u32(0)),
+		appendFullBox(nil, "stco", 0, 0, u32(0)))
+	minf := appendBox(nil, "minf", header, dinf, stbl)
+	return appendBox(nil, "trak", tkhd, appendBox(nil, "mdia", mdhd, hdlr, minf))
+}
+
+// sampleEntry returns the sample entry box of the track.
+func sampleEntry(e *matroska.TrackEntry) ([]byte, error) {
+	switch e.Type {
+	case matroska.TrackTypeVideo:
+		var typ, cfg string
+		switch e.CodecID {
+		case matroska.CodecAVC:
+			typ, cfg = "avc1", "avcC"
+		case matroska.CodecHEVC:
+			typ, cfg = "hvc1", "hvcC"
+		case matroska.CodecAV1:
+			typ, cfg = "av01", "av1C"
+		default:
+			return nil, errors.New("mp4: unsupported codec " + e.CodecID)
+		}
+		if len(e.CodecPrivate) == 0 || e.Video == nil {
+			return nil, errors.New("mp4: no decoder configuration")
+		}
+		v := make([]byte, 78)
+		v[7] = 1 // Data reference index
+		copy(v[24:], u16(e.Video.Width))
+		copy(v[26:], u16(e.Video.Height))
+		copy(v[28:], u32(0x480000))
+		copy(v[32:], u32(0x480000))
+		copy(v[40:], u16(1))
+		copy(v[74:], []byte{0, 0x18, 0xff, 0xff})
+		return appendBox(nil, typ, v, appendBox(nil, cfg, e.CodecPrivate)), nil
+	case matroska.TrackTypeAudio:
+		if e.Audio == nil {
+			return nil, errors.New("mp4: no audio settings")
+		}
+		a := make([]byte, 28)
+		a[7] = 1
+		copy(a[16:], u16(e.Audio.Channels))
+		copy(a[18:], u16(16))
+		copy(a[24:], u16(int(e.Audio.SamplingFreq)))
+		switch {
+		case e.CodecID == matroska.CodecOpus:
+			c, err := codec.ParseOpusConfig(e.CodecPrivate)
+			if err != nil {
+				return nil, err
+			}
+			dops := []byte{0, byte(c.Channels)}
+			dops = append(dops, u16(c.PreSkip)...)
+			dops = append(dops, u32(uint32(c.SampleRate))...)
+			dops = append(dops, u16(int(uint16(c.OutputGain)))...)
+			dops = append(dops, c.MappingFamily)
+			if c.MappingFamily != 0 {
+				dops = append(append(dops, byte(c.StreamCount), byte(c.CoupledCount)), c.Mapping...)
+			}
+			return appendBox(nil, "Opus", a, appendBox(nil, "dOps", dops)), nil
+		case strings.HasPrefix(e.CodecID, matroska.CodecAAC):
+			dsi := e.CodecPrivate
+			if len(dsi) == 0 {
+				c := &codec.AACConfig{ObjectType: codec.AACLC, SampleRate: int(e.Audio.SamplingFreq), Channels: e.Audio.Channels}
+				dsi = c.Bytes()
+			}
+			return appendBox(nil, "mp4a", a, esds(0x40, dsi)), nil
+		case e.CodecID == matroska.CodecMP3:
+			return appendBox(nil, "mp4a", a, esds(0x6b, nil)), nil
+		}
+	}
+	return nil, errors.New("mp4: unsupported codec " + e.CodecID)
+}
+
+// esds returns the esds box with the object type indication and the decoder specific info.
+func esds(oti byte, dsi []byte) []byte {
+	dec := append([]byte{oti, 0x15, 0, 0, 0}, make([]byte, 8)...)
+	if dsi != nil {
+		dec = appendDescriptor(dec, 5, dsi)
+	}
+	es := appendDescriptor([]byte{0, 1, 0}, 4, dec)
+	es = appendDescriptor(es, 6, []byte{2})
+	return appendFullBox(nil, "esds", 0, 0, appendDescriptor(nil, 3, es))
+}
+
+func appendDescriptor(dst []byte, tag byte, data []byte) []byte {
+	n := len(data)
+	dst = append(dst, tag, byte(n>>21&0x7f|0x80), byte(n>>14&0x7f|0x80), byte(n>>7&0x7f|0x80), byte(n&0x7f))
+	return append(dst, data...)
+}
@@ -0,0 +1,240 @@
+// Package ogg implements the Ogg bitstream format.
+// See RFC 3533.
+package ogg
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// Page header flags
+const (
+	FlagContinued = 0x01
+	FlagBOS       = 0x02
+	FlagEOS       = 0x04
+)
+
+const (
+	maxSegments = 255
+	maxPageSize = 4096
+)
+
+var errInvalidPage = errors.New("ogg: invalid page")
+
+// Reader reads packets of a logical bitstream from Ogg pages.
+// The bitstream of the first page is read, pages of other multiplexed bitstreams are skipped.
+type Reader struct {
+	Serial uint32
+
+	r       io.Reader
+	init    bool
+	eos     bool
+	packets [][]byte
+	granule int64
+	partial []byte
+}
+
+// NewReader returns a new reader.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: r}
+}
+
+// ReadPacket reads the next packet with the granule position of its page if it is the last packet
+// completed on the page, otherwise the granule position is -1.
+// Returns io.EOF at the end of the bitstream.
+func (r *Reader) ReadPacket() ([]byte, int64, error) {
+	for len(r.packets) == 0 {
+		if r.eos {
+			return nil, -1, io.EOF
+		}
+		if err := r.readPage(); err != nil {
+			return nil, -1, err
+		}
+	}
+	b := r.packets[0]
+	r.packets = r.packets[1:]
+	if len(r.packets) == 0 {
+		return b, r.granule, nil
+	}
+	return b, -1, nil
+}
+
+func (r *Reader) readPage() error {
+	h := make([]byte, 27, 27+maxSegments)
+	if _, err := io.ReadFull(r.r, h); err != nil {
+		return err
+	}
+	if string(h[:4]) != "OggS" || h[4] != 0 {
+		return errInvalidPage
+	}
+	h = h[:27+int(h[26])]
+	if _, err := io.ReadFull(r.r, h[27:]); err != nil {
+		return unexpected(err)
+	}
+	size := 0
+	for _, v := range h[27:] {
+		size += int(v)
+	}
+	b := make([]byte, len(h)+size)
+	copy(b, h)
+	if _, err := io.ReadFull(r.r, b[len(h):]); err != nil {
+		return unexpected(err)
+	}
+	sum := binary.LittleEndian.Uint32(b[22:])
+	b[22], b[23], b[24], b[25] = 0, 0, 0, 0
+	if crc(b) != sum {
+		return errors.New("ogg: checksum mismatch")
+	}
+	flags, serial := b[5], binary.LittleEndian.Uint32(b[14:])
+	if !r.init {
+		if flags&FlagBOS == 0 {
+			return errInvalidPage
+		}
+		r.Serial, r.init = serial, true
+	} else if serial != r.Serial {
+		return nil
+	}
+	r.granule = int64(binary.LittleEndian.Uint64(b[6:]))
+	r.eos = flags&FlagEOS != 0
+	if flags&FlagContinued == 0 {
+		r.partial = nil
+	}
+	data, lost := b[len(h):], flags&FlagContinued != 0 && r.partial == nil
+	for _, v := range h[27:] {
+		r.partial = append(r.partial, data[:v]...)
+		data = data[v:]
+		if v < 255 {
+			// Continuation of a packet from a page that was not read
+			if !lost {
+				r.packets = append(r.packets, r.partial)
+			}
+			r.partial, lost = nil, false
+		}
+	}
+	return nil
+}
+
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// Writer writes packets of a logical bitstream into Ogg pages.
+type Writer struct {
+	w      io.Writer
+	serial uint32
+	seq    uint32
+	flags  byte
+	lacing []byte
+	ends   []int64 // Granule positions of packets ending at lacing values or -1
+	data   []byte
+	last   int64
+}
+
+// NewWriter returns a new writer of the logical bitstream with the given serial number.
+func NewWriter(w io.Writer, serial uint32) *Writer {
+	return &Writer{w: w, serial: serial, flags: FlagBOS}
+}
+
+// WritePacket adds the packet with the granule position at its end.
+// Pages are written when they are full.
+func (w *Writer) WritePacket(b []byte, granule int64) error {
+	n := len(b)
+	for {
+		v := n
+		if v > 255 {
+			v = 255
+		}
+		w.lacing = append(w.lacing, byte(v))
+		w.ends = append(w.ends, -1)
+		if n -= v; v < 255 {
+			break
+		}
+	}
+	w.ends[len(w.ends)-1] = granule
+	w.data = append(w.data, b...)
+	w.last = granule
+	for len(w.lacing) >= maxSegments || len(w.data) >= maxPageSize {
+		if err := w.writePage(len(w.lacing), 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Flush writes added packets into a new page.
+func (w *Writer) Flush() error {
+	if len(w.lacing) == 0 {
+		return nil
+	}
+	return w.writePage(len(w.lacing), 0)
+}
+
+// Close writes the last page of the bitstream.
+func (w *Writer) Close() error {
+	return w.writePage(len(w.lacing), FlagEOS)
+}
+
+func (w *Writer) writePage(n int, flags byte) error {
+	if n > maxSegments {
+		n = maxSegments
+	}
+	size, granule := 0, int64(-1)
+	for i, v := range w.lacing[:n] {
+		size += int(v)
+		if w.ends[i] >= 0 {
+			granule = w.ends[i]
+		}
+	}
+	if n == 0 {
+		granule = w.last
+	}
+	b := make([]byte, 27+n, 27+n+size)
+	copy(b, "OggS")
+	b[5] = w.flags | flags
+	binary.LittleEndian.PutUint64(b[6:], uint64(granule))
+	binary.LittleEndian.PutUint32(b[14:], w.serial)
+	binary.LittleEndian.PutUint32(b[18:], w.seq)
+	b[26] = byte(n)
+	copy(b[27:], w.lacing[:n])
+	b = append(b, w.data[:size]...)
+	binary.LittleEndian.PutUint32(b[22:], crc(b))
+	if _, err := w.w.Write(b); err != nil {
+		return err
+	}
+	w.seq++
+	w.flags = 0
+	if n > 0 && w.ends[n-1] < 0 {
+		w.flags = FlagContinued
+	}
+	w.lacing = append(w.lacing[:0], w.lacing[n:]...)
+	w.ends = append(w.ends[:0], w.ends[n:]...)
+	w.data = append(w.data[:0], w.data[size:]...)
+	return nil
+}
+
+var crcTable = func() (t [256]uint32) {
+	for i := range t {
+		r := uint32(i) << 24
+		for j := 0; j < 8; j++ {
+			if r&0x80000000 != 0 {
+				r = r<<1 ^ 0x04c11db7
+			} else {
+				r <<= 1
+			}
+		}
+		t[i] = r
+	}
+	return
+}()
+
+func crc(b []byte) uint32 {
+	var c uint32
+	for _, v := range b {
+		c = c<<8 ^ crcTable[byte(c>>24)^v]
+	}
+	return c
+}
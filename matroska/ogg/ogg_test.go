@@ -0,0 +1,123 @@
+package ogg
+
+import (
+	"bytes"
+	"github.com/pixelbender/go-matroska/matroska"
+	"github.com/pixelbender/go-matroska/matroska/codec"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestReader(t *testing.T) {
+	b := &bytes.Buffer{}
+	w, other := NewWriter(b, 1), NewWriter(b, 2)
+	packets := [][]byte{[]byte("head"), bytes.Repeat([]byte{1}, 10000), []byte("a"), bytes.Repeat([]byte{2}, 255), []byte("b")}
+	for i, it := range packets {
+		if err := w.WritePacket(it, int64(i)); err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 {
+			if err := w.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			if err := other.WritePacket([]byte("other"), 0); err != nil {
+				t.Fatal(err)
+			}
+			if err := other.Close(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	r := NewReader(b)
+	for i, it := range packets {
+		v, granule, err := r.ReadPacket()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(v, it) {
+			t.Fatalf("Unexpected packet %d: %d bytes", i, len(v))
+		}
+		// Packets after the large one are on the last page
+		if want := map[int]int64{0: 0, 1: 1, 4: 4}[i]; (i == 2 || i == 3) != (granule < 0) || granule >= 0 && granule != want {
+			t.Errorf("Unexpected granule of packet %d: %d", i, granule)
+		}
+	}
+	if _, _, err := r.ReadPacket(); err != io.EOF || r.Serial != 1 {
+		t.Errorf("Unexpected end: %v, serial: %d", err, r.Serial)
+	}
+}
+
+func TestTrack(t *testing.T) {
+	b := &bytes.Buffer{}
+	w := NewWriter(b, 1)
+	w.WritePacket((&codec.OpusConfig{Channels: 2, PreSkip: 312, SampleRate: 44100}).Bytes(), 0)
+	w.Flush()
+	w.WritePacket([]byte("OpusTags\x00\x00\x00\x00\x00\x00\x00\x00"), 0)
+	w.Flush()
+	// 10 packets of 20 ms, last 500 samples are padding
+	for i := 0; i < 10; i++ {
+		granule := int64(-1)
+		if i == 9 {
+			granule = 10*960 - 500
+		}
+		w.WritePacket([]byte{0xf8, byte(i)}, granule)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	tr, err := NewTrack(NewReader(b), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := tr.Entry
+	if e.CodecID != matroska.CodecOpus || e.CodecDelay != 6500*time.Microsecond || e.SeekPreRoll != 80*time.Millisecond || e.Audio.Channels != 2 || e.Audio.SamplingFreq != 48000 {
+		t.Fatalf("Unexpected track entry: %+v", e)
+	}
+	out := &bytes.Buffer{}
+	mw, err := matroska.NewWriter(out, &matroska.Segment{Tracks: []*matroska.Track{{Entries: []*matroska.TrackEntry{e}}}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		p, err := tr.ReadPacket()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = mw.WritePacket(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	mr, err := matroska.NewReader(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; ; i++ {
+		p, err := mr.ReadPacket()
+		if err == io.EOF {
+			if i != 10 {
+				t.Errorf("Unexpected number of packets: %d", i)
+			}
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		var pad time.Duration
+		if i == 9 {
+			pad = 500 * time.Second / 48000
+		}
+		if p.Time != time.Duration(i)*20*time.Millisecond || !p.Keyframe || p.DiscardPadding != pad || p.Data[1] != byte(i) {
+			t.Errorf("Unexpected packet %d: %+v", i, p)
+		}
+	}
+}
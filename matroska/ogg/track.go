@@ -0,0 +1,154 @@
+package ogg
+
+import (
+	"bytes"
+	"errors"
+	"github.com/pixelbender/go-matroska/matroska"
+	"github.com/pixelbender/go-matroska/matroska/codec"
+	"io"
+	"time"
+)
+
+// Track reads Opus or Vorbis packets of the Ogg bitstream as packets of the Matroska track.
+// Trailing samples cut by the last granule position are signalled by DiscardPadding of the last packet.
+type Track struct {
+	Entry *matroska.TrackEntry
+
+	r      *Reader
+	rate   int64
+	vorbis *codec.VorbisConfig
+	block  int   // Previous Vorbis block size
+	pos    int64 // Number of samples of packets read so far
+	start  int64 // Granule position of the first sample
+	synced bool  // Whether start is known
+	next   *packet
+}
+
+type packet struct {
+	data    []byte
+	granule int64
+}
+
+// NewTrack reads the codec headers from r and returns the track with the given number.
+func NewTrack(r *Reader, number matroska.TrackNumber) (*Track, error) {
+	b, _, err := r.ReadPacket()
+	if err != nil {
+		return nil, unexpected(err)
+	}
+	e := &matroska.TrackEntry{
+		Number:         number,
+		Type:           matroska.TrackTypeAudio,
+		Enabled:        true,
+		Default:        true,
+		CodecDecodeAll: true,
+		Lacing:         true,
+	}
+	t := &Track{Entry: e, r: r}
+	switch {
+	case bytes.HasPrefix(b, []byte("OpusHead")):
+		c, err := codec.ParseOpusConfig(b)
+		if err != nil {
+			return nil, err
+		}
+		// Skip OpusTags
+		if _, _, err = r.ReadPacket(); err != nil {
+			return nil, unexpected(err)
+		}
+		e.CodecID = matroska.CodecOpus
+		e.CodecPrivate = b
+		e.CodecDelay = time.Duration(c.PreSkip) * time.Second / 48000
+		e.SeekPreRoll = 80 * time.Millisecond
+		e.Audio = &matroska.AudioTrack{SamplingFreq: 48000, Channels: c.Channels}
+		t.rate = 48000
+	case bytes.HasPrefix(b, []byte("\x01vorbis")):
+		headers := [][]byte{b}
+		for len(headers) < 3 {
+			h, _, err := r.ReadPacket()
+			if err != nil {
+				return nil, unexpected(err)
+			}
+			headers = append(headers, h)
+		}
+		c, err := codec.ParseVorbisConfig(codec.JoinXiph(headers))
+		if err != nil {
+			return nil, err
+		}
+		if c.Modes == nil {
+			return nil, errors.New("ogg: unsupported vorbis setup header")
+		}
+		e.CodecID = matroska.CodecVorbis
+		e.CodecPrivate = c.Bytes()
+		e.Audio = &matroska.AudioTrack{SamplingFreq: float64(c.SampleRate), Channels: c.Channels}
+		t.rate, t.vorbis = int64(c.SampleRate), c
+	default:
+		return nil, errors.New("ogg: unsupported codec")
+	}
+	if t.next, err = t.read(); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return t, nil
+}
+
+// ReadPacket reads the next packet. Returns io.EOF at the end of the bitstream.
+func (t *Track) ReadPacket() (*matroska.Packet, error) {
+	cur := t.next
+	if cur == nil {
+		return nil, io.EOF
+	}
+	next, err := t.read()
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	t.next = next
+	n := t.samples(cur.data)
+	p := &matroska.Packet{
+		Track:    t.Entry.Number,
+		Time:     t.duration(t.pos),
+		Keyframe: true,
+		Data:     cur.data,
+	}
+	t.pos += n
+	if cur.granule >= 0 && !t.synced {
+		// The stream ending on its first page starts at zero
+		if next != nil {
+			t.start = cur.granule - t.pos
+		}
+		t.synced = true
+	}
+	if next == nil {
+		p.Duration = t.duration(n)
+		if t.synced && cur.granule >= 0 {
+			if pad := t.pos - (cur.granule - t.start); pad > 0 && pad <= n {
+				p.DiscardPadding = t.duration(pad)
+			}
+		}
+	}
+	return p, nil
+}
+
+func (t *Track) read() (*packet, error) {
+	b, granule, err := t.r.ReadPacket()
+	if err != nil {
+		return nil, err
+	}
+	return &packet{b, granule}, nil
+}
+
+// samples returns the number of samples decoded from the packet.
+func (t *Track) samples(b []byte) int64 {
+	if t.vorbis == nil {
+		return int64(codec.OpusPacketDuration(b) * 48000 / time.Second)
+	}
+	n := t.vorbis.BlockSize(b)
+	if t.block == 0 {
+		t.block = n
+		return 0
+	}
+	v := (t.block + n) / 4
+	t.block = n
+	return int64(v)
+}
+
+func (t *Track) duration(n int64) time.Duration {
+	return time.Duration(n) * time.Second / time.Duration(t.rate)
+}
@@ -0,0 +1,246 @@
+package rtp
+
+import (
+	"errors"
+	"github.com/pixelbender/go-matroska/matroska"
+	"github.com/pixelbender/go-matroska/matroska/codec"
+)
+
+// depacketizer reassembles frames of the codec from RTP payloads.
+type depacketizer interface {
+	// start reports whether the payload starts a frame.
+	start(payload []byte) bool
+	// append appends codec data of the payload to the frame.
+	append(frame, payload []byte) ([]byte, error)
+	// keyframe reports whether the frame is a key frame.
+	keyframe(frame []byte) bool
+}
+
+var errPayload = errors.New("rtp: invalid payload")
+
+func newDepacketizer(t *matroska.TrackEntry) (depacketizer, error) {
+	switch t.CodecID {
+	case matroska.CodecVP8:
+		return vp8Depacketizer{}, nil
+	case matroska.CodecVP9:
+		return vp9Depacketizer{}, nil
+	case matroska.CodecOpus:
+		return opusDepacketizer{}, nil
+	case matroska.CodecAVC:
+		return &avcDepacketizer{fu: -1}, nil
+	}
+	return nil, errors.New("rtp: unsupported codec " + t.CodecID)
+}
+
+// vp8Depacketizer implements the VP8 payload format.
+// See RFC 7741.
+type vp8Depacketizer struct{}
+
+func (vp8Depacketizer) start(b []byte) bool {
+	// Start of the first partition
+	return len(b) > 0 && b[0]&0x10 != 0 && b[0]&7 == 0
+}
+
+func (vp8Depacketizer) append(frame, b []byte) ([]byte, error) {
+	if len(b) < 1 {
+		return nil, errPayload
+	}
+	n := 1
+	if b[0]&0x80 != 0 {
+		if len(b) < 2 {
+			return nil, errPayload
+		}
+		x := b[1]
+		n++
+		if x&0x80 != 0 { // PictureID
+			if len(b) <= n {
+				return nil, errPayload
+			}
+			if b[n]&0x80 != 0 {
+				n++
+			}
+			n++
+		}
+		if x&0x40 != 0 { // TL0PICIDX
+			n++
+		}
+		if x&0x30 != 0 { // TID, KEYIDX
+			n++
+		}
+	}
+	if n > len(b) {
+		return nil, errPayload
+	}
+	return append(frame, b[n:]...), nil
+}
+
+func (vp8Depacketizer) keyframe(b []byte) bool {
+	return codec.VP8Keyframe(b)
+}
+
+// vp9Depacketizer implements the VP9 payload format.
+// See draft-ietf-payload-vp9.
+type vp9Depacketizer struct{}
+
+func (vp9Depacketizer) start(b []byte) bool {
+	return len(b) > 0 && b[0]&0x08 != 0
+}
+
+func (vp9Depacketizer) append(frame, b []byte) ([]byte, error) {
+	if len(b) < 1 {
+		return nil, errPayload
+	}
+	h, n := b[0], 1
+	next := func() (byte, bool) {
+		if n >= len(b) {
+			return 0, false
+		}
+		n++
+		return b[n-1], true
+	}
+	if h&0x80 != 0 { // PictureID
+		v, ok := next()
+		if !ok {
+			return nil, errPayload
+		}
+		if v&0x80 != 0 {
+			n++
+		}
+	}
+	if h&0x20 != 0 { // Layer indices
+		n++
+		if h&0x10 == 0 { // TL0PICIDX in non-flexible mode
+			n++
+		}
+	}
+	if h&0x50 == 0x50 { // Reference indices in flexible mode
+		for i := 0; i < 3; i++ {
+			v, ok := next()
+			if !ok {
+				return nil, errPayload
+			}
+			if v&1 == 0 {
+				break
+			}
+		}
+	}
+	if h&0x02 != 0 { // Scalability structure
+		v, ok := next()
+		if !ok {
+			return nil, errPayload
+		}
+		if v&0x10 != 0 {
+			n += 4 * (int(v>>5) + 1)
+		}
+		if v&0x08 != 0 {
+			g, ok := next()
+			if !ok {
+				return nil, errPayload
+			}
+			for i := 0; i < int(g); i++ {
+				if v, ok = next(); !ok {
+					return nil, errPayload
+				}
+				n += int(v >> 2 & 3)
+			}
+		}
+	}
+	if n > len(b) {
+		return nil, errPayload
+	}
+	return append(frame, b[n:]...), nil
+}
+
+func (vp9Depacketizer) keyframe(b []byte) bool {
+	return codec.VP9Keyframe(b)
+}
+
+// opusDepacketizer implements the Opus payload format, each payload is a single packet.
+// See RFC 7587.
+type opusDepacketizer struct{}
+
+func (opusDepacketizer) start(b []byte) bool {
+	return true
+}
+
+func (opusDepacketizer) append(frame, b []byte) ([]byte, error) {
+	return append(frame, b...), nil
+}
+
+func (opusDepacketizer) keyframe(b []byte) bool {
+	return true
+}
+
+// AVC packetization types
+const (
+	avcSTAPA = 24
+	avcFUA   = 28
+)
+
+// avcDepacketizer implements the H.264 payload format in the non-interleaved mode.
+// Frames consist of NAL units with 4-byte length prefixes.
+// See RFC 6184.
+type avcDepacketizer struct {
+	fu int // Offset of the fragmented NAL unit
+}
+
+func (d *avcDepacketizer) start(b []byte) bool {
+	return len(b) > 1 && (b[0]&0x1f != avcFUA || b[1]&0x80 != 0)
+}
+
+func (d *avcDepacketizer) append(frame, b []byte) ([]byte, error) {
+	if len(frame) == 0 {
+		d.fu = -1
+	}
+	if len(b) < 1 {
+		return nil, errPayload
+	}
+	switch b[0] & 0x1f {
+	case avcSTAPA:
+		for b = b[1:]; len(b) > 0; {
+			if len(b) < 2 {
+				return nil, errPayload
+			}
+			n := int(b[0])<<8 | int(b[1])
+			if len(b) < 2+n {
+				return nil, errPayload
+			}
+			frame = codec.AppendNALUs(frame, 4, b[2:2+n])
+			b = b[2+n:]
+		}
+	case avcFUA:
+		if len(b) < 2 {
+			return nil, errPayload
+		}
+		if b[1]&0x80 != 0 {
+			d.fu = len(frame)
+			frame = append(frame, 0, 0, 0, 0, b[0]&0xe0|b[1]&0x1f)
+		} else if d.fu < 0 {
+			return nil, errPayload
+		}
+		frame = append(frame, b[2:]...)
+		if b[1]&0x40 != 0 {
+			n := len(frame) - d.fu - 4
+			frame[d.fu], frame[d.fu+1], frame[d.fu+2], frame[d.fu+3] = byte(n>>24), byte(n>>16), byte(n>>8), byte(n)
+			d.fu = -1
+		}
+	case 25, 26, 27, 29:
+		return nil, errors.New("rtp: interleaved H.264 packetization is not supported")
+	default:
+		frame = codec.AppendNALUs(frame, 4, b)
+	}
+	return frame, nil
+}
+
+func (d *avcDepacketizer) keyframe(b []byte) bool {
+	list, err := codec.SplitNALUs(b, 4)
+	if err != nil {
+		return false
+	}
+	for _, it := range list {
+		if len(it) > 0 && it[0]&0x1f == codec.AVCNALIDR {
+			return true
+		}
+	}
+	return false
+}
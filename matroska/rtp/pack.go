@@ -0,0 +1,154 @@
+package rtp
+
+import (
+	"errors"
+	"github.com/pixelbender/go-matroska/matroska"
+	"github.com/pixelbender/go-matroska/matroska/codec"
+)
+
+// packetizer splits frames of the codec into RTP payloads.
+type packetizer interface {
+	// packetize returns payloads of the frame of at most n bytes.
+	packetize(frame []byte, key bool, n int) ([][]byte, error)
+}
+
+func newPacketizer(t *matroska.TrackEntry) (packetizer, error) {
+	switch t.CodecID {
+	case matroska.CodecVP8:
+		return &vp8Packetizer{}, nil
+	case matroska.CodecVP9:
+		return &vp9Packetizer{}, nil
+	case matroska.CodecOpus:
+		return opusPacketizer{}, nil
+	case matroska.CodecAVC:
+		p := &avcPacketizer{size: 4}
+		if len(t.CodecPrivate) > 0 {
+			c, err := codec.ParseAVCConfig(t.CodecPrivate)
+			if err != nil {
+				return nil, err
+			}
+			p.size, p.params = c.LengthSize, append(c.SPS, c.PPS...)
+		}
+		return p, nil
+	}
+	return nil, errors.New("rtp: unsupported codec " + t.CodecID)
+}
+
+// errMTU is returned if the MTU leaves no room for the payload.
+var errMTU = errors.New("rtp: MTU is too small")
+
+// split splits b into chunks of at most n bytes with the header returned by fn for each chunk.
+func split(b []byte, n int, fn func(first, last bool) []byte) ([][]byte, error) {
+	var list [][]byte
+	for i := 0; ; {
+		h := fn(i == 0, false)
+		if n <= len(h) {
+			return nil, errMTU
+		}
+		end := i + n - len(h)
+		if end >= len(b) {
+			end, h = len(b), fn(i == 0, true)
+		}
+		list = append(list, append(h, b[i:end]...))
+		if i = end; i >= len(b) {
+			return list, nil
+		}
+	}
+}
+
+// vp8Packetizer writes payload descriptors with 15-bit picture IDs.
+type vp8Packetizer struct {
+	picture uint16
+}
+
+func (p *vp8Packetizer) packetize(b []byte, key bool, n int) ([][]byte, error) {
+	id := p.picture & 0x7fff
+	p.picture++
+	return split(b, n, func(first, last bool) []byte {
+		h := []byte{0x80, 0x80, 0x80 | byte(id>>8), byte(id)}
+		if first {
+			h[0] |= 0x10
+		}
+		return h
+	})
+}
+
+// vp9Packetizer writes non-flexible mode payload descriptors of a single layer with 15-bit picture IDs.
+type vp9Packetizer struct {
+	picture uint16
+}
+
+func (p *vp9Packetizer) packetize(b []byte, key bool, n int) ([][]byte, error) {
+	id := p.picture & 0x7fff
+	p.picture++
+	return split(b, n, func(first, last bool) []byte {
+		h := []byte{0x80, 0x80 | byte(id>>8), byte(id)}
+		if !key {
+			h[0] |= 0x40
+		}
+		if first {
+			h[0] |= 0x08
+		}
+		if last {
+			h[0] |= 0x04
+		}
+		return h
+	})
+}
+
+type opusPacketizer struct{}
+
+func (opusPacketizer) packetize(b []byte, key bool, n int) ([][]byte, error) {
+	return [][]byte{b}, nil
+}
+
+// avcPacketizer writes single NAL unit packets and FU-A fragments.
+// Parameter sets of CodecPrivate are sent before keyframes without them.
+type avcPacketizer struct {
+	size   int
+	params [][]byte
+}
+
+func (p *avcPacketizer) packetize(b []byte, key bool, n int) ([][]byte, error) {
+	list, err := codec.SplitNALUs(b, p.size)
+	if err != nil {
+		return nil, err
+	}
+	if key {
+		params := true
+		for _, it := range list {
+			if len(it) > 0 && it[0]&0x1f == codec.AVCNALSPS {
+				params = false
+			}
+		}
+		if params {
+			list = append(append([][]byte(nil), p.params...), list...)
+		}
+	}
+	var res [][]byte
+	for _, it := range list {
+		if len(it) == 0 {
+			continue
+		}
+		if len(it) <= n {
+			res = append(res, it)
+			continue
+		}
+		typ := it[0] & 0x1f
+		frags, err := split(it[1:], n, func(first, last bool) []byte {
+			h := []byte{it[0]&0xe0 | avcFUA, typ}
+			if first {
+				h[1] |= 0x80
+			}
+			if last {
+				h[1] |= 0x40
+			}
+			return h
+		})
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, frags...)
+	}
+	return res, nil
+}
@@ -0,0 +1,295 @@
+package rtp
+
+import (
+	"errors"
+	"github.com/pixelbender/go-matroska/matroska"
+	"github.com/pixelbender/go-matroska/matroska/codec"
+	"io"
+	"sort"
+	"time"
+)
+
+// Track describes the RTP stream of a track.
+type Track struct {
+	Entry       *matroska.TrackEntry
+	PayloadType uint8
+	// ClockRate is the RTP clock rate, 90000 for video and 48000 for audio if zero.
+	ClockRate int
+}
+
+// Recorder reassembles frames from RTP packets and writes them into the Matroska stream.
+//
+// Packets of each track are delayed by Latency packets to reorder them.
+// Frames with lost packets are dropped, video tracks continue from the next keyframe.
+// Tracks are aligned by the arrival time of their first packets.
+// Frames are interleaved in time order by the Matroska writer, Interleave is 2 seconds if zero.
+// Frames later than the Interleave window behind the newest frame are dropped.
+//
+// CodecPrivate of H.264 tracks is taken from parameter sets of the first keyframe if not set,
+// the Matroska header is written when all such tracks have it.
+type Recorder struct {
+	// Latency is the number of packets buffered per track for reordering, 32 if zero.
+	Latency int
+
+	out     io.Writer
+	opt     *matroska.WriterOptions
+	seg     *matroska.Segment
+	w       *matroska.Writer
+	tracks  map[uint8]*track
+	start   time.Time
+	newest  time.Duration
+	pending []*matroska.Packet
+}
+
+type track struct {
+	*Track
+	dep     depacketizer
+	queue   []*queued
+	seq     int64 // Highest extended sequence number received
+	next    int64 // Extended sequence number of the next packet to process
+	last    int64 // Extended timestamp of the last packet
+	base    int64 // Extended timestamp of the first packet
+	offset  time.Duration
+	frame   []byte
+	ts      int64
+	active  bool
+	needKey bool
+}
+
+type queued struct {
+	seq int64
+	*Packet
+}
+
+// NewRecorder returns a new recorder writing tracks to w.
+func NewRecorder(w io.Writer, tracks []*Track, opt *matroska.WriterOptions) (*Recorder, error) {
+	o := &matroska.WriterOptions{}
+	if opt != nil {
+		*o = *opt
+	}
+	if o.Interleave <= 0 {
+		o.Interleave = 2 * time.Second
+	}
+	r := &Recorder{out: w, opt: o, seg: &matroska.Segment{}, tracks: make(map[uint8]*track)}
+	var entries []*matroska.TrackEntry
+	for _, it := range tracks {
+		if r.tracks[it.PayloadType] != nil {
+			return nil, errors.New("rtp: duplicate payload type")
+		}
+		dep, err := newDepacketizer(it.Entry)
+		if err != nil {
+			return nil, err
+		}
+		t := &track{Track: it, dep: dep, seq: -1, next: -1, needKey: it.Entry.Type == matroska.TrackTypeVideo}
+		if t.ClockRate == 0 {
+			t.ClockRate = 90000
+			if it.Entry.Type == matroska.TrackTypeAudio {
+				t.ClockRate = 48000
+			}
+		}
+		r.tracks[it.PayloadType] = t
+		entries = append(entries, it.Entry)
+	}
+	r.seg.Tracks = []*matroska.Track{{Entries: entries}}
+	return r, nil
+}
+
+// WriteRTP writes the RTP packet received at the given time.
+// Packets of unknown payload types are ignored, b may be reused after the call.
+func (r *Recorder) WriteRTP(b []byte, arrival time.Time) error {
+	p, err := ParsePacket(b)
+	if err != nil {
+		return err
+	}
+	t := r.tracks[p.PayloadType]
+	if t == nil {
+		return nil
+	}
+	// The payload is queued for reordering
+	p.Payload = append([]byte(nil), p.Payload...)
+	if r.start.IsZero() {
+		r.start = arrival
+	}
+	if t.seq < 0 {
+		// Sequence numbers are extended from the middle of the range to allow preceding packets
+		t.seq, t.offset = 1<<32|int64(p.SequenceNumber), arrival.Sub(r.start)
+	}
+	seq := t.seq + int64(int16(p.SequenceNumber-uint16(t.seq)))
+	if seq < t.next {
+		// Late or duplicate packet
+		return nil
+	}
+	if seq > t.seq {
+		t.seq = seq
+	}
+	i := sort.Search(len(t.queue), func(i int) bool { return t.queue[i].seq >= seq })
+	if i < len(t.queue) && t.queue[i].seq == seq {
+		return nil
+	}
+	t.queue = append(t.queue, nil)
+	copy(t.queue[i+1:], t.queue[i:])
+	t.queue[i] = &queued{seq, p}
+	latency := r.Latency
+	if latency <= 0 {
+		latency = 32
+	}
+	return r.drain(t, latency)
+}
+
+// drain processes queued packets in order while more than n packets are queued.
+func (r *Recorder) drain(t *track, n int) error {
+	for len(t.queue) > n {
+		q := t.queue[0]
+		t.queue = t.queue[1:]
+		if t.next < 0 {
+			t.last = 1<<32 | int64(q.Timestamp)
+			t.base = t.last
+		} else if q.seq != t.next {
+			t.lost()
+		}
+		t.next = q.seq + 1
+		if err := r.process(t, q.Packet); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Recorder) process(t *track, p *Packet) error {
+	ts := t.last + int64(int32(p.Timestamp-uint32(t.last)))
+	t.last = ts
+	if t.active && ts != t.ts {
+		// The end of the frame is lost
+		t.lost()
+	}
+	if !t.active {
+		if !t.dep.start(p.Payload) {
+			return nil
+		}
+		t.frame, t.ts, t.active = t.frame[:0], ts, true
+	}
+	var err error
+	if t.frame, err = t.dep.append(t.frame, p.Payload); err != nil {
+		t.lost()
+		return nil
+	}
+	if !p.Marker && t.Entry.Type == matroska.TrackTypeVideo {
+		return nil
+	}
+	t.active = false
+	key := t.dep.keyframe(t.frame)
+	if t.needKey && !key {
+		return nil
+	}
+	t.needKey = false
+	if key && t.Entry.CodecID == matroska.CodecAVC && len(t.Entry.CodecPrivate) == 0 {
+		if err = setAVCConfig(t.Entry, t.frame); err != nil {
+			return err
+		}
+	}
+	return r.write(&matroska.Packet{
+		Track:    t.Entry.Number,
+		Time:     t.offset + time.Duration((ts-t.base)*int64(time.Second)/int64(t.ClockRate)),
+		Keyframe: key,
+		Data:     append([]byte(nil), t.frame...),
+	})
+}
+
+// lost drops the current frame, video tracks wait for the next keyframe.
+func (t *track) lost() {
+	t.active = false
+	if t.Entry.Type == matroska.TrackTypeVideo {
+		t.needKey = true
+	}
+}
+
+func setAVCConfig(e *matroska.TrackEntry, frame []byte) error {
+	list, err := codec.SplitNALUs(frame, 4)
+	if err != nil {
+		return err
+	}
+	c, err := codec.AVCConfigFromAnnexB(codec.AppendAnnexB(nil, list...))
+	if err != nil {
+		return err
+	}
+	e.CodecPrivate = c.Bytes()
+	if e.Video == nil {
+		e.Video = &matroska.VideoTrack{}
+	}
+	if e.Video.Width == 0 {
+		e.Video.Width, e.Video.Height = c.Width, c.Height
+	}
+	return nil
+}
+
+// write writes the packet if the header is written, otherwise queues it.
+func (r *Recorder) write(p *matroska.Packet) error {
+	if p.Time < r.newest-r.opt.Interleave {
+		// Too late to be written in time order
+		return nil
+	}
+	if p.Time > r.newest {
+		r.newest = p.Time
+	}
+	if r.w == nil {
+		r.pending = append(r.pending, p)
+		if !r.ready() {
+			return nil
+		}
+		return r.open()
+	}
+	return r.w.WritePacket(p)
+}
+
+// ready reports whether the header can be written and queued frames span all tracks,
+// which are interleaved by the writer from then on, or the Interleave window.
+func (r *Recorder) ready() bool {
+	started := make(map[matroska.TrackNumber]bool)
+	oldest := r.newest
+	for _, it := range r.pending {
+		started[it.Track] = true
+		if it.Time < oldest {
+			oldest = it.Time
+		}
+	}
+	for _, t := range r.tracks {
+		if t.Entry.CodecID == matroska.CodecAVC && len(t.Entry.CodecPrivate) == 0 {
+			return false
+		}
+	}
+	return len(started) == len(r.tracks) || r.newest-oldest > r.opt.Interleave
+}
+
+// open writes the header and queued frames in time order.
+func (r *Recorder) open() error {
+	w, err := matroska.NewWriter(r.out, r.seg, r.opt)
+	if err != nil {
+		return err
+	}
+	r.w = w
+	sort.SliceStable(r.pending, func(i, j int) bool {
+		return r.pending[i].Time < r.pending[j].Time
+	})
+	for _, it := range r.pending {
+		if err = w.WritePacket(it); err != nil {
+			return err
+		}
+	}
+	r.pending = nil
+	return nil
+}
+
+// Close writes queued packets and closes the Matroska writer.
+func (r *Recorder) Close() error {
+	for _, t := range r.tracks {
+		if err := r.drain(t, 0); err != nil {
+			return err
+		}
+	}
+	if r.w == nil {
+		if err := r.open(); err != nil {
+			return err
+		}
+	}
+	return r.w.Close()
+}
@@ -0,0 +1,63 @@
+// Package rtp records RTP streams of WebRTC sessions into Matroska files and replays them.
+package rtp
+
+import "errors"
+
+// Packet is the RTP packet.
+// See RFC 3550.
+type Packet struct {
+	Marker         bool
+	PayloadType    uint8
+	SequenceNumber uint16
+	Timestamp      uint32
+	SSRC           uint32
+	Payload        []byte
+}
+
+var errShortPacket = errors.New("rtp: packet is too short")
+
+// ParsePacket parses the RTP packet. CSRC identifiers, the header extension and padding are skipped.
+func ParsePacket(b []byte) (*Packet, error) {
+	if len(b) < 12 {
+		return nil, errShortPacket
+	}
+	if b[0]>>6 != 2 {
+		return nil, errors.New("rtp: unsupported version")
+	}
+	p := &Packet{
+		Marker:         b[1]&0x80 != 0,
+		PayloadType:    b[1] & 0x7f,
+		SequenceNumber: uint16(b[2])<<8 | uint16(b[3]),
+		Timestamp:      uint32(b[4])<<24 | uint32(b[5])<<16 | uint32(b[6])<<8 | uint32(b[7]),
+		SSRC:           uint32(b[8])<<24 | uint32(b[9])<<16 | uint32(b[10])<<8 | uint32(b[11]),
+	}
+	n := 12 + 4*int(b[0]&0xf)
+	if b[0]&0x10 != 0 {
+		if len(b) < n+4 {
+			return nil, errShortPacket
+		}
+		n += 4 + 4*(int(b[n+2])<<8|int(b[n+3]))
+	}
+	end := len(b)
+	if b[0]&0x20 != 0 && end > 0 {
+		end -= int(b[end-1])
+	}
+	if n > end {
+		return nil, errShortPacket
+	}
+	p.Payload = b[n:end]
+	return p, nil
+}
+
+// Bytes returns the encoded packet without CSRC identifiers and the header extension.
+func (p *Packet) Bytes() []byte {
+	b := make([]byte, 12, 12+len(p.Payload))
+	b[0], b[1] = 0x80, p.PayloadType&0x7f
+	if p.Marker {
+		b[1] |= 0x80
+	}
+	b[2], b[3] = byte(p.SequenceNumber>>8), byte(p.SequenceNumber)
+	b[4], b[5], b[6], b[7] = byte(p.Timestamp>>24), byte(p.Timestamp>>16), byte(p.Timestamp>>8), byte(p.Timestamp)
+	b[8], b[9], b[10], b[11] = byte(p.SSRC>>24), byte(p.SSRC>>16), byte(p.SSRC>>8), byte(p.SSRC)
+	return append(b, p.Payload...)
+}
@@ -0,0 +1,339 @@
+package rtp
+
+import (
+	"bytes"
+	"github.com/pixelbender/go-matroska/matroska"
+	"github.com/pixelbender/go-matroska/matroska/codec"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+var (
+	testSPS = []byte{0x67, 0x42, 0xc0, 0x1e, 0xf4, 0x0a, 0x0f, 0xc8}
+	testPPS = []byte{0x68, 0xce, 0x3c, 0x80}
+)
+
+func TestParsePacket(t *testing.T) {
+	// CSRC, header extension and padding
+	b := []byte{0xb1, 0xe0, 0x12, 0x34, 0, 0, 0x10, 0, 1, 2, 3, 4, 5, 6, 7, 8, 0xbe, 0xde, 0, 1, 9, 9, 9, 9, 0xaa, 0xbb, 0, 2}
+	p, err := ParsePacket(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.Marker || p.PayloadType != 96 || p.SequenceNumber != 0x1234 || p.Timestamp != 0x1000 || p.SSRC != 0x01020304 || !bytes.Equal(p.Payload, []byte{0xaa, 0xbb}) {
+		t.Errorf("Unexpected packet: %+v", p)
+	}
+	if _, err = ParsePacket(b[:18]); err == nil {
+		t.Error("Expected error for the truncated extension")
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	vp8 := &matroska.TrackEntry{Number: 1, Type: matroska.TrackTypeVideo, CodecID: matroska.CodecVP8, Video: &matroska.VideoTrack{Width: 320, Height: 240}}
+	opus := &matroska.TrackEntry{Number: 2, Type: matroska.TrackTypeAudio, CodecID: matroska.CodecOpus, CodecPrivate: (&codec.OpusConfig{Channels: 2, SampleRate: 48000}).Bytes(), Audio: &matroska.AudioTrack{SamplingFreq: 48000, Channels: 2}}
+	avc := &matroska.TrackEntry{Number: 3, Type: matroska.TrackTypeVideo, CodecID: matroska.CodecAVC}
+	out := &bytes.Buffer{}
+	rec, err := NewRecorder(out, []*Track{{Entry: vp8, PayloadType: 96}, {Entry: opus, PayloadType: 111}, {Entry: avc, PayloadType: 102}}, &matroska.WriterOptions{DocType: "matroska"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	var packets [][]byte
+	frames := make([][]byte, 10)
+	seq := uint16(65530)
+	for i := range frames {
+		frames[i] = make([]byte, 2500)
+		frames[i][0] = 0x11
+		if i%5 == 0 {
+			frames[i][0] = 0x10
+		}
+		frames[i][1] = byte(i)
+		for j := 0; j < 3; j++ {
+			d := []byte{0x80, 0x80, 0x80 | byte(i>>8), byte(i)}
+			if j == 0 {
+				d[0] |= 0x10
+			}
+			end := (j + 1) * 1000
+			if end > 2500 {
+				end = 2500
+			}
+			p := &Packet{PayloadType: 96, SequenceNumber: seq, Timestamp: 0xfffff000 + uint32(i*3000), Marker: j == 2, Payload: append(d, frames[i][j*1000:end]...)}
+			seq++
+			// The second packet of the third frame is lost
+			if i != 2 || j != 1 {
+				packets = append(packets, p.Bytes())
+			}
+		}
+	}
+	// Reorder packets
+	for i := 0; i+1 < len(packets); i += 4 {
+		packets[i], packets[i+1] = packets[i+1], packets[i]
+	}
+	for _, it := range packets {
+		if err = rec.WriteRTP(it, start); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The buffer of packets is reused
+	var buf []byte
+	for i := 0; i < 20; i++ {
+		p := &Packet{PayloadType: 111, SequenceNumber: uint16(i), Timestamp: uint32(i * 960), Payload: []byte{0xfc, byte(i)}}
+		buf = append(buf[:0], p.Bytes()...)
+		if err = rec.WriteRTP(buf, start.Add(100*time.Millisecond)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	idr := append([]byte{0x65}, bytes.Repeat([]byte{1}, 2000)...)
+	stap := []byte{avcSTAPA, 0, byte(len(testSPS))}
+	stap = append(append(stap, testSPS...), 0, byte(len(testPPS)))
+	stap = append(stap, testPPS...)
+	h264 := [][]byte{stap}
+	for i := 1; i < len(idr); i += 1000 {
+		fu := []byte{0x60 | avcFUA, idr[0] & 0x1f}
+		if i == 1 {
+			fu[1] |= 0x80
+		}
+		end := i + 1000
+		if end >= len(idr) {
+			end = len(idr)
+			fu[1] |= 0x40
+		}
+		h264 = append(h264, append(fu, idr[i:end]...))
+	}
+	for i, it := range h264 {
+		p := &Packet{PayloadType: 102, SequenceNumber: uint16(i), Timestamp: 0, Marker: i == len(h264)-1, Payload: it}
+		if err = rec.WriteRTP(p.Bytes(), start); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p := &Packet{PayloadType: 102, SequenceNumber: uint16(len(h264)), Timestamp: 3000, Marker: true, Payload: []byte{0x41, 1, 2, 3}}
+	if err = rec.WriteRTP(p.Bytes(), start); err != nil {
+		t.Fatal(err)
+	}
+	if err = rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+	r, err := matroska.NewReader(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := r.Segment.Track(3); e == nil || len(e.CodecPrivate) == 0 {
+		t.Fatalf("Unexpected H.264 track: %+v", e)
+	}
+	got := make(map[matroska.TrackNumber][]*matroska.Packet)
+	var last time.Duration
+	for {
+		p, err := r.ReadPacket()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Time < last {
+			t.Errorf("Packet of track %d at %v is written after %v", p.Track, p.Time, last)
+		}
+		last = p.Time
+		got[p.Track] = append(got[p.Track], p)
+	}
+	// Frames 2-4 are dropped until the next keyframe
+	video := []int{0, 1, 5, 6, 7, 8, 9}
+	if len(got[1]) != len(video) {
+		t.Fatalf("Unexpected number of VP8 frames: %d", len(got[1]))
+	}
+	for i, it := range got[1] {
+		n := video[i]
+		if it.Time != time.Duration(n*100/3)*time.Millisecond || it.Keyframe != (n%5 == 0) || !bytes.Equal(it.Data, frames[n]) {
+			t.Errorf("Unexpected VP8 frame %d: %v %v %d", n, it.Time, it.Keyframe, len(it.Data))
+		}
+	}
+	if len(got[2]) != 20 {
+		t.Fatalf("Unexpected number of Opus packets: %d", len(got[2]))
+	}
+	for i, it := range got[2] {
+		if it.Time != 100*time.Millisecond+time.Duration(i)*20*time.Millisecond || !bytes.Equal(it.Data, []byte{0xfc, byte(i)}) {
+			t.Errorf("Unexpected Opus packet: %+v", it)
+		}
+	}
+	want := codec.AppendNALUs(nil, 4, testSPS, testPPS, idr)
+	if len(got[3]) != 2 || !got[3][0].Keyframe || !bytes.Equal(got[3][0].Data, want) || got[3][1].Keyframe || got[3][1].Time != 33*time.Millisecond {
+		t.Errorf("Unexpected H.264 frames: %+v", got[3])
+	}
+}
+
+func TestVP9Payload(t *testing.T) {
+	// Picture ID, layer indices and the scalability structure with one spatial layer and one group
+	b := []byte{0xaa, 0x81, 0x02, 0x00, 0x05, 0x18, 0x01, 0x40, 0x00, 0xf0, 0x01, 0x04, 0x01, 0x82, 0x49, 0x83}
+	d := vp9Depacketizer{}
+	if !d.start(b) {
+		t.Error("Expected the start of the frame")
+	}
+	frame, err := d.append(nil, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(frame, []byte{0x82, 0x49, 0x83}) {
+		t.Errorf("Unexpected frame: %x", frame)
+	}
+}
+
+func TestSender(t *testing.T) {
+	vp8 := &matroska.TrackEntry{Number: 1, Type: matroska.TrackTypeVideo, CodecID: matroska.CodecVP8, Video: &matroska.VideoTrack{Width: 320, Height: 240}}
+	opus := &matroska.TrackEntry{Number: 2, Type: matroska.TrackTypeAudio, CodecID: matroska.CodecOpus, CodecPrivate: (&codec.OpusConfig{Channels: 2, SampleRate: 48000}).Bytes(), Audio: &matroska.AudioTrack{SamplingFreq: 48000, Channels: 2}}
+	b := &bytes.Buffer{}
+	w, err := matroska.NewWriter(b, &matroska.Segment{Tracks: []*matroska.Track{{Entries: []*matroska.TrackEntry{vp8, opus}}}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var packets []*matroska.Packet
+	for i := 0; i < 10; i++ {
+		data := make([]byte, 3000)
+		data[0], data[1] = 0x11, byte(i)
+		if i%5 == 0 {
+			data[0] = 0x10
+		}
+		packets = append(packets, &matroska.Packet{Track: 1, Time: time.Duration(i) * 20 * time.Millisecond, Keyframe: i%5 == 0, Data: data})
+		packets = append(packets, &matroska.Packet{Track: 2, Time: time.Duration(i) * 20 * time.Millisecond, Keyframe: true, Data: []byte{0xfc, byte(i)}})
+	}
+	for _, it := range packets {
+		if err = w.WritePacket(it); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	r, err := matroska.NewReader(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracks := []*Track{{Entry: vp8, PayloadType: 96}, {Entry: opus, PayloadType: 111}}
+	s, err := NewSender(tracks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	out := &bytes.Buffer{}
+	rec, err := NewRecorder(out, tracks, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				done <- nil
+				return
+			}
+			if err = rec.WriteRTP(append([]byte(nil), buf[:n]...), time.Now()); err != nil {
+				done <- err
+				return
+			}
+		}
+	}()
+	client, err := net.DialUDP("udp", nil, conn.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	start := time.Now()
+	if err = s.Send(client, r); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d < 180*time.Millisecond {
+		t.Errorf("Packets are not paced: %v", d)
+	}
+	conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if err = <-done; err != nil {
+		t.Fatal(err)
+	}
+	if err = rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if r, err = matroska.NewReader(out); err != nil {
+		t.Fatal(err)
+	}
+	first := make(map[matroska.TrackNumber]time.Duration)
+	n := 0
+	for {
+		p, err := r.ReadPacket()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := first[p.Track]; !ok {
+			first[p.Track] = p.Time
+		}
+		want := packets[2*int(p.Data[1])+int(p.Track)-1]
+		if p.Track != want.Track || p.Time-first[p.Track] != want.Time || p.Keyframe != want.Keyframe || !bytes.Equal(p.Data, want.Data) {
+			t.Errorf("Unexpected packet: %v %v %v", p.Track, p.Time, p.Keyframe)
+		}
+		n++
+	}
+	if n != len(packets) {
+		t.Errorf("Unexpected number of packets: %d", n)
+	}
+}
+
+func TestAVCPayload(t *testing.T) {
+	c, err := codec.AVCConfigFromAnnexB(codec.AppendAnnexB(nil, testSPS, testPPS))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pack, err := newPacketizer(&matroska.TrackEntry{CodecID: matroska.CodecAVC, CodecPrivate: c.Bytes()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	idr := append([]byte{0x65}, bytes.Repeat([]byte{1, 2, 3}, 500)...)
+	list, err := pack.packetize(codec.AppendNALUs(nil, 4, idr), true, 600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Parameter sets and 3 fragments of the IDR
+	if len(list) != 5 {
+		t.Fatalf("Unexpected number of payloads: %d", len(list))
+	}
+	d := &avcDepacketizer{}
+	var frame []byte
+	for _, it := range list {
+		if len(it) > 600 {
+			t.Errorf("Unexpected payload size: %d", len(it))
+		}
+		if frame, err = d.append(frame, it); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !bytes.Equal(frame, codec.AppendNALUs(nil, 4, testSPS, testPPS, idr)) || !d.keyframe(frame) {
+		t.Errorf("Unexpected frame: %x", frame)
+	}
+}
+
+func TestSenderMTU(t *testing.T) {
+	vp8 := &matroska.TrackEntry{Number: 1, Type: matroska.TrackTypeVideo, CodecID: matroska.CodecVP8}
+	s, err := NewSender([]*Track{{Entry: vp8, PayloadType: 96}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &matroska.Packet{Track: 1, Keyframe: true, Data: []byte{0x10, 1, 2, 3}}
+	// RTP and VP8 payload headers take 16 bytes
+	for _, mtu := range []int{8, 15, 16} {
+		s.MTU = mtu
+		if _, err = s.Packetize(p); err == nil {
+			t.Errorf("Expected error for MTU %d", mtu)
+		}
+	}
+	s.MTU = 17
+	if list, err := s.Packetize(p); err != nil || len(list) != 4 {
+		t.Errorf("Unexpected packets for MTU 17: %d, %v", len(list), err)
+	}
+}
@@ -0,0 +1,127 @@
+package rtp
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"github.com/pixelbender/go-matroska/matroska"
+	"io"
+	"time"
+)
+
+// Sender packetizes Matroska packets into RTP packets of tracks.
+// Sequence numbers, timestamps and SSRC identifiers of tracks start at random values.
+type Sender struct {
+	// MTU is the maximum size of RTP packets, 1200 if zero.
+	MTU int
+
+	tracks map[matroska.TrackNumber]*sendTrack
+}
+
+type sendTrack struct {
+	*Track
+	pack packetizer
+	seq  uint16
+	ts   uint32
+	ssrc uint32
+}
+
+// NewSender returns a new sender of tracks.
+func NewSender(tracks []*Track) (*Sender, error) {
+	s := &Sender{tracks: make(map[matroska.TrackNumber]*sendTrack)}
+	for _, it := range tracks {
+		pack, err := newPacketizer(it.Entry)
+		if err != nil {
+			return nil, err
+		}
+		b := make([]byte, 10)
+		if _, err = rand.Read(b); err != nil {
+			return nil, err
+		}
+		t := &sendTrack{
+			Track: it,
+			pack:  pack,
+			seq:   binary.BigEndian.Uint16(b),
+			ts:    binary.BigEndian.Uint32(b[2:]),
+			ssrc:  binary.BigEndian.Uint32(b[6:]),
+		}
+		if t.ClockRate == 0 {
+			t.ClockRate = 90000
+			if it.Entry.Type == matroska.TrackTypeAudio {
+				t.ClockRate = 48000
+			}
+		}
+		s.tracks[it.Entry.Number] = t
+	}
+	return s, nil
+}
+
+// Packetize returns encoded RTP packets of the Matroska packet.
+// The RTP timestamp is the packet time in the clock rate of the track.
+// Returns nil if the track is not sent.
+func (s *Sender) Packetize(p *matroska.Packet) ([][]byte, error) {
+	t := s.tracks[p.Track]
+	if t == nil {
+		return nil, nil
+	}
+	mtu := s.MTU
+	if mtu <= 0 {
+		mtu = 1200
+	}
+	payloads, err := t.pack.packetize(p.Data, p.Keyframe, mtu-12)
+	if err != nil {
+		return nil, err
+	}
+	ts := t.ts + uint32(int64(p.Time/time.Microsecond)*int64(t.ClockRate)/1e6)
+	list := make([][]byte, len(payloads))
+	for i, it := range payloads {
+		rp := &Packet{
+			Marker:         i == len(payloads)-1 && t.Entry.Type == matroska.TrackTypeVideo,
+			PayloadType:    t.PayloadType,
+			SequenceNumber: t.seq,
+			Timestamp:      ts,
+			SSRC:           t.ssrc,
+			Payload:        it,
+		}
+		t.seq++
+		list[i] = rp.Bytes()
+	}
+	return list, nil
+}
+
+// PacketReader is a source of packets, like matroska.Reader.
+type PacketReader interface {
+	ReadPacket() (*matroska.Packet, error)
+}
+
+// Send reads packets from r and writes RTP packets to w, one per call, paced in real time
+// from the first packet. Returns at the end of the stream.
+func (s *Sender) Send(w io.Writer, r PacketReader) error {
+	var start time.Time
+	var first time.Duration
+	for {
+		p, err := r.ReadPacket()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		list, err := s.Packetize(p)
+		if err != nil {
+			return err
+		}
+		if len(list) == 0 {
+			continue
+		}
+		if start.IsZero() {
+			start, first = time.Now(), p.Time
+		} else if d := start.Add(p.Time - first).Sub(time.Now()); d > 0 {
+			time.Sleep(d)
+		}
+		for _, it := range list {
+			if _, err = w.Write(it); err != nil {
+				return err
+			}
+		}
+	}
+}
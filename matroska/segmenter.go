@@ -0,0 +1,111 @@
+package matroska
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"time"
+)
+
+// MediaSegment is a Cluster of the WebM byte stream for Media Source Extensions.
+type MediaSegment struct {
+	Start time.Duration // Earliest presentation time of packets
+	End   time.Duration // Latest end time of packets
+	Data  []byte
+}
+
+// Segmenter splits the Matroska stream into the initialization segment and media segments
+// of the WebM byte stream format. Each media segment is a Cluster starting with a keyframe.
+type Segmenter struct {
+	// Init contains the EBML header, the Segment header with unknown size, Info and Tracks.
+	Init []byte
+
+	r     *Reader
+	w     *Writer
+	buf   bytes.Buffer
+	min   time.Duration
+	video bool
+	next  *Packet
+	seg   *MediaSegment
+}
+
+// NewSegmenter reads the stream header from r and returns a new segmenter.
+// Media segments are at least d long unless the stream ends.
+func NewSegmenter(r *Reader, d time.Duration) (*Segmenter, error) {
+	s := &Segmenter{r: r, min: d}
+	seg := &Segment{Tracks: r.Segment.Tracks}
+	for _, it := range r.Segment.Info {
+		c := *it
+		c.Duration = 0
+		seg.Info = append(seg.Info, &c)
+	}
+	for _, t := range seg.Tracks {
+		for _, it := range t.Entries {
+			s.video = s.video || it.Type == TrackTypeVideo
+		}
+	}
+	opt := &WriterOptions{DocType: r.EBML.DocType, ClusterDuration: math.MaxInt64, ClusterSize: math.MaxInt32}
+	w, err := NewWriter(&s.buf, seg, opt)
+	if err != nil {
+		return nil, err
+	}
+	s.w = w
+	s.Init = s.take()
+	return s, nil
+}
+
+// Next returns the next media segment. Returns io.EOF at the end of the stream.
+// Packets preceding the first keyframe are skipped.
+func (s *Segmenter) Next() (*MediaSegment, error) {
+	for {
+		p := s.next
+		s.next = nil
+		if p == nil {
+			var err error
+			if p, err = s.r.ReadPacket(); err != nil {
+				if err == io.EOF && s.seg != nil {
+					return s.flush()
+				}
+				return nil, err
+			}
+		}
+		t := s.w.Segment.Track(p.Track)
+		if t == nil {
+			continue
+		}
+		key := p.Keyframe && (!s.video || t.Type == TrackTypeVideo)
+		if s.seg == nil {
+			if !key {
+				continue
+			}
+			s.seg = &MediaSegment{Start: p.Time, End: p.Time}
+		} else if key && p.Time-s.seg.Start >= s.min {
+			s.next = p
+			return s.flush()
+		}
+		if err := s.w.WritePacket(p); err != nil {
+			return nil, err
+		}
+		if p.Time < s.seg.Start {
+			s.seg.Start = p.Time
+		}
+		if end := p.Time + p.Duration; end > s.seg.End {
+			s.seg.End = end
+		}
+	}
+}
+
+func (s *Segmenter) flush() (*MediaSegment, error) {
+	if err := s.w.Flush(); err != nil {
+		return nil, err
+	}
+	seg := s.seg
+	s.seg, seg.Data = nil, s.take()
+	return seg, nil
+}
+
+func (s *Segmenter) take() []byte {
+	b := append([]byte(nil), s.buf.Bytes()...)
+	s.buf.Reset()
+	return b
+}
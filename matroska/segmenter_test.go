@@ -0,0 +1,82 @@
+package matroska
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestSegmenter(t *testing.T) {
+	seg := &Segment{Tracks: []*Track{{Entries: []*TrackEntry{
+		{Number: 1, Type: TrackTypeVideo, CodecID: CodecVP8, Enabled: true, Default: true, DefaultDuration: 40 * time.Millisecond, Video: &VideoTrack{Width: 320, Height: 240}},
+		{Number: 2, Type: TrackTypeAudio, CodecID: CodecOpus, Enabled: true, Default: true, DefaultDuration: 20 * time.Millisecond, Audio: &AudioTrack{SamplingFreq: 48000, Channels: 2}},
+	}}}}
+	b := &bytes.Buffer{}
+	w, err := NewWriter(b, seg, &WriterOptions{DocType: "webm", ClusterDuration: 500 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 150; i++ {
+		// Starts with non-keyframes to be skipped
+		if i%3 == 1 {
+			err = w.WritePacket(&Packet{Track: 1, Time: time.Duration(i) * 20 * time.Millisecond, Duration: 40 * time.Millisecond, Keyframe: i%30 == 16, Data: []byte{byte(i)}})
+		}
+		if err == nil {
+			err = w.WritePacket(&Packet{Track: 2, Time: time.Duration(i) * 20 * time.Millisecond, Duration: 20 * time.Millisecond, Keyframe: true, Data: []byte{byte(i)}})
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewReader(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewSegmenter(r, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := append([]byte(nil), s.Init...)
+	var list []*MediaSegment
+	for {
+		m, err := s.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.HasPrefix(m.Data, []byte{0x1f, 0x43, 0xb6, 0x75}) {
+			t.Errorf("Media segment is not a cluster: %x", m.Data[:4])
+		}
+		list = append(list, m)
+		out = append(out, m.Data...)
+	}
+	if len(list) != 3 || list[0].Start != 320*time.Millisecond || list[1].Start != 1520*time.Millisecond || list[2].Start != 2720*time.Millisecond || list[2].End != 3*time.Second {
+		for _, it := range list {
+			t.Logf("Segment %v - %v", it.Start, it.End)
+		}
+		t.Fatalf("Unexpected segments: %d", len(list))
+	}
+	r, err = NewReader(bytes.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.EBML.DocType != "webm" || len(r.Segment.Tracks[0].Entries) != 2 {
+		t.Errorf("Unexpected header: %s", r.EBML.DocType)
+	}
+	n := 0
+	for {
+		if _, err = r.ReadPacket(); err != nil {
+			break
+		}
+		n++
+	}
+	if err != io.EOF || n != 45+134 {
+		t.Errorf("Unexpected packets: %d, %v", n, err)
+	}
+}
@@ -1,10 +1,210 @@
 package matroska
 
-import "io"
+import (
+	"errors"
+	"github.com/pixelbender/go-matroska/ebml"
+	"io"
+	"time"
+)
 
+// Packet is a single frame of the track with a presentation timestamp.
+type Packet struct {
+	Track          TrackNumber
+	Time           time.Duration
+	Duration       time.Duration // Zero if not known
+	Keyframe       bool
+	Invisible      bool
+	Discardable    bool
+	DiscardPadding time.Duration    `json:",omitempty"`
+	Additions      []*BlockAddition `json:",omitempty"`
+	Data           []byte           `json:"-"`
+}
+
+// Reader reads packets from the Matroska stream cluster by cluster.
 type Reader struct {
+	EBML *EBML
+	// Segment contains Top-Level Elements read so far, except Clusters.
+	Segment *Segment
+	// Filters are applied to packets of tracks before they are returned.
+	Filters map[TrackNumber]PacketFilter
+
+	seg     *ebml.Reader
+	cluster *ebml.Reader
+	time    int64
+	queue   []*Packet
+}
+
+// NewReader returns a new reader that reads from r.
+// It reads the EBML header and Top-Level Elements of the Segment preceding the first Cluster.
+func NewReader(r io.Reader) (*Reader, error) {
+	dec := ebml.NewReader(r, &ebml.DecodeOptions{
+		SkipDamaged: true,
+		Level:       Level,
+	})
+	s := &Reader{EBML: &EBML{}, Segment: &Segment{}}
+	for s.seg == nil {
+		id, elem, err := dec.ReadElement()
+		if err != nil {
+			if err == io.EOF {
+				err = errors.New("matroska: segment not found")
+			}
+			return nil, err
+		}
+		switch ID(id) {
+		case IDEBML:
+			if err = elem.Decode(s.EBML); err != nil {
+				return nil, err
+			}
+		case IDSegment:
+			s.seg = elem
+		}
+	}
+	for s.cluster == nil {
+		if err := s.next(); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// ReadPacket reads the next packet of the stream.
+// Top-Level Elements found between Clusters are decoded into the Segment.
+// Returns io.EOF at the end of the Segment.
+func (r *Reader) ReadPacket() (*Packet, error) {
+	for len(r.queue) == 0 {
+		if err := r.next(); err != nil {
+			return nil, err
+		}
+	}
+	p := r.queue[0]
+	r.queue = r.queue[1:]
+	if f := r.Filters[p.Track]; f != nil {
+		if err := f(p); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// ReadCluster reads the remaining packets of the current Cluster or packets of the next Cluster.
+// Returns io.EOF at the end of the Segment.
+func (r *Reader) ReadCluster() ([]*Packet, error) {
+	for r.cluster == nil && len(r.queue) == 0 {
+		if err := r.next(); err != nil {
+			return nil, err
+		}
+	}
+	for r.cluster != nil {
+		if err := r.next(); err != nil {
+			return nil, err
+		}
+	}
+	list := r.queue
+	r.queue = nil
+	for _, p := range list {
+		if f := r.Filters[p.Track]; f != nil {
+			if err := f(p); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return list, nil
+}
+
+func (r *Reader) next() error {
+	if r.cluster == nil {
+		id, elem, err := r.seg.ReadElement()
+		if err != nil {
+			return err
+		}
+		if ID(id) == IDCluster {
+			r.cluster, r.time = elem, 0
+			return nil
+		}
+		return elem.DecodeElement(id, r.Segment)
+	}
+	id, elem, err := r.cluster.ReadElement()
+	if err != nil {
+		if err == io.EOF {
+			r.cluster = nil
+			return nil
+		}
+		return err
+	}
+	switch id {
+	case 0xE7:
+		r.time, err = elem.ReadInt()
+	case 0xA3:
+		b := &Block{}
+		if err = elem.Decode(b); err == nil {
+			r.queue = r.Segment.appendPackets(r.queue, r.time, b, nil)
+		}
+	case 0xA0:
+		g := &BlockGroup{}
+		if err = elem.Decode(g); err == nil && g.Block != nil {
+			r.queue = r.Segment.appendPackets(r.queue, r.time, g.Block, g)
+		}
+	}
+	return err
+}
+
+// Packets returns packets of the Cluster.
+func (s *Segment) Packets(c *Cluster) []*Packet {
+	var list []*Packet
+	for _, it := range c.SimpleBlock {
+		list = s.appendPackets(list, int64(c.Timecode), it, nil)
+	}
+	for _, it := range c.BlockGroup {
+		if it.Block != nil {
+			list = s.appendPackets(list, int64(c.Timecode), it.Block, it)
+		}
+	}
+	return list
+}
+
+// TimecodeScale returns the timestamp scale of the Segment.
+func (s *Segment) TimecodeScale() time.Duration {
+	for _, it := range s.Info {
+		if it.TimecodeScale > 0 {
+			return it.TimecodeScale
+		}
+	}
+	return time.Millisecond
 }
 
-func NewReader(r io.Reader) *Reader {
-	return &Reader{}
+func (s *Segment) appendPackets(list []*Packet, cluster int64, b *Block, g *BlockGroup) []*Packet {
+	scale := s.TimecodeScale()
+	t := time.Duration(cluster+int64(b.Timecode)) * scale
+	var step time.Duration
+	if e := s.Track(b.TrackNumber); e != nil {
+		step = e.DefaultDuration
+	}
+	for i, it := range b.Frames {
+		p := &Packet{
+			Track:       b.TrackNumber,
+			Time:        t + time.Duration(i)*step,
+			Duration:    step,
+			Keyframe:    b.Keyframe(),
+			Invisible:   b.Flags&BlockFlagInvisible != 0,
+			Discardable: b.Flags&BlockFlagDiscardable != 0,
+			Data:        it,
+		}
+		if g != nil {
+			p.Keyframe = len(g.ReferenceBlock) == 0
+			if len(b.Frames) == 1 {
+				if g.Duration > 0 {
+					p.Duration = time.Duration(g.Duration) * scale
+				}
+				p.Additions = g.Additions
+			}
+			if i == len(b.Frames)-1 {
+				p.DiscardPadding = g.DiscardPadding
+			}
+		}
+		list = append(list, p)
+	}
+	return list
 }
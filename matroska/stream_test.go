@@ -0,0 +1,111 @@
+package matroska
+
+import (
+	"bytes"
+	"github.com/pixelbender/go-matroska/ebml"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestUnknownSize(t *testing.T) {
+	seg := &Segment{Tracks: []*Track{{Entries: []*TrackEntry{
+		{Number: 1, Type: TrackTypeVideo, CodecID: CodecVP8, Enabled: true, Default: true, Video: &VideoTrack{Width: 320, Height: 240}},
+	}}}}
+	b := &bytes.Buffer{}
+	if _, err := NewWriter(b, seg, &WriterOptions{DocType: "webm"}); err != nil {
+		t.Fatal(err)
+	}
+	// Clusters of unknown size written by live encoders
+	enc := ebml.NewWriter(b)
+	for i := 0; i < 3; i++ {
+		if err := enc.WriteElementHeader(uint32(IDCluster), -1); err != nil {
+			t.Fatal(err)
+		}
+		if err := enc.EncodeElement(0xE7, int64(i*1000)); err != nil {
+			t.Fatal(err)
+		}
+		for j := 0; j < 2; j++ {
+			if err := enc.EncodeElement(0xA3, &Block{TrackNumber: 1, Timecode: int16(j * 40), Flags: BlockFlagKeyframe, Frames: [][]byte{{byte(i), byte(j)}}}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	cues, err := ebml.Marshal(&Segment{Cues: []*CuePoint{{TrackPositions: []*CueTrackPosition{{Track: 1}}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Write(cues)
+	r, err := NewReader(bytes.NewReader(b.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 6; i++ {
+		p, err := r.ReadPacket()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Time != time.Duration(i/2*1000+i%2*40)*time.Millisecond || !bytes.Equal(p.Data, []byte{byte(i / 2), byte(i % 2)}) {
+			t.Errorf("Unexpected packet: %+v", p)
+		}
+	}
+	if _, err = r.ReadPacket(); err != io.EOF {
+		t.Errorf("Expected EOF, got %v", err)
+	}
+	if len(r.Segment.Cues) != 1 {
+		t.Errorf("Unexpected cues: %s", dump(r.Segment.Cues))
+	}
+	f, err := ioutil.TempFile("", "matroska")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	if _, err = f.Write(b.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	doc, err := Decode(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := doc.Segment
+	if len(s.Cluster) != 3 || len(s.Cues) != 1 {
+		t.Fatalf("Unexpected clusters: %d, cues: %d", len(s.Cluster), len(s.Cues))
+	}
+	for i, it := range s.Cluster {
+		if it.Timecode != Time(i*1000) || len(it.SimpleBlock) != 2 {
+			t.Errorf("Unexpected cluster: %s", dump(it))
+		}
+	}
+}
+
+func TestLargeBlock(t *testing.T) {
+	seg := &Segment{Tracks: []*Track{{Entries: []*TrackEntry{
+		{Number: 1, Type: TrackTypeVideo, CodecID: CodecVP8, Enabled: true, Default: true, Video: &VideoTrack{Width: 320, Height: 240}},
+	}}}}
+	b := &bytes.Buffer{}
+	if _, err := NewWriter(b, seg, &WriterOptions{DocType: "webm"}); err != nil {
+		t.Fatal(err)
+	}
+	// SimpleBlock of 1 TiB in the Cluster of unknown size
+	enc := ebml.NewWriter(b)
+	if err := enc.WriteElementHeader(uint32(IDCluster), -1); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.EncodeElement(0xE7, int64(0)); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteElementHeader(0xA3, 1<<40); err != nil {
+		t.Fatal(err)
+	}
+	b.Write([]byte{0x81, 0, 0, 0x80, 1, 2, 3})
+	r, err := NewReader(bytes.NewReader(b.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = r.ReadPacket(); err == nil || err == io.EOF {
+		t.Errorf("Expected error for the large block, got %v", err)
+	}
+}
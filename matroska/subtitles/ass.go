@@ -0,0 +1,158 @@
+package subtitles
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	assFormat = "Format: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text"
+	ssaFormat = "Format: Marked, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text"
+)
+
+// DefaultASSHeader is the ASS script header used for plain text subtitles.
+const DefaultASSHeader = `[Script Info]
+ScriptType: v4.00+
+WrapStyle: 0
+ScaledBorderAndShadow: yes
+
+[V4+ Styles]
+Format: Name, Fontname, Fontsize, PrimaryColour, SecondaryColour, OutlineColour, BackColour, Bold, Italic, Underline, StrikeOut, ScaleX, ScaleY, Spacing, Angle, BorderStyle, Outline, Shadow, Alignment, MarginL, MarginR, MarginV, Encoding
+Style: Default,Arial,20,&H00FFFFFF,&H000000FF,&H00000000,&H00000000,0,0,0,0,100,100,0,0,1,2,2,2,10,10,10,1
+`
+
+// ReadASS reads the ASS or SSA subtitle file.
+// Sections following the [Events] section are not supported and skipped.
+func ReadASS(r io.Reader) (*Track, error) {
+	lines, err := readLines(r)
+	if err != nil {
+		return nil, err
+	}
+	t := &Track{Format: ASS}
+	format := strings.Split(strings.TrimPrefix(assFormat, "Format: "), ", ")
+	var header []string
+	section := ""
+	for _, line := range lines {
+		s := strings.TrimSpace(line)
+		if strings.HasPrefix(s, "[") {
+			if section == "[events]" {
+				break
+			}
+			section = strings.ToLower(s)
+		}
+		if section == "[events]" {
+			key, value := s, ""
+			if i := strings.IndexByte(s, ':'); i >= 0 {
+				key, value = s[:i], strings.TrimSpace(s[i+1:])
+			}
+			switch key {
+			case "Format":
+				format = strings.Split(value, ",")
+				for i, it := range format {
+					format[i] = strings.TrimSpace(it)
+				}
+			case "Dialogue":
+				e, err := parseASSEvent(format, value)
+				if err != nil {
+					return nil, err
+				}
+				e.ReadOrder = len(t.Events)
+				t.Events = append(t.Events, e)
+				continue
+			case "Comment":
+				continue
+			}
+		}
+		header = append(header, line)
+	}
+	t.Header = strings.TrimRight(strings.Join(header, "\n"), "\n") + "\n"
+	return t, nil
+}
+
+func parseASSEvent(format []string, s string) (*Event, error) {
+	v := strings.SplitN(s, ",", len(format))
+	if len(v) != len(format) {
+		return nil, errors.New("subtitles: invalid ASS event: " + s)
+	}
+	e := &Event{}
+	var err error
+	for i, it := range format {
+		switch strings.ToLower(it) {
+		case "layer", "marked":
+			e.Layer = v[i]
+		case "start":
+			e.Start, err = parseTime(v[i])
+		case "end":
+			e.End, err = parseTime(v[i])
+		case "style":
+			e.Style = v[i]
+		case "name":
+			e.Name = v[i]
+		case "marginl":
+			e.MarginL = v[i]
+		case "marginr":
+			e.MarginR = v[i]
+		case "marginv":
+			e.MarginV = v[i]
+		case "effect":
+			e.Effect = v[i]
+		case "text":
+			e.Text = v[i]
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+func (w *Writer) writeASS() error {
+	header := DefaultASSHeader
+	if w.ass {
+		header = strings.Replace(string(w.track.CodecPrivate), "\r\n", "\n", -1)
+	}
+	header = strings.TrimRight(header, "\x00\n") + "\n"
+	if !strings.Contains(header, "[Events]") {
+		header += "\n[Events]\n"
+	}
+	events := header[strings.Index(header, "[Events]"):]
+	if !strings.Contains(events, "Format:") {
+		if codec(w.track) == CodecSSA {
+			header += ssaFormat + "\n"
+		} else {
+			header += assFormat + "\n"
+		}
+	}
+	list := append([]*Event(nil), w.events...)
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].ReadOrder < list[j].ReadOrder
+	})
+	b := bufio.NewWriter(w.w)
+	b.WriteString(header)
+	for _, it := range list {
+		layer, style := it.Layer, it.Style
+		if !w.ass {
+			layer, style = "0", "Default"
+		}
+		fmt.Fprintf(b, "Dialogue: %s,%s,%s,%s,%s,%s,%s,%s,%s,%s\n", layer, formatASSTime(it.Start), formatASSTime(it.End),
+			style, it.Name, zero(it.MarginL), zero(it.MarginR), zero(it.MarginV), it.Effect, w.assText(it))
+	}
+	return b.Flush()
+}
+
+func formatASSTime(t time.Duration) string {
+	h, m, s, ms := splitTime(t)
+	return fmt.Sprintf("%d:%02d:%02d.%02d", h, m, s, ms/10)
+}
+
+func zero(s string) string {
+	if s == "" {
+		return "0"
+	}
+	return s
+}
@@ -0,0 +1,153 @@
+package subtitles
+
+import (
+	"errors"
+	"fmt"
+	"github.com/pixelbender/go-matroska/matroska"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Track is a subtitle track read from a subtitle file.
+type Track struct {
+	Format   Format
+	Header   string // ASS script header or WebVTT file header
+	Language string // ISO-639-2 language code
+	Name     string
+	Events   []*Event
+}
+
+// TrackEntry returns the Matroska track entry of the subtitle track with the given number.
+func (t *Track) TrackEntry(number matroska.TrackNumber) *matroska.TrackEntry {
+	e := &matroska.TrackEntry{
+		Number:         number,
+		Type:           matroska.TrackTypeSubtitle,
+		Name:           t.Name,
+		Language:       t.Language,
+		Enabled:        true,
+		Default:        true,
+		CodecDecodeAll: true,
+	}
+	switch t.Format {
+	case ASS:
+		e.CodecID = CodecASS
+		if strings.Contains(strings.ToLower(t.Header), "[v4 styles]") {
+			e.CodecID = CodecSSA
+		}
+		e.CodecPrivate = []byte(t.Header)
+	case WebVTT:
+		e.CodecID = CodecWebVTT
+		e.MaxBlockAdditionID = 1
+		if h := strings.TrimSpace(t.Header); h != "" && h != "WEBVTT" {
+			e.CodecPrivate = []byte(h)
+		}
+	default:
+		e.CodecID = CodecUTF8
+	}
+	return e
+}
+
+// Packets returns packets of the subtitle track with the given number ordered by time.
+func (t *Track) Packets(number matroska.TrackNumber) []*matroska.Packet {
+	list := make([]*matroska.Packet, 0, len(t.Events))
+	for _, it := range t.Events {
+		p := &matroska.Packet{
+			Track:    number,
+			Time:     it.Start,
+			Keyframe: true,
+			Data:     []byte(it.Text),
+		}
+		if it.End > it.Start {
+			p.Duration = it.End - it.Start
+		}
+		switch t.Format {
+		case ASS:
+			p.Data = []byte(fmt.Sprintf("%d,%s,%s,%s,%s,%s,%s,%s,%s", it.ReadOrder, zero(it.Layer), it.Style, it.Name,
+				zero(it.MarginL), zero(it.MarginR), zero(it.MarginV), it.Effect, it.Text))
+		case WebVTT:
+			if it.Settings != "" || it.ID != "" {
+				p.Additions = []*matroska.BlockAddition{{ID: 1, Data: []byte(it.Settings + "\n" + it.ID)}}
+			}
+		}
+		list = append(list, p)
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].Time < list[j].Time
+	})
+	return list
+}
+
+// Mux copies the Matroska stream from r to w adding the subtitle tracks.
+// New tracks are numbered after the existing ones. WebM supports WebVTT tracks only.
+func Mux(w io.Writer, r *matroska.Reader, tracks []*Track, opt *matroska.WriterOptions) error {
+	s := r.Segment
+	seg := &matroska.Segment{
+		Info:        s.Info,
+		Chapters:    s.Chapters,
+		Attachments: s.Attachments,
+	}
+	entries := &matroska.Track{}
+	var n matroska.TrackNumber
+	for _, t := range s.Tracks {
+		for _, it := range t.Entries {
+			if it.Number > n {
+				n = it.Number
+			}
+			entries.Entries = append(entries.Entries, it)
+		}
+	}
+	var queue []*matroska.Packet
+	for _, it := range tracks {
+		n++
+		entries.Entries = append(entries.Entries, it.TrackEntry(n))
+		queue = append(queue, it.Packets(n)...)
+	}
+	sort.SliceStable(queue, func(i, j int) bool {
+		return queue[i].Time < queue[j].Time
+	})
+	seg.Tracks = []*matroska.Track{entries}
+	o := &matroska.WriterOptions{}
+	if opt != nil {
+		*o = *opt
+	}
+	if o.DocType == "" && r.EBML != nil {
+		o.DocType = r.EBML.DocType
+	}
+	if o.DocType == "webm" {
+		for _, it := range tracks {
+			if it.Format != WebVTT {
+				return errors.New("subtitles: only WebVTT tracks can be muxed into webm")
+			}
+		}
+	}
+	enc, err := matroska.NewWriter(w, seg, o)
+	if err != nil {
+		return err
+	}
+	for {
+		p, err := r.ReadPacket()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return err
+		}
+		for len(queue) > 0 && queue[0].Time <= p.Time {
+			if err = enc.WritePacket(queue[0]); err != nil {
+				return err
+			}
+			queue = queue[1:]
+		}
+		if err = enc.WritePacket(p); err != nil {
+			return err
+		}
+	}
+	for _, it := range queue {
+		if err = enc.WritePacket(it); err != nil {
+			return err
+		}
+	}
+	seg.Tags = s.Tags
+	return enc.Close()
+}
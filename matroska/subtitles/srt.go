@@ -0,0 +1,52 @@
+package subtitles
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// ReadSRT reads the SubRip subtitle file.
+func ReadSRT(r io.Reader) (*Track, error) {
+	lines, err := readLines(r)
+	if err != nil {
+		return nil, err
+	}
+	t := &Track{Format: SRT}
+	for i := 0; i < len(lines); i++ {
+		if !strings.Contains(lines[i], "-->") {
+			continue
+		}
+		start, end, _, err := parseTiming(lines[i])
+		if err != nil {
+			return nil, err
+		}
+		j := i + 1
+		for j < len(lines) && strings.TrimSpace(lines[j]) != "" {
+			j++
+		}
+		t.Events = append(t.Events, &Event{
+			Start:     start,
+			End:       end,
+			ReadOrder: len(t.Events),
+			Text:      strings.Join(lines[i+1:j], "\n"),
+		})
+		i = j
+	}
+	return t, nil
+}
+
+func (w *Writer) writeSRT() error {
+	b := bufio.NewWriter(w.w)
+	for i, it := range w.events {
+		fmt.Fprintf(b, "%d\n%s --> %s\n%s\n\n", i+1, formatSRTTime(it.Start), formatSRTTime(it.End), strings.TrimRight(w.plainText(it), "\n"))
+	}
+	return b.Flush()
+}
+
+func formatSRTTime(t time.Duration) string {
+	h, m, s, ms := splitTime(t)
+	return fmt.Sprintf("%02d:%02d:%02d,%03d", h, m, s, ms)
+}
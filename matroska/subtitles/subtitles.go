@@ -0,0 +1,289 @@
+// Package subtitles converts text subtitle tracks of Matroska files to SRT, ASS/SSA and WebVTT files
+// and muxes subtitle files into Matroska files.
+package subtitles
+
+import (
+	"bufio"
+	"errors"
+	"github.com/pixelbender/go-matroska/matroska"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Format is a subtitle file format.
+type Format int
+
+// Formats
+const (
+	SRT Format = iota
+	ASS
+	WebVTT
+)
+
+// Subtitle codec IDs
+const (
+	CodecUTF8   = "S_TEXT/UTF8"
+	CodecASCII  = "S_TEXT/ASCII"
+	CodecASS    = "S_TEXT/ASS"
+	CodecSSA    = "S_TEXT/SSA"
+	CodecWebVTT = "S_TEXT/WEBVTT"
+)
+
+// Event is a single subtitle event.
+type Event struct {
+	Start     time.Duration
+	End       time.Duration
+	ReadOrder int
+	Layer     string // Layer of ASS or Marked of SSA events
+	Style     string
+	Name      string
+	MarginL   string
+	MarginR   string
+	MarginV   string
+	Effect    string
+	Text      string // Text in the format of the track codec
+	ID        string // WebVTT cue identifier
+	Settings  string // WebVTT cue settings
+}
+
+// Writer writes packets of the subtitle track as a subtitle file.
+type Writer struct {
+	w      io.Writer
+	format Format
+	track  *matroska.TrackEntry
+	ass    bool
+	events []*Event
+}
+
+// NewWriter returns a new writer of the subtitle track to w in the given format.
+func NewWriter(w io.Writer, track *matroska.TrackEntry, format Format) (*Writer, error) {
+	switch codec(track) {
+	case CodecUTF8, CodecASCII, CodecWebVTT:
+		return &Writer{w: w, format: format, track: track}, nil
+	case CodecASS, CodecSSA:
+		return &Writer{w: w, format: format, track: track, ass: true}, nil
+	default:
+		return nil, errors.New("subtitles: unsupported codec " + track.CodecID)
+	}
+}
+
+// WritePacket adds the packet of the subtitle track.
+func (w *Writer) WritePacket(p *matroska.Packet) error {
+	e := &Event{
+		Start:     p.Time,
+		End:       p.Time + p.Duration,
+		ReadOrder: len(w.events),
+		Text:      strings.TrimRight(string(p.Data), "\x00"),
+	}
+	if p.Duration == 0 {
+		e.End = -1
+	}
+	if w.ass {
+		v := strings.SplitN(e.Text, ",", 9)
+		if len(v) != 9 {
+			return errors.New("subtitles: invalid ASS event: " + e.Text)
+		}
+		if n, err := strconv.Atoi(strings.TrimSpace(v[0])); err == nil {
+			e.ReadOrder = n
+		}
+		e.Layer, e.Style, e.Name = v[1], v[2], v[3]
+		e.MarginL, e.MarginR, e.MarginV = v[4], v[5], v[6]
+		e.Effect, e.Text = v[7], v[8]
+	} else if codec(w.track) == CodecWebVTT {
+		for _, it := range p.Additions {
+			v := strings.SplitN(string(it.Data), "\n", 3)
+			e.Settings = v[0]
+			if len(v) > 1 {
+				e.ID = v[1]
+			}
+		}
+	}
+	w.events = append(w.events, e)
+	return nil
+}
+
+// Close writes the subtitle file.
+func (w *Writer) Close() error {
+	sort.SliceStable(w.events, func(i, j int) bool {
+		a, b := w.events[i], w.events[j]
+		if a.Start != b.Start {
+			return a.Start < b.Start
+		}
+		return a.ReadOrder < b.ReadOrder
+	})
+	for i, it := range w.events {
+		if it.End >= 0 {
+			continue
+		}
+		if i+1 < len(w.events) {
+			it.End = w.events[i+1].Start
+		} else {
+			it.End = it.Start
+		}
+	}
+	switch w.format {
+	case SRT:
+		return w.writeSRT()
+	case ASS:
+		return w.writeASS()
+	case WebVTT:
+		return w.writeWebVTT()
+	default:
+		return errors.New("subtitles: unsupported format")
+	}
+}
+
+// Extract reads packets of the track from r and writes them as a subtitle file in the given format.
+func Extract(w io.Writer, r *matroska.Reader, track matroska.TrackNumber, format Format) error {
+	t := r.Segment.Track(track)
+	if t == nil {
+		return errors.New("subtitles: track not found")
+	}
+	enc, err := NewWriter(w, t, format)
+	if err != nil {
+		return err
+	}
+	for {
+		p, err := r.ReadPacket()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return err
+		}
+		if p.Track != track {
+			continue
+		}
+		if err = enc.WritePacket(p); err != nil {
+			return err
+		}
+	}
+	return enc.Close()
+}
+
+// plainText returns the event text without ASS override tags.
+func (w *Writer) plainText(e *Event) string {
+	if !w.ass {
+		return e.Text
+	}
+	var b strings.Builder
+	s := e.Text
+	for len(s) > 0 {
+		switch {
+		case s[0] == '{':
+			if i := strings.IndexByte(s, '}'); i >= 0 {
+				s = s[i+1:]
+				continue
+			}
+		case strings.HasPrefix(s, `\N`), strings.HasPrefix(s, `\n`):
+			b.WriteByte('\n')
+			s = s[2:]
+			continue
+		case strings.HasPrefix(s, `\h`):
+			b.WriteByte(' ')
+			s = s[2:]
+			continue
+		}
+		b.WriteByte(s[0])
+		s = s[1:]
+	}
+	return b.String()
+}
+
+// assText returns the event text in the ASS format.
+func (w *Writer) assText(e *Event) string {
+	if w.ass {
+		return e.Text
+	}
+	s := strings.Replace(e.Text, "\r\n", "\n", -1)
+	return strings.Replace(s, "\n", `\N`, -1)
+}
+
+func codec(t *matroska.TrackEntry) string {
+	switch t.CodecID {
+	case "S_ASS":
+		return CodecASS
+	case "S_SSA":
+		return CodecSSA
+	default:
+		return t.CodecID
+	}
+}
+
+func splitTime(t time.Duration) (h, m, s, ms int64) {
+	if t < 0 {
+		t = 0
+	}
+	ms = int64(t / time.Millisecond)
+	return ms / 3600000, ms / 60000 % 60, ms / 1000 % 60, ms % 1000
+}
+
+// parseTime parses timestamps of subtitle files like "01:02:03,456", "1:02:03.45" or "02:03.456".
+func parseTime(s string) (time.Duration, error) {
+	s = strings.Replace(strings.TrimSpace(s), ",", ".", 1)
+	v := strings.Split(s, ":")
+	if len(v) < 2 || len(v) > 3 {
+		return 0, errors.New("subtitles: invalid timestamp " + s)
+	}
+	var t time.Duration
+	for _, it := range v[:len(v)-1] {
+		n, err := strconv.ParseUint(it, 10, 32)
+		if err != nil {
+			return 0, errors.New("subtitles: invalid timestamp " + s)
+		}
+		t = t*60 + time.Duration(n)
+	}
+	sec, frac := v[len(v)-1], ""
+	if i := strings.IndexByte(sec, '.'); i >= 0 {
+		sec, frac = sec[:i], sec[i+1:]
+	}
+	n, err := strconv.ParseUint(sec, 10, 32)
+	if err != nil {
+		return 0, errors.New("subtitles: invalid timestamp " + s)
+	}
+	t = (t*60 + time.Duration(n)) * time.Second
+	for i, unit := 0, 100*time.Millisecond; i < len(frac) && unit > 0; i, unit = i+1, unit/10 {
+		if frac[i] < '0' || frac[i] > '9' {
+			return 0, errors.New("subtitles: invalid timestamp " + s)
+		}
+		t += time.Duration(frac[i]-'0') * unit
+	}
+	return t, nil
+}
+
+// readLines reads lines of the text file without line endings and the byte order mark.
+func readLines(r io.Reader) ([]string, error) {
+	var lines []string
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1<<20)
+	for s.Scan() {
+		line := strings.TrimRight(s.Text(), "\r")
+		if len(lines) == 0 {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+		lines = append(lines, line)
+	}
+	return lines, s.Err()
+}
+
+// parseTiming parses the "start --> end settings" line of SRT and WebVTT cues.
+func parseTiming(line string) (start, end time.Duration, settings string, err error) {
+	i := strings.Index(line, "-->")
+	if i < 0 {
+		return 0, 0, "", errors.New("subtitles: invalid cue timing " + line)
+	}
+	v := strings.Fields(line[i+3:])
+	if len(v) == 0 {
+		return 0, 0, "", errors.New("subtitles: invalid cue timing " + line)
+	}
+	if start, err = parseTime(line[:i]); err != nil {
+		return
+	}
+	if end, err = parseTime(v[0]); err != nil {
+		return
+	}
+	return start, end, strings.Join(v[1:], " "), nil
+}
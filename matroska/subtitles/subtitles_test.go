@@ -0,0 +1,127 @@
+package subtitles
+
+import (
+	"bytes"
+	"github.com/pixelbender/go-matroska/ebml"
+	"github.com/pixelbender/go-matroska/matroska"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testASSHeader = `[Script Info]
+ScriptType: v4.00+
+
+[V4+ Styles]
+Format: Name, Fontname, Fontsize, PrimaryColour, SecondaryColour, OutlineColour, BackColour, Bold, Italic, Underline, StrikeOut, ScaleX, ScaleY, Spacing, Angle, BorderStyle, Outline, Shadow, Alignment, MarginL, MarginR, MarginV, Encoding
+Style: Default,Arial,20,&H00FFFFFF,&H000000FF,&H00000000,&H00000000,0,0,0,0,100,100,0,0,1,2,2,2,10,10,10,1
+`
+
+func TestExtract(t *testing.T) {
+	tests := []struct {
+		name   string
+		track  matroska.TrackNumber
+		format Format
+		want   string
+	}{
+		{"utf8 to srt", 1, SRT, "1\n00:00:01,000 --> 00:00:02,500\nHello\nworld\n\n2\n00:00:03,000 --> 00:00:04,000\n<i>Bye</i>\n\n"},
+		{"utf8 to webvtt", 1, WebVTT, "WEBVTT\n\n00:00:01.000 --> 00:00:02.500\nHello\nworld\n\n00:00:03.000 --> 00:00:04.000\n<i>Bye</i>\n\n"},
+		{"ass to srt", 2, SRT, "1\n00:00:01,000 --> 00:00:02,000\nFirst\n\n2\n00:00:01,000 --> 00:00:03,000\nSecond\nline\n\n"},
+		{"ass to ass", 2, ASS, testASSHeader + "\n[Events]\n" + assFormat + "\n" +
+			"Dialogue: 1,0:00:01.00,0:00:02.00,Default,Bob,0,0,0,,{\\b1}First\n" +
+			"Dialogue: 0,0:00:01.00,0:00:03.00,Default,,0,0,0,,Second{\\i1}\\Nline\n"},
+	}
+	b, err := ebml.Marshal(newTestFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, it := range tests {
+		r, err := matroska.NewReader(bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := &bytes.Buffer{}
+		if err = Extract(out, r, it.track, it.format); err != nil {
+			t.Fatalf("%s: %v", it.name, err)
+		}
+		if out.String() != it.want {
+			t.Errorf("%s: unexpected output, want:\n%s\ngot:\n%s", it.name, it.want, out.String())
+		}
+	}
+}
+
+func TestMux(t *testing.T) {
+	srt := "1\n00:00:00,500 --> 00:00:01,250\nFirst\nline\n\n2\n00:00:05,000 --> 00:00:06,000\nLast\n\n"
+	ass := testASSHeader + "\n[Events]\n" + assFormat + "\n" +
+		"Dialogue: 0,0:00:02.00,0:00:03.50,Default,,0,0,0,,{\\i1}Italic\n" +
+		"Dialogue: 1,0:00:01.00,0:00:02.00,Default,Bob,0,0,0,,Early\n"
+	vtt := "WEBVTT\n\nSTYLE\n::cue { color: lime }\n\nintro\n00:00:01.000 --> 00:00:02.000 align:start\nHi\n\n00:00:05.000 --> 00:00:06.000\nThere\n\n"
+	var tracks []*Track
+	for _, it := range []struct {
+		read func(io.Reader) (*Track, error)
+		text string
+	}{{ReadSRT, srt}, {ReadASS, ass}, {ReadWebVTT, vtt}} {
+		tr, err := it.read(strings.NewReader(it.text))
+		if err != nil {
+			t.Fatal(err)
+		}
+		tracks = append(tracks, tr)
+	}
+	b, err := ebml.Marshal(newTestFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := matroska.NewReader(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = Mux(&bytes.Buffer{}, r, tracks, &matroska.WriterOptions{DocType: "webm"}); err == nil {
+		t.Error("Expected error for SRT and ASS tracks in webm")
+	}
+	out := &bytes.Buffer{}
+	if err = Mux(out, r, tracks, nil); err != nil {
+		t.Fatal(err)
+	}
+	for i, it := range []struct {
+		format Format
+		want   string
+	}{{SRT, srt}, {ASS, ass}, {WebVTT, vtt}} {
+		r, err := matroska.NewReader(bytes.NewReader(out.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		res := &bytes.Buffer{}
+		if err = Extract(res, r, matroska.TrackNumber(i+3), it.format); err != nil {
+			t.Fatal(err)
+		}
+		if res.String() != it.want {
+			t.Errorf("unexpected output, want:\n%s\ngot:\n%s", it.want, res.String())
+		}
+	}
+}
+
+func newTestFile() *matroska.File {
+	f := matroska.NewFile("matroska")
+	f.Segment.Info = []*matroska.Info{{TimecodeScale: time.Millisecond, MuxingApp: "test", WritingApp: "test"}}
+	f.Segment.Tracks = []*matroska.Track{{Entries: []*matroska.TrackEntry{
+		{Number: 1, ID: 1, Type: matroska.TrackTypeSubtitle, CodecID: CodecUTF8},
+		{Number: 2, ID: 2, Type: matroska.TrackTypeSubtitle, CodecID: CodecASS, CodecPrivate: []byte(testASSHeader)},
+	}}}
+	group := func(track matroska.TrackNumber, tc int16, d matroska.Duration, text string) *matroska.BlockGroup {
+		return &matroska.BlockGroup{
+			Block:    &matroska.Block{TrackNumber: track, Timecode: tc, Frames: [][]byte{[]byte(text)}},
+			Duration: d,
+		}
+	}
+	f.Segment.Cluster = []*matroska.Cluster{{
+		Timecode: 1000,
+		BlockGroup: []*matroska.BlockGroup{
+			group(1, 0, 1500, "Hello\nworld"),
+			group(2, 0, 2000, "1,0,Default,,0,0,0,,Second{\\i1}\\Nline"),
+			group(2, 0, 1000, "0,1,Default,Bob,0,0,0,,{\\b1}First"),
+			group(1, 2000, 1000, "<i>Bye</i>"),
+		},
+	}}
+	return f
+}
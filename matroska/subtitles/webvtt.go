@@ -0,0 +1,102 @@
+package subtitles
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// ReadWebVTT reads the WebVTT file.
+// The header, STYLE and REGION blocks preceding the first cue make up the header of the track.
+func ReadWebVTT(r io.Reader) (*Track, error) {
+	lines, err := readLines(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 || !strings.HasPrefix(lines[0], "WEBVTT") {
+		return nil, errors.New("subtitles: invalid WebVTT header")
+	}
+	t := &Track{Format: WebVTT}
+	var header []string
+	for i := 0; i < len(lines); {
+		for i < len(lines) && strings.TrimSpace(lines[i]) == "" {
+			i++
+		}
+		j := i
+		for j < len(lines) && strings.TrimSpace(lines[j]) != "" {
+			j++
+		}
+		block := lines[i:j]
+		if len(block) == 0 {
+			break
+		}
+		k := 0
+		if !strings.Contains(block[0], "-->") {
+			k = 1
+		}
+		switch {
+		case i == 0:
+			header = append(header, strings.Join(block, "\n"))
+		case strings.HasPrefix(block[0], "NOTE"):
+		case k < len(block) && strings.Contains(block[k], "-->"):
+			start, end, settings, err := parseTiming(block[k])
+			if err != nil {
+				return nil, err
+			}
+			e := &Event{
+				Start:     start,
+				End:       end,
+				ReadOrder: len(t.Events),
+				Settings:  settings,
+				Text:      strings.Join(block[k+1:], "\n"),
+			}
+			if k > 0 {
+				e.ID = block[0]
+			}
+			t.Events = append(t.Events, e)
+		case len(t.Events) == 0:
+			header = append(header, strings.Join(block, "\n"))
+		}
+		i = j
+	}
+	t.Header = strings.Join(header, "\n\n")
+	return t, nil
+}
+
+func (w *Writer) writeWebVTT() error {
+	b := bufio.NewWriter(w.w)
+	header := strings.TrimSpace(string(w.track.CodecPrivate))
+	if !strings.HasPrefix(header, "WEBVTT") {
+		header = strings.TrimSpace("WEBVTT\n\n" + header)
+	}
+	b.WriteString(header + "\n\n")
+	for _, it := range w.events {
+		if it.ID != "" {
+			b.WriteString(it.ID + "\n")
+		}
+		fmt.Fprintf(b, "%s --> %s", formatWebVTTTime(it.Start), formatWebVTTTime(it.End))
+		if it.Settings != "" {
+			b.WriteString(" " + it.Settings)
+		}
+		text := w.plainText(it)
+		if w.ass {
+			text = escapeWebVTT(text)
+		}
+		fmt.Fprintf(b, "\n%s\n\n", strings.TrimRight(text, "\n"))
+	}
+	return b.Flush()
+}
+
+func formatWebVTTTime(t time.Duration) string {
+	h, m, s, ms := splitTime(t)
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", h, m, s, ms)
+}
+
+var webVTTEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+func escapeWebVTT(s string) string {
+	return webVTTEscaper.Replace(s)
+}
@@ -0,0 +1,190 @@
+package matroska
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+)
+
+// ReadTagsXML reads tags in the Matroska tag XML format used by mkvtoolnix.
+func ReadTagsXML(r io.Reader) ([]*Tag, error) {
+	v := &xmlTags{}
+	if err := xml.NewDecoder(r).Decode(v); err != nil {
+		return nil, err
+	}
+	tags := make([]*Tag, 0, len(v.Tags))
+	for _, it := range v.Tags {
+		tag := &Tag{}
+		for _, t := range it.Targets {
+			target := &Target{
+				TypeValue: t.TypeValue,
+				Type:      t.Type,
+			}
+			if target.TypeValue == 0 {
+				target.TypeValue = TargetTypeAlbum
+			}
+			for _, id := range t.TrackIDs {
+				target.TrackIDs = append(target.TrackIDs, TrackID(id))
+			}
+			for _, id := range t.EditionIDs {
+				target.EditionIDs = append(target.EditionIDs, encodeEditionID(id))
+			}
+			for _, id := range t.ChapterIDs {
+				target.ChapterIDs = append(target.ChapterIDs, ChapterID(id))
+			}
+			for _, id := range t.AttachmentIDs {
+				target.AttachmentIDs = append(target.AttachmentIDs, AttachmentID(id))
+			}
+			tag.Targets = append(tag.Targets, target)
+		}
+		if len(tag.Targets) == 0 {
+			tag.Targets = []*Target{{TypeValue: TargetTypeAlbum}}
+		}
+		simple, err := readXMLSimpleTags(it.SimpleTags)
+		if err != nil {
+			return nil, err
+		}
+		tag.SimpleTags = simple
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+func readXMLSimpleTags(list []*xmlSimpleTag) ([]*SimpleTag, error) {
+	var tags []*SimpleTag
+	for _, it := range list {
+		t := &SimpleTag{
+			Name:         it.Name,
+			Language:     it.Language,
+			LanguageIETF: it.LanguageIETF,
+			Default:      it.Default == nil || bool(*it.Default),
+			String:       it.String,
+		}
+		if t.Language == "" {
+			t.Language = "und"
+		}
+		if it.Binary != nil {
+			b, err := it.Binary.bytes()
+			if err != nil {
+				return nil, err
+			}
+			t.Binary = b
+		}
+		simple, err := readXMLSimpleTags(it.SimpleTags)
+		if err != nil {
+			return nil, err
+		}
+		t.SimpleTags = simple
+		tags = append(tags, t)
+	}
+	return tags, nil
+}
+
+// WriteTagsXML writes tags in the Matroska tag XML format used by mkvtoolnix.
+func WriteTagsXML(w io.Writer, tags []*Tag) error {
+	v := &xmlTags{}
+	for _, it := range tags {
+		tag := &xmlTag{SimpleTags: writeXMLSimpleTags(it.SimpleTags)}
+		for _, t := range it.Targets {
+			target := &xmlTarget{
+				TypeValue: t.TypeValue,
+				Type:      t.Type,
+			}
+			for _, id := range t.TrackIDs {
+				target.TrackIDs = append(target.TrackIDs, uint64(id))
+			}
+			for _, id := range t.EditionIDs {
+				target.EditionIDs = append(target.EditionIDs, decodeEditionID(id))
+			}
+			for _, id := range t.ChapterIDs {
+				target.ChapterIDs = append(target.ChapterIDs, uint64(id))
+			}
+			for _, id := range t.AttachmentIDs {
+				target.AttachmentIDs = append(target.AttachmentIDs, uint64(id))
+			}
+			tag.Targets = append(tag.Targets, target)
+		}
+		v.Tags = append(v.Tags, tag)
+	}
+	if _, err := io.WriteString(w, xml.Header+"<!DOCTYPE Tags SYSTEM \"matroskatags.dtd\">\n"); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func writeXMLSimpleTags(tags []*SimpleTag) []*xmlSimpleTag {
+	var list []*xmlSimpleTag
+	for _, it := range tags {
+		def := xmlFlag(it.Default)
+		t := &xmlSimpleTag{
+			Name:         it.Name,
+			String:       it.String,
+			Language:     it.Language,
+			LanguageIETF: it.LanguageIETF,
+			Default:      &def,
+			SimpleTags:   writeXMLSimpleTags(it.SimpleTags),
+		}
+		if it.Binary != nil {
+			t.Binary = &xmlBinary{Format: "base64", Data: base64.StdEncoding.EncodeToString(it.Binary)}
+		}
+		list = append(list, t)
+	}
+	return list
+}
+
+type xmlTags struct {
+	XMLName xml.Name  `xml:"Tags"`
+	Tags    []*xmlTag `xml:"Tag"`
+}
+
+type xmlTag struct {
+	Targets    []*xmlTarget    `xml:"Targets"`
+	SimpleTags []*xmlSimpleTag `xml:"Simple"`
+}
+
+type xmlTarget struct {
+	TypeValue     int      `xml:"TargetTypeValue,omitempty"`
+	Type          string   `xml:"TargetType,omitempty"`
+	TrackIDs      []uint64 `xml:"TrackUID"`
+	EditionIDs    []uint64 `xml:"EditionUID"`
+	ChapterIDs    []uint64 `xml:"ChapterUID"`
+	AttachmentIDs []uint64 `xml:"AttachmentUID"`
+}
+
+type xmlSimpleTag struct {
+	Name         string          `xml:"Name"`
+	String       string          `xml:"String,omitempty"`
+	Binary       *xmlBinary      `xml:"Binary"`
+	Language     string          `xml:"TagLanguage,omitempty"`
+	LanguageIETF string          `xml:"TagLanguageIETF,omitempty"`
+	Default      *xmlFlag        `xml:"DefaultLanguage"`
+	SimpleTags   []*xmlSimpleTag `xml:"Simple"`
+}
+
+// xmlBinary is a binary value encoded as hex, base64 or ASCII text.
+type xmlBinary struct {
+	Format string `xml:"format,attr,omitempty"`
+	Data   string `xml:",chardata"`
+}
+
+func (b *xmlBinary) bytes() ([]byte, error) {
+	switch strings.ToLower(b.Format) {
+	case "hex":
+		return hex.DecodeString(strings.Join(strings.Fields(b.Data), ""))
+	case "", "base64":
+		return base64.StdEncoding.DecodeString(strings.Join(strings.Fields(b.Data), ""))
+	case "ascii":
+		return []byte(b.Data), nil
+	default:
+		return nil, errors.New("matroska: unsupported binary format: " + b.Format)
+	}
+}
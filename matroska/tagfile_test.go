@@ -0,0 +1,65 @@
+package matroska
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTagsXML(t *testing.T) {
+	artist := NewSimpleTag("ARTIST", "Blender Foundation")
+	artist.SimpleTags = []*SimpleTag{NewSimpleTag("SORT_WITH", "Foundation")}
+	cover := &SimpleTag{Name: "COVER", Language: "und", Binary: []byte{0, 1, 2, 0xff}}
+	tags := []*Tag{
+		newTestTags("Big Buck Bunny", "Test")[0],
+		{
+			Targets:    []*Target{{TypeValue: TargetTypeTrack, Type: "TRACK", TrackIDs: []TrackID{102}, EditionIDs: []EditionID{{1, 2}}}},
+			SimpleTags: []*SimpleTag{artist, cover},
+		},
+	}
+	b := &bytes.Buffer{}
+	if err := WriteTagsXML(b, tags); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadTagsXML(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(tags, got) {
+		t.Errorf("Unexpected tags, want: %s\ngot: %s", dump(tags), dump(got))
+	}
+}
+
+func TestReadTagsXML(t *testing.T) {
+	in := `<?xml version="1.0"?>
+<Tags>
+  <Tag>
+    <Targets><TrackUID>7</TrackUID></Targets>
+    <Simple>
+      <Name>ENCODER</Name>
+      <String>libvpx</String>
+      <TagLanguage>eng</TagLanguage>
+      <DefaultLanguage>0</DefaultLanguage>
+    </Simple>
+    <Simple>
+      <Name>DATA</Name>
+      <Binary format="hex">01 02 ff</Binary>
+    </Simple>
+  </Tag>
+</Tags>`
+	got, err := ReadTagsXML(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*Tag{{
+		Targets: []*Target{{TypeValue: TargetTypeAlbum, TrackIDs: []TrackID{7}}},
+		SimpleTags: []*SimpleTag{
+			{Name: "ENCODER", String: "libvpx", Language: "eng"},
+			{Name: "DATA", Binary: []byte{1, 2, 0xff}, Language: "und", Default: true},
+		},
+	}}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("Unexpected tags, want: %s\ngot: %s", dump(want), dump(got))
+	}
+}
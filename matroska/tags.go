@@ -0,0 +1,158 @@
+package matroska
+
+import "sort"
+
+// Target type values define the logical level of the tagged target.
+const (
+	TargetTypeCollection = 70
+	TargetTypeEdition    = 60
+	TargetTypeSeason     = 60
+	TargetTypeAlbum      = 50
+	TargetTypeMovie      = 50
+	TargetTypeEpisode    = 50
+	TargetTypePart       = 40
+	TargetTypeSession    = 40
+	TargetTypeTrack      = 30
+	TargetTypeChapter    = 30
+	TargetTypeScene      = 20
+	TargetTypeShot       = 10
+)
+
+// Track returns the track entry with the given number or nil if there is no such track.
+func (s *Segment) Track(n TrackNumber) *TrackEntry {
+	for _, t := range s.Tracks {
+		for _, it := range t.Entries {
+			if it.Number == n {
+				return it
+			}
+		}
+	}
+	return nil
+}
+
+// TagsFor returns string values of tags applied to the target, keyed by tag name.
+// Names of nested tags are joined with a dot, for example "ARTIST.SORT_WITH".
+//
+// Tags without IDs of some kind apply to all elements of that kind and tags of
+// the higher TypeValue levels apply to the lower ones, more specific tags override them.
+// Zero TypeValue of the target with IDs matches tags of all levels,
+// without IDs it is the default level 50 of the whole Segment.
+func (s *Segment) TagsFor(target *Target) map[string]string {
+	if target == nil {
+		target = &Target{}
+	}
+	if target.TypeValue == 0 && !target.hasUIDs() {
+		target = &Target{TypeValue: TargetTypeAlbum}
+	}
+	var list []*tagMatch
+	for _, tag := range s.Tags {
+		targets := tag.Targets
+		if len(targets) == 0 {
+			targets = []*Target{{TypeValue: TargetTypeAlbum}}
+		}
+		for _, it := range targets {
+			if n, ok := it.match(target); ok {
+				list = append(list, &tagMatch{tag, it.TypeValue, n})
+				break
+			}
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if a.ids != b.ids {
+			return a.ids < b.ids
+		}
+		return a.level > b.level
+	})
+	values := make(map[string]string)
+	for _, it := range list {
+		v := make(map[string]*SimpleTag)
+		collectTags(v, "", it.tag.SimpleTags)
+		for name, t := range v {
+			values[name] = t.String
+		}
+	}
+	return values
+}
+
+type tagMatch struct {
+	tag   *Tag
+	level int
+	ids   int
+}
+
+func collectTags(v map[string]*SimpleTag, prefix string, tags []*SimpleTag) {
+	for _, it := range tags {
+		name := prefix + it.Name
+		if it.String != "" || it.Binary == nil {
+			if prev, ok := v[name]; !ok || it.Default && !prev.Default {
+				v[name] = it
+			}
+		}
+		collectTags(v, name+".", it.SimpleTags)
+	}
+}
+
+// match reports whether the tags of t apply to the target q
+// and returns a number of ID kinds specified by t.
+func (t *Target) match(q *Target) (int, bool) {
+	if q.TypeValue > 0 && t.TypeValue < q.TypeValue {
+		return 0, false
+	}
+	n := 0
+	a, b := t.uids(), q.uids()
+	for i := range a {
+		if len(a[i]) == 0 {
+			continue
+		}
+		if !intersects(a[i], b[i]) {
+			return 0, false
+		}
+		n++
+	}
+	return n, true
+}
+
+func (t *Target) hasUIDs() bool {
+	for _, it := range t.uids() {
+		if len(it) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// uids returns non-zero track, edition, chapter and attachment IDs of the target.
+func (t *Target) uids() (v [4][]uint64) {
+	for _, it := range t.TrackIDs {
+		v[0] = appendUID(v[0], uint64(it))
+	}
+	for _, it := range t.EditionIDs {
+		v[1] = appendUID(v[1], decodeEditionID(it))
+	}
+	for _, it := range t.ChapterIDs {
+		v[2] = appendUID(v[2], uint64(it))
+	}
+	for _, it := range t.AttachmentIDs {
+		v[3] = appendUID(v[3], uint64(it))
+	}
+	return
+}
+
+func appendUID(v []uint64, id uint64) []uint64 {
+	if id == 0 {
+		return v
+	}
+	return append(v, id)
+}
+
+func intersects(a, b []uint64) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,69 @@
+package matroska
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTagsFor(t *testing.T) {
+	artist := NewSimpleTag("ARTIST", "Blender Foundation")
+	artist.SimpleTags = []*SimpleTag{NewSimpleTag("SORT_WITH", "Foundation")}
+	german := NewSimpleTag("TITLE", "Kommentar")
+	german.Language, german.Default = "ger", false
+	s := &Segment{
+		Tracks: []*Track{{Entries: []*TrackEntry{
+			{Number: 1, ID: 101},
+			{Number: 2, ID: 102},
+		}}},
+		Tags: []*Tag{
+			{
+				Targets:    []*Target{{TypeValue: TargetTypeMovie}},
+				SimpleTags: []*SimpleTag{NewSimpleTag("TITLE", "Big Buck Bunny"), artist},
+			},
+			{
+				Targets:    []*Target{{TypeValue: TargetTypeTrack}},
+				SimpleTags: []*SimpleTag{NewSimpleTag("TITLE", "Track")},
+			},
+			{
+				Targets:    []*Target{{TypeValue: TargetTypeTrack, TrackIDs: []TrackID{102}}},
+				SimpleTags: []*SimpleTag{german, NewSimpleTag("TITLE", "Commentary")},
+			},
+			{
+				Targets:    []*Target{{TypeValue: TargetTypeShot, ChapterIDs: []ChapterID{7}}},
+				SimpleTags: []*SimpleTag{NewSimpleTag("TITLE", "Shot")},
+			},
+		},
+	}
+	tests := []struct {
+		name   string
+		target *Target
+		want   map[string]string
+	}{
+		{"segment", nil, map[string]string{
+			"TITLE":            "Big Buck Bunny",
+			"ARTIST":           "Blender Foundation",
+			"ARTIST.SORT_WITH": "Foundation",
+		}},
+		{"movie", &Target{TypeValue: TargetTypeMovie}, map[string]string{
+			"TITLE":            "Big Buck Bunny",
+			"ARTIST":           "Blender Foundation",
+			"ARTIST.SORT_WITH": "Foundation",
+		}},
+		{"track 1", &Target{TrackIDs: []TrackID{s.Track(1).ID}}, map[string]string{
+			"TITLE":            "Track",
+			"ARTIST":           "Blender Foundation",
+			"ARTIST.SORT_WITH": "Foundation",
+		}},
+		{"track 2", &Target{TrackIDs: []TrackID{s.Track(2).ID}}, map[string]string{
+			"TITLE":            "Commentary",
+			"ARTIST":           "Blender Foundation",
+			"ARTIST.SORT_WITH": "Foundation",
+		}},
+		{"collection", &Target{TypeValue: TargetTypeCollection}, map[string]string{}},
+	}
+	for _, it := range tests {
+		if got := s.TagsFor(it.target); !reflect.DeepEqual(it.want, got) {
+			t.Errorf("%s: unexpected tags, want: %v, got: %v", it.name, it.want, got)
+		}
+	}
+}
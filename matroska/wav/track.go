@@ -0,0 +1,83 @@
+package wav
+
+import (
+	"errors"
+	"github.com/pixelbender/go-matroska/matroska"
+	"io"
+	"time"
+)
+
+// Number of frames per second read by Track
+const frameRate = 50
+
+// Track reads samples of the WAVE file as fixed-duration packets of the Matroska track.
+// Packets are laced by matroska.Writer if MaxLacedFrames is set.
+type Track struct {
+	Entry *matroska.TrackEntry
+
+	r    *Reader
+	size int   // Number of samples per frame
+	pos  int64 // Number of samples read so far
+}
+
+// NewTrack returns the track with the given number reading samples from r.
+func NewTrack(r *Reader, number matroska.TrackNumber) (*Track, error) {
+	f := r.Format
+	e := &matroska.TrackEntry{
+		Number:         number,
+		Type:           matroska.TrackTypeAudio,
+		Enabled:        true,
+		Default:        true,
+		CodecDecodeAll: true,
+		Lacing:         true,
+		Audio: &matroska.AudioTrack{
+			SamplingFreq: float64(f.SampleRate),
+			Channels:     f.Channels,
+			BitDepth:     f.BitDepth,
+		},
+	}
+	switch f.Tag {
+	case FormatPCM:
+		e.CodecID = matroska.CodecPCM
+	case FormatFloat:
+		e.CodecID = matroska.CodecFloat
+	default:
+		return nil, errors.New("wav: unsupported format")
+	}
+	t := &Track{Entry: e, r: r, size: f.SampleRate / frameRate}
+	if t.size == 0 {
+		t.size = 1
+	}
+	e.DefaultDuration = t.duration(int64(t.size))
+	return t, nil
+}
+
+// ReadPacket reads the next packet. Returns io.EOF at the end of the samples.
+// The last packet may be shorter than the default duration.
+func (t *Track) ReadPacket() (*matroska.Packet, error) {
+	align := t.r.Format.BlockAlign()
+	b := make([]byte, t.size*align)
+	n, err := io.ReadFull(t.r, b)
+	if n /= align; n == 0 {
+		if err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+		return nil, err
+	}
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	p := &matroska.Packet{
+		Track:    t.Entry.Number,
+		Time:     t.duration(t.pos),
+		Duration: t.duration(int64(n)),
+		Keyframe: true,
+		Data:     b[:n*align],
+	}
+	t.pos += int64(n)
+	return p, nil
+}
+
+func (t *Track) duration(n int64) time.Duration {
+	return time.Duration(n) * time.Second / time.Duration(t.r.Format.SampleRate)
+}
@@ -0,0 +1,193 @@
+// Package wav implements the RIFF WAVE audio file format.
+package wav
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+)
+
+// Sample formats
+const (
+	FormatPCM   = 1
+	FormatFloat = 3
+)
+
+// Format describes samples of the WAVE file.
+type Format struct {
+	Tag        int // FormatPCM or FormatFloat
+	Channels   int
+	SampleRate int
+	BitDepth   int
+}
+
+// BlockAlign returns the size of a single sample frame in bytes.
+func (f *Format) BlockAlign() int {
+	return f.Channels * ((f.BitDepth + 7) / 8)
+}
+
+// Reader reads samples from the WAVE file.
+type Reader struct {
+	Format *Format
+	// Size is the size of samples in bytes, -1 if not known
+	Size int64
+
+	r io.Reader
+}
+
+const formatExtensible = 0xfffe
+
+// NewReader reads the WAVE header from r up to the data chunk and returns a new reader.
+func NewReader(r io.Reader) (*Reader, error) {
+	b := make([]byte, 12)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, err
+	}
+	if string(b[:4]) != "RIFF" || string(b[8:]) != "WAVE" {
+		return nil, errors.New("wav: invalid header")
+	}
+	var f *Format
+	for {
+		if _, err := io.ReadFull(r, b[:8]); err != nil {
+			return nil, unexpected(err)
+		}
+		id, size := string(b[:4]), int64(binary.LittleEndian.Uint32(b[4:]))
+		switch id {
+		case "fmt ":
+			if size < 16 {
+				return nil, errors.New("wav: invalid format chunk")
+			}
+			v := make([]byte, size+size&1)
+			if _, err := io.ReadFull(r, v); err != nil {
+				return nil, unexpected(err)
+			}
+			f = &Format{
+				Tag:        int(binary.LittleEndian.Uint16(v)),
+				Channels:   int(binary.LittleEndian.Uint16(v[2:])),
+				SampleRate: int(binary.LittleEndian.Uint32(v[4:])),
+				BitDepth:   int(binary.LittleEndian.Uint16(v[14:])),
+			}
+			// Sub format GUID starts with the format tag
+			if f.Tag == formatExtensible && size >= 40 {
+				f.Tag = int(binary.LittleEndian.Uint16(v[24:]))
+			}
+		case "data":
+			if f == nil {
+				return nil, errors.New("wav: no format chunk")
+			}
+			if f.Channels <= 0 || f.SampleRate <= 0 || f.BitDepth <= 0 {
+				return nil, errors.New("wav: invalid format")
+			}
+			rd := &Reader{Format: f, Size: size, r: r}
+			// Streaming writers set sizes to zero or maximum values
+			if size == 0 || size == 0xffffffff-headerSize || size == 0xffffffff {
+				rd.Size = -1
+			} else {
+				rd.r = io.LimitReader(r, size)
+			}
+			return rd, nil
+		default:
+			if _, err := io.CopyN(ioutil.Discard, r, size+size&1); err != nil {
+				return nil, unexpected(err)
+			}
+		}
+	}
+}
+
+// Read reads interleaved little-endian samples.
+func (r *Reader) Read(b []byte) (int, error) {
+	return r.r.Read(b)
+}
+
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// Writer writes samples into the WAVE file.
+type Writer struct {
+	Format *Format
+
+	w    io.Writer
+	seek io.WriteSeeker
+	base int64
+	size int64
+}
+
+const headerSize = 44
+
+// NewWriter writes the WAVE header to w and returns a new writer.
+// The sizes of the header are updated by Close if w is an io.WriteSeeker,
+// otherwise they are set to the maximum values.
+func NewWriter(w io.Writer, f *Format) (*Writer, error) {
+	if f.Channels <= 0 || f.SampleRate <= 0 || f.BitDepth <= 0 {
+		return nil, errors.New("wav: invalid format")
+	}
+	wr := &Writer{Format: f, w: w, size: -1}
+	if s, ok := w.(io.WriteSeeker); ok {
+		if off, err := s.Seek(0, io.SeekCurrent); err == nil {
+			wr.seek, wr.base = s, off
+		}
+	}
+	if _, err := w.Write(wr.header()); err != nil {
+		return nil, err
+	}
+	wr.size = 0
+	return wr, nil
+}
+
+// Write writes interleaved little-endian samples.
+func (w *Writer) Write(b []byte) (int, error) {
+	n, err := w.w.Write(b)
+	w.size += int64(n)
+	return n, err
+}
+
+// Close writes the padding byte and updates sizes of the header if the output is seekable.
+func (w *Writer) Close() error {
+	if w.size&1 != 0 {
+		if _, err := w.w.Write([]byte{0}); err != nil {
+			return err
+		}
+	}
+	if w.seek == nil {
+		return nil
+	}
+	end, err := w.seek.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err = w.seek.Seek(w.base, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err = w.seek.Write(w.header()); err != nil {
+		return err
+	}
+	_, err = w.seek.Seek(end, io.SeekStart)
+	return err
+}
+
+func (w *Writer) header() []byte {
+	f := w.Format
+	size := uint32(0xffffffff - headerSize)
+	if w.size >= 0 {
+		size = uint32(w.size)
+	}
+	b := make([]byte, headerSize)
+	copy(b, "RIFF")
+	binary.LittleEndian.PutUint32(b[4:], size+size&1+headerSize-8)
+	copy(b[8:], "WAVEfmt ")
+	binary.LittleEndian.PutUint32(b[16:], 16)
+	binary.LittleEndian.PutUint16(b[20:], uint16(f.Tag))
+	binary.LittleEndian.PutUint16(b[22:], uint16(f.Channels))
+	binary.LittleEndian.PutUint32(b[24:], uint32(f.SampleRate))
+	binary.LittleEndian.PutUint32(b[28:], uint32(f.SampleRate*f.BlockAlign()))
+	binary.LittleEndian.PutUint16(b[32:], uint16(f.BlockAlign()))
+	binary.LittleEndian.PutUint16(b[34:], uint16(f.BitDepth))
+	copy(b[36:], "data")
+	binary.LittleEndian.PutUint32(b[40:], size)
+	return b
+}
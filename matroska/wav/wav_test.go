@@ -0,0 +1,118 @@
+package wav
+
+import (
+	"bytes"
+	"github.com/pixelbender/go-matroska/matroska"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestTrack(t *testing.T) {
+	b := &bytes.Buffer{}
+	w, err := NewWriter(b, &Format{Tag: FormatPCM, Channels: 1, SampleRate: 8000, BitDepth: 16})
+	if err != nil {
+		t.Fatal(err)
+	}
+	samples := make([]byte, 1010*2)
+	for i := range samples {
+		samples[i] = byte(i)
+	}
+	if _, err = w.Write(samples); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewReader(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f := r.Format; *f != (Format{Tag: FormatPCM, Channels: 1, SampleRate: 8000, BitDepth: 16}) || r.Size != -1 {
+		t.Fatalf("Unexpected format: %+v, size: %d", f, r.Size)
+	}
+	tr, err := NewTrack(r, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := tr.Entry
+	if e.CodecID != matroska.CodecPCM || e.DefaultDuration != 20*time.Millisecond || e.Audio.SamplingFreq != 8000 || e.Audio.BitDepth != 16 {
+		t.Fatalf("Unexpected track entry: %+v", e)
+	}
+	out, err := ioutil.TempFile("", "wav")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(out.Name())
+	defer out.Close()
+	mw, err := matroska.NewWriter(out, &matroska.Segment{Tracks: []*matroska.Track{{Entries: []*matroska.TrackEntry{e}}}}, &matroska.WriterOptions{MaxLacedFrames: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		p, err := tr.ReadPacket()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = mw.WritePacket(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = mw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f, err := matroska.Decode(out.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 6 full frames are laced into 2 blocks, the short last frame is in a block group
+	c := f.Segment.Cluster[0]
+	if len(c.SimpleBlock) != 2 || len(c.SimpleBlock[0].Frames) != 4 || len(c.SimpleBlock[1].Frames) != 2 || len(c.BlockGroup) != 1 {
+		t.Fatalf("Unexpected cluster: %d blocks, %d groups", len(c.SimpleBlock), len(c.BlockGroup))
+	}
+	if _, err = out.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	mr, err := matroska.NewReader(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data []byte
+	for i := 0; ; i++ {
+		p, err := mr.ReadPacket()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Time != time.Duration(i)*20*time.Millisecond {
+			t.Errorf("Unexpected packet %d: %+v", i, p)
+		}
+		data = append(data, p.Data...)
+	}
+	if !bytes.Equal(data, samples) {
+		t.Errorf("Unexpected samples: %d bytes", len(data))
+	}
+}
+
+func TestBlockAlign(t *testing.T) {
+	for _, it := range []struct {
+		format *Format
+		want   int
+	}{
+		{&Format{Channels: 2, BitDepth: 16}, 4},
+		{&Format{Channels: 2, BitDepth: 20}, 6},
+		{&Format{Channels: 6, BitDepth: 24}, 18},
+		{&Format{Channels: 3, BitDepth: 12}, 6},
+	} {
+		if got := it.format.BlockAlign(); got != it.want {
+			t.Errorf("Unexpected block align of %+v: %d", it.format, got)
+		}
+	}
+}
@@ -0,0 +1,504 @@
+package matroska
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"github.com/pixelbender/go-matroska/ebml"
+	"io"
+	"math"
+	"time"
+)
+
+// WriterOptions contains options of the Matroska writer.
+type WriterOptions struct {
+	// DocType is the EBML document type, "matroska" if empty.
+	DocType string
+	// ClusterDuration is the duration of Clusters, 5 seconds if zero.
+	// New Clusters are started with keyframes of video tracks if there are any.
+	ClusterDuration time.Duration
+	// ClusterSize is the maximum size of Clusters in bytes, 5 MB if zero.
+	ClusterSize int
+	// MaxLacedFrames is the maximum number of frames laced into a block, lacing is disabled if zero.
+	// Consecutive keyframes of audio tracks with Lacing and DefaultDuration are laced.
+	MaxLacedFrames int
+	// Interleave is the maximum time span of packets buffered to write packets of all tracks in time order.
+	// Packets are written in the order of calls if zero.
+	// Packets of non-video tracks older than written packets are rejected.
+	Interleave time.Duration
+}
+
+const seekHeadSize = 256
+
+// Writer writes packets into the Matroska stream.
+//
+// Info, Tracks, Chapters and Attachments of the Segment are written by NewWriter,
+// Cues and Tags are written by Close.
+// The Segment size, duration and SeekHead are written if the output is an io.WriteSeeker.
+type Writer struct {
+	Segment *Segment
+	// Filters are applied to copies of packets of tracks before they are written.
+	Filters map[TrackNumber]PacketFilter
+
+	opt     WriterOptions
+	out     *countWriter
+	seek    io.WriteSeeker
+	base    int64
+	segOff  int64
+	start   int64
+	headOff int64
+	infoOff int64
+	infoLen int64
+	seeks   []*Seek
+	scale   time.Duration
+	video   bool
+	dur     bool
+	cluster *clusterWriter
+	lace    *lacedBlock
+	cues    []*CuePoint
+	last    map[TrackNumber]int64
+	end     time.Duration
+	queue   map[TrackNumber][]*Packet
+	times   map[TrackNumber]time.Duration
+	newest  time.Duration
+	written time.Duration // Latest time of interleaved packets written
+}
+
+type clusterWriter struct {
+	pos    int64
+	time   int64
+	blocks int
+	buf    bytes.Buffer
+	enc    *ebml.Writer
+}
+
+// lacedBlock is the pending block of laced frames.
+type lacedBlock struct {
+	p      *Packet // First frame
+	t      *TrackEntry
+	frames [][]byte
+}
+
+// NewWriter writes the EBML header and the Segment header to w and returns a new writer.
+func NewWriter(w io.Writer, seg *Segment, opt *WriterOptions) (*Writer, error) {
+	m := &Writer{
+		Segment: seg,
+		out:     &countWriter{w: w},
+		last:    make(map[TrackNumber]int64),
+		queue:   make(map[TrackNumber][]*Packet),
+		times:   make(map[TrackNumber]time.Duration),
+	}
+	if opt != nil {
+		m.opt = *opt
+	}
+	if m.opt.DocType == "" {
+		m.opt.DocType = "matroska"
+	}
+	if m.opt.ClusterDuration == 0 {
+		m.opt.ClusterDuration = 5 * time.Second
+	}
+	if m.opt.ClusterSize == 0 {
+		m.opt.ClusterSize = 5 << 20
+	}
+	if s, ok := w.(io.WriteSeeker); ok {
+		if off, err := s.Seek(0, io.SeekCurrent); err == nil {
+			m.seek, m.base = s, off
+		}
+	}
+	if err := m.writeHeader(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (w *Writer) writeHeader() error {
+	s := w.Segment
+	if len(s.Info) == 0 {
+		s.Info = []*Info{{}}
+	}
+	info := s.Info[0]
+	if info.TimecodeScale == 0 {
+		info.TimecodeScale = time.Millisecond
+	}
+	if info.MuxingApp == "" {
+		info.MuxingApp = "go-matroska"
+	}
+	if info.WritingApp == "" {
+		info.WritingApp = "go-matroska"
+	}
+	if len(info.ID) == 0 && w.opt.DocType != "webm" {
+		info.ID = make(SegmentID, 16)
+		if _, err := rand.Read(info.ID); err != nil {
+			return err
+		}
+	}
+	if w.seek != nil && info.Duration == 0 {
+		// Placeholder to be overwritten by Close
+		info.Duration, w.dur = 1, true
+	}
+	w.scale = info.TimecodeScale
+	for _, t := range s.Tracks {
+		for _, it := range t.Entries {
+			if it.ID == 0 {
+				id, err := newUID()
+				if err != nil {
+					return err
+				}
+				it.ID = TrackID(id)
+			}
+			if it.Type == TrackTypeVideo {
+				w.video = true
+			}
+		}
+	}
+	header := NewFile(w.opt.DocType).EBML
+	header.DocTypeVersion, header.DocTypeReadVersion = 4, 2
+	enc := ebml.NewWriter(w.out)
+	if err := enc.EncodeElement(uint32(IDEBML), header); err != nil {
+		return err
+	}
+	w.segOff = w.out.n
+	if err := enc.WriteElementHeaderWidth(uint32(IDSegment), -1, 8); err != nil {
+		return err
+	}
+	w.start = w.out.n
+	if w.seek != nil {
+		w.headOff = w.out.n
+		if err := enc.WriteVoid(seekHeadSize); err != nil {
+			return err
+		}
+	}
+	w.infoOff = w.out.n
+	if err := w.writeTopLevel(&Segment{Info: s.Info}); err != nil {
+		return err
+	}
+	w.infoLen = w.out.n - w.infoOff
+	for _, it := range []*Segment{
+		{Tracks: s.Tracks},
+		{Chapters: s.Chapters},
+		{Attachments: s.Attachments},
+	} {
+		if err := w.writeTopLevel(it); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeTopLevel writes the Top-Level Elements of the partial Segment and adds them to the SeekHead.
+func (w *Writer) writeTopLevel(s *Segment) error {
+	b, err := ebml.Marshal(s)
+	if err != nil || len(b) == 0 {
+		return err
+	}
+	r := ebml.NewReaderBytes(b, nil)
+	id, _, err := r.ReadElement()
+	if err != nil {
+		return err
+	}
+	w.seeks = append(w.seeks, &Seek{ID(id), Position(w.out.n - w.start)})
+	_, err = w.out.Write(b)
+	return err
+}
+
+// WritePacket writes the packet into the current Cluster or starts a new one.
+// Data of the packet is retained until the next call if the packet is laced.
+//
+// If Interleave is set, packets are queued per track and retained until written.
+// Packets of each track except video tracks, which may contain reordered frames, must be in time order.
+func (w *Writer) WritePacket(p *Packet) error {
+	t := w.Segment.Track(p.Track)
+	if t == nil {
+		return errors.New("matroska: unknown track")
+	}
+	if f := w.Filters[p.Track]; f != nil {
+		c := *p
+		if err := f(&c); err != nil {
+			return err
+		}
+		p = &c
+	}
+	if w.opt.Interleave <= 0 {
+		return w.write(p, t)
+	}
+	if last, ok := w.times[p.Track]; ok && p.Time < last && t.Type != TrackTypeVideo {
+		return fmt.Errorf("matroska: time %v of track %d is before %v", p.Time, p.Track, last)
+	}
+	if p.Time < w.written && t.Type != TrackTypeVideo {
+		return fmt.Errorf("matroska: time %v of track %d is before written %v", p.Time, p.Track, w.written)
+	}
+	w.times[p.Track] = p.Time
+	w.queue[p.Track] = append(w.queue[p.Track], p)
+	if p.Time > w.newest {
+		w.newest = p.Time
+	}
+	return w.interleave(false)
+}
+
+// interleave writes queued packets in time order while every track that has received packets
+// has a queued packet or packets span more than the Interleave window.
+// All packets are written if all is set.
+func (w *Writer) interleave(all bool) error {
+	tracks := len(w.times)
+	for {
+		var head *Packet
+		n := 0
+		for _, q := range w.queue {
+			if len(q) == 0 {
+				continue
+			}
+			n++
+			if p := q[0]; head == nil || p.Time < head.Time || p.Time == head.Time && p.Track < head.Track {
+				head = p
+			}
+		}
+		if head == nil || !all && n < tracks && w.newest-head.Time <= w.opt.Interleave {
+			return nil
+		}
+		q := w.queue[head.Track]
+		q[0] = nil
+		w.queue[head.Track] = q[1:]
+		if head.Time > w.written {
+			w.written = head.Time
+		}
+		if err := w.write(head, w.Segment.Track(head.Track)); err != nil {
+			return err
+		}
+	}
+}
+
+func (w *Writer) write(p *Packet, t *TrackEntry) error {
+	lace := w.opt.MaxLacedFrames > 1 && laced(p, t)
+	if l := w.lace; l != nil {
+		d := p.Time - l.p.Time - time.Duration(len(l.frames))*t.DefaultDuration
+		if lace && l.p.Track == p.Track && len(l.frames) < w.opt.MaxLacedFrames && d > -w.scale/2 && d < w.scale/2 {
+			l.frames = append(l.frames, p.Data)
+			return nil
+		}
+		if err := w.flushLace(); err != nil {
+			return err
+		}
+	}
+	if lace {
+		w.lace = &lacedBlock{p: p, t: t, frames: [][]byte{p.Data}}
+		return nil
+	}
+	return w.writeBlock(p, t, [][]byte{p.Data})
+}
+
+// laced reports whether the packet can be laced with other frames of the track.
+func laced(p *Packet, t *TrackEntry) bool {
+	return t.Type == TrackTypeAudio && t.Lacing && t.DefaultDuration > 0 && p.Keyframe && !p.Invisible &&
+		(p.Duration == 0 || p.Duration == t.DefaultDuration) && p.DiscardPadding == 0 && len(p.Additions) == 0
+}
+
+func (w *Writer) flushLace() error {
+	l := w.lace
+	if l == nil {
+		return nil
+	}
+	w.lace = nil
+	if err := w.writeBlock(l.p, l.t, l.frames); err != nil {
+		return err
+	}
+	if end := l.p.Time + time.Duration(len(l.frames))*l.t.DefaultDuration; end > w.end {
+		w.end = end
+	}
+	return nil
+}
+
+func (w *Writer) writeBlock(p *Packet, t *TrackEntry, frames [][]byte) error {
+	tc := int64(p.Time / w.scale)
+	if w.split(p, t, tc) {
+		if err := w.flushCluster(); err != nil {
+			return err
+		}
+		c := &clusterWriter{pos: w.out.n - w.start, time: tc}
+		c.enc = ebml.NewWriter(&c.buf)
+		if err := c.enc.EncodeElement(0xE7, tc); err != nil {
+			return err
+		}
+		w.cluster = c
+	}
+	c := w.cluster
+	if p.Keyframe && (t.Type == TrackTypeVideo || !w.video && c.blocks == 0) {
+		w.cues = append(w.cues, &CuePoint{
+			Time: Time(tc),
+			TrackPositions: []*CueTrackPosition{{
+				Track:            p.Track,
+				ClusterPosition:  Position(c.pos),
+				RelativePosition: Position(c.buf.Len()),
+				BlockNumber:      c.blocks + 1,
+			}},
+		})
+	}
+	b := &Block{
+		TrackNumber: p.Track,
+		Timecode:    int16(tc - c.time),
+		Frames:      frames,
+	}
+	if p.Invisible {
+		b.Flags |= BlockFlagInvisible
+	}
+	var err error
+	if p.Duration != 0 && p.Duration != t.DefaultDuration || p.DiscardPadding != 0 || len(p.Additions) > 0 {
+		g := &BlockGroup{
+			Block:          b,
+			Additions:      p.Additions,
+			DiscardPadding: p.DiscardPadding,
+		}
+		if p.Duration != 0 {
+			g.Duration = Duration(p.Duration / w.scale)
+		}
+		if last, ok := w.last[p.Track]; ok && !p.Keyframe {
+			g.ReferenceBlock = []Time{Time(last - tc)}
+		}
+		err = c.enc.EncodeElement(0xA0, g)
+	} else {
+		if p.Keyframe {
+			b.Flags |= BlockFlagKeyframe
+		}
+		if p.Discardable {
+			b.Flags |= BlockFlagDiscardable
+		}
+		err = c.enc.EncodeElement(0xA3, b)
+	}
+	if err != nil {
+		return err
+	}
+	c.blocks++
+	w.last[p.Track] = tc
+	if end := p.Time + p.Duration; end > w.end {
+		w.end = end
+	}
+	return nil
+}
+
+// split reports whether the packet starts a new Cluster.
+func (w *Writer) split(p *Packet, t *TrackEntry, tc int64) bool {
+	c := w.cluster
+	if c == nil {
+		return true
+	}
+	d := tc - c.time
+	if d < math.MinInt16 || d > math.MaxInt16 || c.buf.Len() >= w.opt.ClusterSize {
+		return true
+	}
+	key := p.Keyframe && (t.Type == TrackTypeVideo || !w.video)
+	return key && time.Duration(d)*w.scale >= w.opt.ClusterDuration
+}
+
+func (w *Writer) flushCluster() error {
+	c := w.cluster
+	if c == nil {
+		return nil
+	}
+	w.cluster = nil
+	enc := ebml.NewWriter(w.out)
+	if err := enc.WriteElementHeader(uint32(IDCluster), int64(c.buf.Len())); err != nil {
+		return err
+	}
+	_, err := w.out.Write(c.buf.Bytes())
+	return err
+}
+
+// Flush writes queued packets and the current Cluster, the next packet starts a new one.
+func (w *Writer) Flush() error {
+	if err := w.interleave(true); err != nil {
+		return err
+	}
+	if err := w.flushLace(); err != nil {
+		return err
+	}
+	return w.flushCluster()
+}
+
+// Close writes the last Cluster, Cues and Tags and finalizes the Segment if the output is seekable.
+func (w *Writer) Close() error {
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if err := w.writeTopLevel(&Segment{Cues: w.cues}); err != nil {
+		return err
+	}
+	if err := w.writeTopLevel(&Segment{Tags: w.Segment.Tags}); err != nil {
+		return err
+	}
+	if w.seek == nil {
+		return nil
+	}
+	end := w.out.n
+	info := w.Segment.Info[0]
+	if w.dur {
+		info.Duration = float64(w.end) / float64(w.scale)
+	}
+	b, err := ebml.Marshal(&Segment{Info: w.Segment.Info})
+	if err != nil {
+		return err
+	}
+	var ok bool
+	if b, ok = fit(b, w.infoLen); !ok {
+		return errors.New("matroska: info does not fit into the written info")
+	}
+	if err = w.writeAt(w.infoOff, b); err != nil {
+		return err
+	}
+	if b, err = ebml.Marshal(&Segment{SeekHead: []*SeekHead{{Seeks: w.seeks}}}); err != nil {
+		return err
+	}
+	if b, ok = fit(b, seekHeadSize); !ok {
+		return errors.New("matroska: seek head does not fit into the reserved space")
+	}
+	if err = w.writeAt(w.headOff, b); err != nil {
+		return err
+	}
+	h := &bytes.Buffer{}
+	if err = ebml.NewWriter(h).WriteElementHeaderWidth(uint32(IDSegment), end-w.start, 8); err != nil {
+		return err
+	}
+	if err = w.writeAt(w.segOff, h.Bytes()); err != nil {
+		return err
+	}
+	_, err = w.seek.Seek(w.base+end, io.SeekStart)
+	return err
+}
+
+// fit pads the encoded element b with Void or widens its size to take exactly n bytes.
+// Returns false if b does not fit.
+func fit(b []byte, n int64) ([]byte, bool) {
+	if n-int64(len(b)) == 1 {
+		b = widen(b)
+	}
+	pad := n - int64(len(b))
+	if pad < 0 || pad == 1 {
+		return nil, false
+	}
+	if pad > 0 {
+		h := bytes.NewBuffer(b)
+		if err := ebml.NewWriter(h).WriteVoid(pad); err != nil {
+			return nil, false
+		}
+		b = h.Bytes()
+	}
+	return b, true
+}
+
+func (w *Writer) writeAt(off int64, b []byte) error {
+	if _, err := w.seek.Seek(w.base+off, io.SeekStart); err != nil {
+		return err
+	}
+	_, err := w.seek.Write(b)
+	return err
+}
+
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(b []byte) (int, error) {
+	n, err := c.w.Write(b)
+	c.n += int64(n)
+	return n, err
+}
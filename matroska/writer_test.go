@@ -0,0 +1,273 @@
+package matroska
+
+import (
+	"bytes"
+	"github.com/pixelbender/go-matroska/ebml"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "matroska")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "writer.mkv")
+	out, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	seg := &Segment{Tracks: []*Track{{Entries: []*TrackEntry{
+		{Number: 1, Type: TrackTypeVideo, CodecID: "V_VP8", Enabled: true, Default: true, DefaultDuration: 40 * time.Millisecond, Video: &VideoTrack{Width: 320, Height: 240}},
+		{Number: 2, Type: TrackTypeSubtitle, CodecID: "S_TEXT/UTF8", Enabled: true, Default: true},
+	}}}}
+	w, err := NewWriter(out, seg, &WriterOptions{ClusterDuration: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var packets []*Packet
+	for i := 0; i < 100; i++ {
+		packets = append(packets, &Packet{
+			Track:    1,
+			Time:     time.Duration(i) * 40 * time.Millisecond,
+			Duration: 40 * time.Millisecond,
+			Keyframe: i%25 == 0,
+			Data:     []byte{byte(i)},
+		})
+		if i%50 == 10 {
+			packets = append(packets, &Packet{
+				Track:    2,
+				Time:     time.Duration(i) * 40 * time.Millisecond,
+				Duration: 1500 * time.Millisecond,
+				Keyframe: true,
+				Data:     []byte("text"),
+			})
+		}
+	}
+	for _, it := range packets {
+		if err = w.WritePacket(it); err != nil {
+			t.Fatal(err)
+		}
+	}
+	seg.Tags = []*Tag{{SimpleTags: []*SimpleTag{NewSimpleTag("TITLE", "Test")}}}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	doc, err := Decode(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := doc.Segment
+	if len(s.Info) != 1 || s.Info[0].Duration != 4000 {
+		t.Errorf("Unexpected info: %s", dump(s.Info))
+	}
+	if len(s.Cluster) != 4 || len(s.Cues) != 4 || len(s.Tags) != 1 {
+		t.Errorf("Unexpected clusters: %d, cues: %d, tags: %d", len(s.Cluster), len(s.Cues), len(s.Tags))
+	}
+	for i, it := range s.Cues {
+		if it.Time != Time(i*1000) || it.TrackPositions[0].Track != 1 {
+			t.Errorf("Unexpected cue point: %s", dump(it))
+		}
+	}
+	if err = checkSeekHead(file); err != nil {
+		t.Error(err)
+	}
+	if _, err = out.Seek(0, 0); err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewReader(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range packets {
+		p, err := r.ReadPacket()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Track != want.Track || p.Time != want.Time || p.Duration != want.Duration || p.Keyframe != want.Keyframe || string(p.Data) != string(want.Data) {
+			t.Fatalf("Unexpected packet: %s, want: %s", dump(p), dump(want))
+		}
+	}
+	if _, err = r.ReadPacket(); err == nil {
+		t.Error("Expected end of stream")
+	}
+}
+
+func TestWriterInterleave(t *testing.T) {
+	seg := &Segment{Tracks: []*Track{{Entries: []*TrackEntry{
+		{Number: 1, Type: TrackTypeVideo, CodecID: CodecVP8, Enabled: true, Default: true, Video: &VideoTrack{Width: 320, Height: 240}},
+		{Number: 2, Type: TrackTypeAudio, CodecID: CodecOpus, Enabled: true, Default: true, Audio: &AudioTrack{SamplingFreq: 48000, Channels: 2}},
+	}}}}
+	b := &bytes.Buffer{}
+	w, err := NewWriter(b, seg, &WriterOptions{ClusterDuration: 500 * time.Millisecond, Interleave: 2 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Audio of the second arrives after the first video frame
+	if err = w.WritePacket(&Packet{Track: 1, Time: 0, Keyframe: true, Data: []byte{0}}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 50; i++ {
+		if err = w.WritePacket(&Packet{Track: 2, Time: time.Duration(i) * 20 * time.Millisecond, Keyframe: true, Data: []byte{byte(i)}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 1; i < 25; i++ {
+		if err = w.WritePacket(&Packet{Track: 1, Time: time.Duration(i) * 40 * time.Millisecond, Keyframe: i%10 == 0, Data: []byte{byte(i)}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = w.WritePacket(&Packet{Track: 2, Time: 500 * time.Millisecond, Keyframe: true}); err == nil {
+		t.Error("Expected error for the non-monotonic packet")
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewReader(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var last time.Duration
+	n := 0
+	for {
+		p, err := r.ReadPacket()
+		if err != nil {
+			break
+		}
+		if p.Time < last {
+			t.Errorf("Packet of track %d at %v is written after %v", p.Track, p.Time, last)
+		}
+		last, n = p.Time, n+1
+	}
+	if n != 75 {
+		t.Errorf("Unexpected number of packets: %d", n)
+	}
+	// Packets of the only track with packets are written at once, older audio is rejected
+	w, err = NewWriter(&bytes.Buffer{}, seg, &WriterOptions{Interleave: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i <= 15; i++ {
+		if err = w.WritePacket(&Packet{Track: 1, Time: time.Duration(i) * 40 * time.Millisecond, Keyframe: i == 0, Data: []byte{byte(i)}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = w.WritePacket(&Packet{Track: 2, Time: 0, Keyframe: true}); err == nil {
+		t.Error("Expected error for the packet older than written packets")
+	}
+	if err = w.WritePacket(&Packet{Track: 2, Time: 600 * time.Millisecond, Keyframe: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// Video frames in decode order with B-frames, then with the subtitle track without packets
+	for _, sub := range []bool{false, true} {
+		if sub {
+			seg.Tracks[0].Entries = append(seg.Tracks[0].Entries, &TrackEntry{Number: 3, Type: TrackTypeSubtitle, CodecID: "S_TEXT/WEBVTT", Enabled: true})
+		}
+		w, err = NewWriter(&bytes.Buffer{}, seg, &WriterOptions{Interleave: 2 * time.Second})
+		if err != nil {
+			t.Fatal(err)
+		}
+		audio := 0
+		for i, n := range []int{0, 3, 1, 2, 6, 4, 5} {
+			// Audio is ahead of the decode time of video
+			for ; audio <= i*2+5; audio++ {
+				if err = w.WritePacket(&Packet{Track: 2, Time: time.Duration(audio) * 20 * time.Millisecond, Keyframe: true, Data: []byte{byte(audio)}}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err = w.WritePacket(&Packet{Track: 1, Time: time.Duration(n) * 40 * time.Millisecond, Keyframe: n == 0, Data: []byte{byte(n)}}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		// Only audio ahead of the last video frame is queued
+		if len(w.queue[1]) != 0 || len(w.queue[2]) == 0 || w.queue[2][0].Time < w.written {
+			t.Errorf("Unexpected queued packets: %d video, %d audio", len(w.queue[1]), len(w.queue[2]))
+		}
+		if err = w.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestWriterClose(t *testing.T) {
+	dir, err := ioutil.TempDir("", "matroska")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, title := range []string{"Title", strings.Repeat("Long title ", 20)} {
+		file := filepath.Join(dir, "close.mkv")
+		out, err := os.Create(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		seg := &Segment{Info: []*Info{{Title: "Original title"}}, Tracks: []*Track{{Entries: []*TrackEntry{
+			{Number: 1, Type: TrackTypeVideo, CodecID: "V_VP8", Enabled: true, Default: true, Video: &VideoTrack{Width: 320, Height: 240}},
+		}}}}
+		w, err := NewWriter(out, seg, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Blocks with durations are written in groups, the first one has no block to reference
+		for i := 0; i < 2; i++ {
+			if err = w.WritePacket(&Packet{Track: 1, Time: time.Duration(i) * 40 * time.Millisecond, Duration: 40 * time.Millisecond, Data: []byte{byte(i)}}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		seg.Info[0].Title = title
+		err = w.Close()
+		out.Close()
+		if len(title) > 100 {
+			if err == nil {
+				t.Error("Expected error for the info larger than written")
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		doc, err := Decode(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := doc.Segment
+		if s.Info[0].Title != title || len(s.Tracks) != 1 {
+			t.Errorf("Unexpected info: %s", dump(s.Info))
+		}
+		if g := s.Cluster[0].BlockGroup; len(g) != 2 || len(g[0].ReferenceBlock) != 0 || len(g[1].ReferenceBlock) != 1 || g[1].ReferenceBlock[0] != -40 {
+			t.Errorf("Unexpected block groups: %s", dump(g))
+		}
+		if err = checkSeekHead(file); err != nil {
+			t.Error(err)
+		}
+	}
+	b, err := ebml.Marshal(&Segment{Info: []*Info{{Title: "Title", MuxingApp: "go-matroska", WritingApp: "go-matroska"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for n := len(b) - 1; n <= len(b)+3; n++ {
+		v, ok := fit(b, int64(n))
+		if ok != (n >= len(b)) || ok && len(v) != n {
+			t.Errorf("Unexpected fit into %d bytes: %x", n, v)
+		}
+		if ok {
+			info := &Info{}
+			id, elem, err := ebml.NewReaderBytes(v, nil).ReadElement()
+			if err == nil {
+				err = elem.Decode(info)
+			}
+			if err != nil || ID(id) != IDInfo || info.Title != "Title" {
+				t.Errorf("Unexpected fit into %d bytes: %v", n, err)
+			}
+		}
+	}
+}